@@ -0,0 +1,213 @@
+// Package alert notifies channels when modems match the alert rules in the config.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/carrier"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/notify"
+)
+
+var (
+	errUnknownRule      = errors.New("unknown alert rule")
+	errInvalidThreshold = errors.New("signal alerts need a threshold between 1 and 100")
+)
+
+const (
+	// pollInterval is how often modems are evaluated without an event, so that
+	// alerts are sent once their duration has passed.
+	pollInterval = 15 * time.Second
+	defaultFor   = time.Minute
+)
+
+// Service evaluates the alerts on every modem event and every poll interval.
+type Service struct {
+	cfg      *config.Config
+	manager  *modem.Manager
+	notifier *notify.Notifier
+	wake     chan struct{}
+
+	// states are only used by Run, so they are not locked.
+	states map[key]*state
+}
+
+type key struct {
+	alert int // index in cfg.Alerts
+	modem string
+}
+
+// state is the value of a modem for one alert.
+type state struct {
+	value    string
+	since    time.Time // when value was first seen
+	notified string    // the value last alerted on
+}
+
+func New(cfg *config.Config, manager *modem.Manager) (*Service, error) {
+	for _, alert := range cfg.Alerts {
+		if _, ok := rules[alert.Rule]; !ok {
+			return nil, fmt.Errorf("%w %q", errUnknownRule, alert.Rule)
+		}
+		if alert.Rule == "signal" && (alert.Threshold < 1 || alert.Threshold > 100) {
+			return nil, errInvalidThreshold
+		}
+	}
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating notifier: %w", err)
+	}
+	return &Service{
+		cfg:      cfg,
+		manager:  manager,
+		notifier: notifier,
+		wake:     make(chan struct{}, 1),
+		states:   make(map[key]*state),
+	}, nil
+}
+
+// Run evaluates the alerts until ctx is canceled.
+func (s *Service) Run(ctx context.Context) error {
+	if len(s.cfg.Alerts) == 0 {
+		slog.Info("alerts disabled; no alerts configured")
+		<-ctx.Done()
+		return nil
+	}
+	// Events only wake the loop, so that bursts of them are evaluated once and
+	// publishing is never blocked on D-Bus calls.
+	unsubscribe, err := s.manager.Subscribe(func(modem.ModemEvent) error {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to modem manager: %w", err)
+	}
+	defer unsubscribe()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		s.evaluate(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *Service) evaluate(now time.Time) {
+	modems, err := s.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	observations := make(map[string]*observation, len(modems))
+	for _, m := range modems {
+		observations[m.EquipmentIdentifier] = observe(m)
+	}
+	for i, alert := range s.cfg.Alerts {
+		r := rules[alert.Rule]
+		ids := make([]string, 0, len(observations))
+		for id := range observations {
+			ids = append(ids, id)
+		}
+		// Removed modems are only evaluated if they were seen before.
+		for k := range s.states {
+			if k.alert == i && observations[k.modem] == nil {
+				ids = append(ids, k.modem)
+			}
+		}
+		for _, id := range ids {
+			if len(alert.Modems) > 0 && !slices.Contains(alert.Modems, id) {
+				continue
+			}
+			s.apply(i, alert, r, id, observations[id], now)
+		}
+	}
+}
+
+// apply updates the state of a modem for one alert and sends the alert when the
+// modem has kept a new value for long enough.
+func (s *Service) apply(i int, alert config.Alert, r rule, id string, o *observation, now time.Time) {
+	k := key{alert: i, modem: id}
+	st := s.states[k]
+	value, ok := r.value(alert, o)
+	if !ok {
+		// A modem that cannot be told does not keep its pending value, e.g. one
+		// that is unplugged while its signal is low.
+		if st != nil {
+			st.value, st.since = st.notified, now
+		}
+		return
+	}
+	if st == nil {
+		st = &state{value: value, since: now}
+		if r.change {
+			st.notified = value
+		}
+		s.states[k] = st
+	}
+	if value != st.value {
+		st.value, st.since = value, now
+	}
+	duration := defaultFor
+	if alert.ForSeconds > 0 {
+		duration = time.Duration(alert.ForSeconds) * time.Second
+	}
+	if st.value == st.notified || now.Sub(st.since) < duration {
+		return
+	}
+	previous := st.notified
+	st.notified = st.value
+	slog.Info("sending alert", "rule", alert.Rule, "modem", id, "from", previous, "to", st.value)
+	text := r.message(alert, previous, st.value, o) + "\nModem: " + s.name(id)
+	if alert.Name != "" {
+		text = alert.Name + "\n" + text
+	}
+	if err := s.notifier.Send(notify.TextMessage{Text: text}, alert.Channels...); err != nil {
+		slog.Error("failed to send alert", "rule", alert.Rule, "modem", id, "error", err)
+	}
+}
+
+// observe reads what the rules need from a modem. Values that cannot be read are
+// logged at debug level only, as modems that are starting up often lack them.
+func observe(m *modem.Modem) *observation {
+	o := &observation{modem: m}
+	if m.State < modem.ModemStateEnabled {
+		return o
+	}
+	if state, err := m.ThreeGPP().RegistrationState(); err == nil {
+		o.registration = &state
+	} else {
+		slog.Debug("failed to read registration state", "modem", m.EquipmentIdentifier, "error", err)
+	}
+	if o.registered() {
+		o.operator, _ = m.ThreeGPP().OperatorCode()
+		o.operatorName, _ = m.ThreeGPP().OperatorName()
+		if o.operatorName == "" {
+			o.operatorName = carrier.Lookup(o.operator).Name
+		}
+	}
+	if quality, _, err := m.SignalQuality(); err == nil {
+		o.quality = &quality
+	}
+	return o
+}
+
+func (s *Service) name(id string) string {
+	if alias := s.cfg.FindModem(id).Alias; alias != "" {
+		return alias
+	}
+	return id
+}
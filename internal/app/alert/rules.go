@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"fmt"
+
+	"github.com/damonto/sigmo/internal/pkg/carrier"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+// observation is what a modem looked like when the rules were evaluated. Values
+// that could not be read are left unset, and rules that need them are skipped.
+type observation struct {
+	modem        *modem.Modem
+	registration *modem.Modem3gppRegistrationState
+	operator     string // the MCC and MNC of the registered network
+	operatorName string
+	quality      *uint32
+}
+
+func (o *observation) registered() bool {
+	return o.registration != nil && o.registration.Registered()
+}
+
+// rule turns an observation into a value. An alert is sent when the value a modem
+// has kept for the alert's duration differs from the one last alerted on.
+// Conditions use "" for a healthy modem, so they alert when they start and stop
+// matching. Changes start from the first value seen, so only changes are alerted.
+type rule struct {
+	change bool
+	// value returns the value of a modem, or false if it cannot be told, such as
+	// a registration while the modem is disabled. o is nil for a removed modem.
+	value func(alert config.Alert, o *observation) (string, bool)
+	// message describes a modem going from previous to current.
+	message func(alert config.Alert, previous, current string, o *observation) string
+}
+
+var rules = map[string]rule{
+	"unplugged": {
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil {
+				return "unplugged", true
+			}
+			return "", true
+		},
+		message: func(_ config.Alert, _, current string, _ *observation) string {
+			if current != "" {
+				return "Modem unplugged"
+			}
+			return "Modem plugged in again"
+		},
+	},
+	"unregistered": {
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil || o.modem.State < modem.ModemStateEnabled || o.registration == nil {
+				return "", false
+			}
+			if o.registered() {
+				return "", true
+			}
+			return "unregistered", true
+		},
+		message: func(_ config.Alert, _, current string, o *observation) string {
+			if current != "" {
+				return fmt.Sprintf("Modem not registered\nState: %s", *o.registration)
+			}
+			return fmt.Sprintf("Modem registered again\nOperator: %s (%s)", o.operatorName, o.operator)
+		},
+	},
+	"roaming": {
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil || !o.registered() {
+				return "", false
+			}
+			if o.registration.Roaming() {
+				return "roaming", true
+			}
+			return "", true
+		},
+		message: func(_ config.Alert, _, current string, o *observation) string {
+			if current != "" {
+				return fmt.Sprintf("Modem roaming\nOperator: %s (%s)", o.operatorName, o.operator)
+			}
+			return fmt.Sprintf("Modem no longer roaming\nOperator: %s (%s)", o.operatorName, o.operator)
+		},
+	},
+	"operator": {
+		change: true,
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil || !o.registered() || o.operator == "" {
+				return "", false
+			}
+			return o.operator, true
+		},
+		message: func(_ config.Alert, previous, current string, o *observation) string {
+			return fmt.Sprintf("Operator changed\nFrom: %s (%s)\nTo: %s (%s)", carrier.Lookup(previous).Name, previous, o.operatorName, current)
+		},
+	},
+	"signal": {
+		value: func(alert config.Alert, o *observation) (string, bool) {
+			if o == nil || !o.registered() || o.quality == nil {
+				return "", false
+			}
+			if *o.quality < uint32(alert.Threshold) {
+				return "low", true
+			}
+			return "", true
+		},
+		message: func(alert config.Alert, _, current string, o *observation) string {
+			if current != "" {
+				return fmt.Sprintf("Signal low\nQuality: %d%% (below %d%%)", *o.quality, alert.Threshold)
+			}
+			return fmt.Sprintf("Signal recovered\nQuality: %d%%", *o.quality)
+		},
+	},
+	"sim-missing": {
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil || o.modem.State == modem.ModemStateInitializing {
+				return "", false
+			}
+			if o.modem.Sim == nil {
+				return "missing", true
+			}
+			return "", true
+		},
+		message: func(_ config.Alert, _, current string, o *observation) string {
+			if current != "" {
+				return "SIM missing"
+			}
+			return fmt.Sprintf("SIM inserted\nICCID: %s", o.modem.Sim.Identifier)
+		},
+	},
+	"profile": {
+		change: true,
+		value: func(_ config.Alert, o *observation) (string, bool) {
+			if o == nil || o.modem.Sim == nil || o.modem.Sim.Identifier == "" {
+				return "", false
+			}
+			return o.modem.Sim.Identifier, true
+		},
+		message: func(_ config.Alert, previous, current string, o *observation) string {
+			return fmt.Sprintf("Profile changed\nFrom: %s\nTo: %s (%s)", previous, current, o.modem.Sim.OperatorName)
+		},
+	},
+}
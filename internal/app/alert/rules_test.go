@@ -0,0 +1,95 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const testModem = "860000000000001"
+
+// capture returns a config with one HTTP channel and a function returning the
+// texts sent to it so far.
+func capture(t *testing.T) (*config.Config, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		texts []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		mu.Lock()
+		texts = append(texts, message.Text)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	cfg := &config.Config{Channels: map[string]config.Channel{"http": {Endpoint: server.URL}}}
+	return cfg, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(texts)
+	}
+}
+
+func TestSimMissing(t *testing.T) {
+	withSIM := func(state modem.ModemState) *observation {
+		return &observation{modem: &modem.Modem{
+			EquipmentIdentifier: testModem,
+			State:               state,
+			Sim:                 &modem.SIM{Identifier: "89860000000000000011"},
+		}}
+	}
+	withoutSIM := func(state modem.ModemState) *observation {
+		return &observation{modem: &modem.Modem{EquipmentIdentifier: testModem, State: state}}
+	}
+	tests := []struct {
+		after time.Duration
+		o     *observation
+		want  string // the alert sent, if any
+	}{
+		{after: 0, o: withSIM(modem.ModemStateRegistered)},
+		{after: 10 * time.Second, o: withoutSIM(modem.ModemStateFailed)},
+		{after: 30 * time.Second, o: withoutSIM(modem.ModemStateFailed)},
+		{after: 80 * time.Second, o: withoutSIM(modem.ModemStateFailed), want: "SIM missing\nModem: " + testModem},
+		{after: 90 * time.Second, o: withoutSIM(modem.ModemStateFailed)},
+		// A modem that is starting up has not read its SIM yet.
+		{after: 100 * time.Second, o: withoutSIM(modem.ModemStateInitializing)},
+		{after: 110 * time.Second, o: withSIM(modem.ModemStateEnabled)},
+		{after: 150 * time.Second, o: nil},
+		// The removed modem dropped the pending value, so the minute starts over.
+		{after: 180 * time.Second, o: withSIM(modem.ModemStateEnabled)},
+		{after: 220 * time.Second, o: withSIM(modem.ModemStateRegistered)},
+		{after: 240 * time.Second, o: withSIM(modem.ModemStateRegistered), want: "SIM inserted\nICCID: 89860000000000000011\nModem: " + testModem},
+		{after: 400 * time.Second, o: withSIM(modem.ModemStateRegistered)},
+	}
+
+	cfg, sent := capture(t)
+	cfg.Alerts = []config.Alert{{Rule: "sim-missing"}}
+	s, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var want []string
+	for _, tt := range tests {
+		s.apply(0, cfg.Alerts[0], rules["sim-missing"], testModem, tt.o, start.Add(tt.after))
+		if tt.want != "" {
+			want = append(want, tt.want)
+		}
+		if got := sent(); !slices.Equal(got, want) {
+			t.Fatalf("alerts after %s = %q, want %q", tt.after, got, want)
+		}
+	}
+}
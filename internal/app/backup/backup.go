@@ -0,0 +1,180 @@
+// Package backup periodically saves the profiles of every eUICC to disk.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/damonto/sigmo/internal/app/handler/esim"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const (
+	// defaultKeep is how many backups are kept per eUICC when none is configured.
+	defaultKeep = 30
+	// modemTimeout bounds how long a single modem may take, including waiting for its lock.
+	modemTimeout = 30 * time.Second
+	// timeFormat names backup files so that they sort by time.
+	timeFormat = "20060102T150405Z"
+)
+
+// Backup writes an export of each eUICC to <data dir>/backups/<eid>/<time>.json
+// whenever its profiles changed since the previous backup.
+type Backup struct {
+	cfg     *config.Config
+	manager *modem.Manager
+	esim    *esim.Service
+	dir     string
+}
+
+func New(cfg *config.Config, manager *modem.Manager) *Backup {
+	return &Backup{
+		cfg:     cfg,
+		manager: manager,
+		esim:    esim.NewService(cfg, manager),
+		dir:     cfg.DataPath("backups"),
+	}
+}
+
+func (b *Backup) Enabled() bool {
+	return b.cfg.Backup.IntervalHours > 0
+}
+
+// Run backs up every eUICC now and then every configured interval until ctx is canceled.
+func (b *Backup) Run(ctx context.Context) error {
+	if !b.Enabled() {
+		slog.Info("profile backup disabled")
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(time.Duration(b.cfg.Backup.IntervalHours) * time.Hour)
+	defer ticker.Stop()
+	for {
+		b.backupAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Backup) backupAll(ctx context.Context) {
+	modems, err := b.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	for _, m := range modems {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := b.backup(ctx, m); err != nil {
+			if errors.Is(err, lpa.ErrNoSupportedAID) {
+				continue
+			}
+			slog.Error("failed to back up profiles", "modem", m.EquipmentIdentifier, "error", err)
+		}
+	}
+}
+
+func (b *Backup) backup(ctx context.Context, m *modem.Modem) error {
+	ctx, cancel := context.WithTimeout(ctx, modemTimeout)
+	defer cancel()
+	export, err := b.esim.Export(ctx, m)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(b.dir, export.EID)
+	names, err := backups(dir)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		unchanged, err := sameProfiles(filepath.Join(dir, names[len(names)-1]), export)
+		if err != nil {
+			slog.Warn("failed to read previous backup", "modem", m.EquipmentIdentifier, "error", err)
+		}
+		if unchanged {
+			return nil
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding backup: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	name := export.ExportedAt.Format(timeFormat) + ".json"
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+	slog.Info("backed up profiles", "modem", m.EquipmentIdentifier, "eid", export.EID, "profiles", len(export.Profiles))
+	b.prune(dir, append(names, name))
+	return nil
+}
+
+// prune removes the oldest backups beyond the configured number.
+func (b *Backup) prune(dir string, names []string) {
+	keep := b.cfg.Backup.Keep
+	if keep <= 0 {
+		keep = defaultKeep
+	}
+	for _, name := range names[:max(len(names)-keep, 0)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			slog.Warn("failed to remove old backup", "path", filepath.Join(dir, name), "error", err)
+		}
+	}
+}
+
+// backups returns the names of the backups in dir, oldest first.
+func backups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// sameProfiles reports whether the backup at path holds the same profiles as export.
+func sameProfiles(path string, export *esim.ExportResponse) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var previous esim.ExportResponse
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return false, err
+	}
+	a, err := json.Marshal(previous.Profiles)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(export.Profiles)
+	if err != nil {
+		return false, err
+	}
+	return string(a) == string(b), nil
+}
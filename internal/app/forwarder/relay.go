@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/godbus/dbus/v5"
@@ -24,6 +25,7 @@ type Relay struct {
 	cancels   map[dbus.ObjectPath]context.CancelFunc
 	equipment map[string]dbus.ObjectPath
 	modems    map[dbus.ObjectPath]string
+	running   atomic.Bool
 }
 
 func New(cfg *config.Config, manager *modem.Manager) (*Relay, error) {
@@ -45,6 +47,11 @@ func (r *Relay) Enabled() bool {
 	return len(r.cfg.Channels) > 0
 }
 
+// Running reports whether the relay is following the modems' messages.
+func (r *Relay) Running() bool {
+	return r.running.Load()
+}
+
 func (r *Relay) Run(ctx context.Context) error {
 	if len(r.cfg.Channels) == 0 {
 		slog.Info("message relay disabled; no channels configured")
@@ -76,6 +83,8 @@ func (r *Relay) Run(ctx context.Context) error {
 		return fmt.Errorf("subscribing to modem manager: %w", err)
 	}
 	defer unsubscribe()
+	r.running.Store(true)
+	defer r.running.Store(false)
 
 	<-ctx.Done()
 	r.stopAll()
@@ -0,0 +1,32 @@
+package debug
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+)
+
+type Handler struct {
+	handler.Handler
+}
+
+func New() *Handler {
+	return &Handler{}
+}
+
+// Locks lists the operations currently holding an eUICC.
+func (h *Handler) Locks(c echo.Context) error {
+	holders := lpa.Holders()
+	response := make([]LockHolderResponse, 0, len(holders))
+	for _, holder := range holders {
+		response = append(response, LockHolderResponse{
+			Modem:     fmt.Sprint(holder.Key),
+			Operation: holder.Operation,
+			Since:     holder.Since,
+		})
+	}
+	return h.Respond(c, response)
+}
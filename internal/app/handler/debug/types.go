@@ -0,0 +1,9 @@
+package debug
+
+import "time"
+
+type LockHolderResponse struct {
+	Modem     string    `json:"modem"`
+	Operation string    `json:"operation"`
+	Since     time.Time `json:"since"`
+}
@@ -8,6 +8,7 @@ import (
 
 	elpa "github.com/damonto/euicc-go/lpa"
 
+	"github.com/damonto/sigmo/internal/pkg/lpa"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
 )
 
@@ -17,6 +18,13 @@ func buildActivationCode(modem *mmodem.Modem, start downloadClientMessage) (*elp
 		return nil, err
 	}
 	matchingID := strings.TrimSpace(start.ActivationCode)
+	// Events discovered through SM-DS are downloaded by using the event ID as the matching ID.
+	if eventID := strings.TrimSpace(start.EventID); eventID != "" {
+		if matchingID != "" {
+			return nil, errors.New("activationCode and eventId cannot be used together")
+		}
+		matchingID = eventID
+	}
 	imei, err := modem.ThreeGPP().IMEI()
 	if err != nil {
 		return nil, fmt.Errorf("reading modem IMEI: %w", err)
@@ -30,16 +38,9 @@ func buildActivationCode(modem *mmodem.Modem, start downloadClientMessage) (*elp
 }
 
 func parseSMDP(raw string) (*url.URL, error) {
-	smdp := strings.TrimSpace(raw)
-	if smdp == "" {
-		return nil, errors.New("smdp is required")
-	}
-	if !strings.Contains(smdp, "://") {
-		smdp = "https://" + smdp
-	}
-	parsed, err := url.Parse(smdp)
-	if err != nil || parsed.Host == "" {
-		return nil, fmt.Errorf("invalid smdp %q", raw)
+	address, err := lpa.ParseAddress(raw)
+	if err != nil {
+		return nil, fmt.Errorf("smdp: %w", err)
 	}
-	return &url.URL{Scheme: "https", Host: parsed.Host}, nil
+	return &url.URL{Scheme: "https", Host: address.Host}, nil
 }
@@ -0,0 +1,125 @@
+package esim
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/fakecard"
+	"github.com/damonto/sigmo/internal/pkg/fakesmdp"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const testMatchingID = "TEST-MATCHING-ID"
+
+// dial serves the download handler with LPA clients routed to card and trusting
+// smdp, and opens a download websocket for modem.
+func dial(t *testing.T, smdp *fakesmdp.Server, card *fakecard.Card, modem *mmodem.Modem) *websocket.Conn {
+	t.Helper()
+	e := echo.New()
+	h := New(&config.Config{App: config.App{DataDir: t.TempDir()}}, bus.Manager)
+	e.GET("/modems/:id/esims/download", h.Download, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := lpa.WithRootCAs(lpa.WithChannel(c.Request().Context(), card), smdp.RootCAs())
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/modems/" + modem.EquipmentIdentifier + "/esims/download"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name             string
+		confirmationCode string
+		accept           bool
+		cancel           bool // send cancel instead of answering the last prompt
+		code             string
+		wantType         string
+		wantMessage      string
+		wantInstalled    bool
+	}{
+		{name: "accepted", accept: true, wantType: wsTypeCompleted, wantInstalled: true},
+		{name: "rejected preview", accept: false, wantType: wsTypeError, wantMessage: lpa.ErrDownloadCanceled.Error()},
+		{name: "confirmation code", confirmationCode: "1234", accept: true, code: "1234", wantType: wsTypeCompleted, wantInstalled: true},
+		{name: "wrong confirmation code", confirmationCode: "1234", accept: true, code: "4321", wantType: wsTypeError},
+		{name: "canceled preview", cancel: true, wantType: wsTypeError, wantMessage: lpa.ErrDownloadCanceled.Error()},
+		{name: "canceled confirmation code", confirmationCode: "1234", accept: true, cancel: true, wantType: wsTypeError, wantMessage: lpa.ErrDownloadCanceled.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, card, modem := setup(t, fakecard.Profile{ICCID: testICCID1, Enabled: true})
+			smdp, err := fakesmdp.Start(fakesmdp.Profile{
+				MatchingID:          testMatchingID,
+				ICCID:               testICCID2,
+				ServiceProviderName: "Operator B",
+				ProfileName:         "Travel",
+				ConfirmationCode:    tt.confirmationCode,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(smdp.Close)
+			conn := dial(t, smdp, card, modem)
+			if err := conn.WriteJSON(downloadClientMessage{Type: wsTypeStart, SMDP: smdp.Address(), ActivationCode: testMatchingID}); err != nil {
+				t.Fatal(err)
+			}
+
+			var last downloadServerMessage
+			for last.Type != wsTypeCompleted && last.Type != wsTypeError {
+				_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+				last = downloadServerMessage{}
+				if err := conn.ReadJSON(&last); err != nil {
+					t.Fatalf("reading download message: %v", err)
+				}
+				// The confirmation code is asked for after the preview, so it is the last prompt when set.
+				lastPrompt := tt.confirmationCode == "" && last.Type == wsTypePreview || last.Type == wsTypeConfirmationCodeRequired
+				switch {
+				case tt.cancel && lastPrompt:
+					err = conn.WriteJSON(downloadClientMessage{Type: wsTypeCancel})
+				case last.Type == wsTypePreview:
+					if last.Profile == nil || last.Profile.ICCID != testICCID2 {
+						t.Errorf("preview = %+v, want profile %s", last.Profile, testICCID2)
+					}
+					err = conn.WriteJSON(downloadClientMessage{Type: wsTypeConfirm, Accept: &tt.accept})
+				case last.Type == wsTypeConfirmationCodeRequired:
+					err = conn.WriteJSON(downloadClientMessage{Type: wsTypeConfirmationCode, Code: tt.code})
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last.Type != tt.wantType || (tt.wantMessage != "" && last.Message != tt.wantMessage) {
+				t.Errorf("last message = %s %q, want %s %q", last.Type, last.Message, tt.wantType, tt.wantMessage)
+			}
+
+			installed := false
+			for _, profile := range card.Profiles() {
+				installed = installed || profile.ICCID == testICCID2
+			}
+			if installed != tt.wantInstalled {
+				t.Errorf("profile installed = %v, want %v", installed, tt.wantInstalled)
+			}
+			if !tt.wantInstalled && len(smdp.Downloads()) != 0 {
+				t.Errorf("SM-DP+ released packages %v, want none", smdp.Downloads())
+			}
+			if tt.wantMessage == lpa.ErrDownloadCanceled.Error() && len(smdp.Cancellations()) != 1 {
+				t.Errorf("SM-DP+ cancellations = %+v, want one", smdp.Cancellations())
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package esim
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var errMissingColumn = errors.New("csv must have iccid and nickname columns")
+
+var csvHeader = []string{
+	"eid", "iccid", "nickname", "service_provider_name", "profile_name",
+	"mcc", "mnc", "profile_class", "icon", "downloaded_at", "smdp_address",
+}
+
+// download is when and from where a profile was downloaded, as recorded in the audit log.
+type download struct {
+	at          time.Time
+	smdpAddress string
+}
+
+// Export lists the profiles of a modem's eUICC with everything needed to tell them apart
+// once the card is gone. Download dates and SM-DP+ addresses are only known for profiles
+// downloaded through sigmo, as the eUICC itself does not store them.
+func (s *Service) Export(ctx context.Context, modem *mmodem.Modem) (*ExportResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	eidBytes, err := client.EID()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := client.ListProfile(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	eid := hex.EncodeToString(eidBytes)
+	downloads, err := s.downloads(eid)
+	if err != nil {
+		// The profiles on the card matter more than their download dates.
+		slog.Warn("failed to read downloads from the audit log", "modem", modem.EquipmentIdentifier, "error", err)
+	}
+
+	response := &ExportResponse{
+		EID:        eid,
+		Modem:      modem.EquipmentIdentifier,
+		ExportedAt: time.Now().UTC(),
+		Profiles:   make([]ExportProfileResponse, 0, len(profiles)),
+	}
+	for _, profile := range profiles {
+		exported := ExportProfileResponse{
+			ICCID:               profile.ICCID.String(),
+			Nickname:            profile.ProfileNickname,
+			ServiceProviderName: profile.ServiceProviderName,
+			ProfileName:         profile.ProfileName,
+			MCC:                 profile.ProfileOwner.MCC(),
+			MNC:                 profile.ProfileOwner.MNC(),
+			ProfileClass:        profile.ProfileClass.String(),
+		}
+		if fileType := profile.Icon.FileType(); fileType != "" {
+			exported.Icon = fmt.Sprintf("data:%s;base64,%s", fileType, base64.StdEncoding.EncodeToString(profile.Icon))
+		}
+		if download, ok := downloads[exported.ICCID]; ok {
+			exported.DownloadedAt = &download.at
+			exported.SMDPAddress = download.smdpAddress
+		}
+		response.Profiles = append(response.Profiles, exported)
+	}
+	return response, nil
+}
+
+// downloads returns the latest recorded download of each profile on the eUICC, keyed by ICCID.
+func (s *Service) downloads(eid string) (map[string]download, error) {
+	entries, err := s.audit.Entries()
+	if err != nil {
+		return nil, err
+	}
+	downloads := make(map[string]download)
+	for _, entry := range entries {
+		if entry.Action != auditActionDownload || !strings.EqualFold(entry.EID, eid) {
+			continue
+		}
+		var record downloadAudit
+		if err := json.Unmarshal(entry.Data, &record); err != nil {
+			return nil, fmt.Errorf("decoding download record: %w", err)
+		}
+		downloads[record.ICCID] = download{at: entry.Time, smdpAddress: record.SMDPAddress}
+	}
+	return downloads, nil
+}
+
+// WriteCSV writes the export as CSV with a header row.
+func (r *ExportResponse) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, profile := range r.Profiles {
+		downloadedAt := ""
+		if profile.DownloadedAt != nil {
+			downloadedAt = profile.DownloadedAt.Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			r.EID, profile.ICCID, profile.Nickname, profile.ServiceProviderName, profile.ProfileName,
+			profile.MCC, profile.MNC, profile.ProfileClass, profile.Icon, downloadedAt, profile.SMDPAddress,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// readImportCSV reads the iccid and nickname columns of a CSV export.
+func readImportCSV(r io.Reader) ([]ImportProfileRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	iccidColumn := slices.Index(header, "iccid")
+	nicknameColumn := slices.Index(header, "nickname")
+	if iccidColumn < 0 || nicknameColumn < 0 {
+		return nil, errMissingColumn
+	}
+	var profiles []ImportProfileRequest
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return profiles, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading csv: %w", err)
+		}
+		if len(record) <= max(iccidColumn, nicknameColumn) {
+			return nil, fmt.Errorf("csv line %d has too few columns", len(profiles)+2)
+		}
+		profiles = append(profiles, ImportProfileRequest{
+			ICCID:    strings.TrimSpace(record[iccidColumn]),
+			Nickname: record[nicknameColumn],
+		})
+	}
+}
+
+// Import sets the nicknames in profiles on the matching profiles of a modem's eUICC,
+// typically one replacing a failed card. Entries without a nickname are ignored, and
+// entries for profiles that are not on the eUICC are reported as missing.
+func (s *Service) Import(ctx context.Context, modem *mmodem.Modem, profiles []ImportProfileRequest) (*ImportResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	installed, err := client.ListProfile(nil, nil)
+	if err != nil {
+		slog.Error("failed to list profiles", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	nicknames := make(map[string]string, len(installed))
+	for _, profile := range installed {
+		nicknames[profile.ICCID.String()] = profile.ProfileNickname
+	}
+
+	response := &ImportResponse{
+		Updated:   make([]string, 0),
+		Unchanged: make([]string, 0),
+		Missing:   make([]string, 0),
+		Failed:    make([]ImportFailureResponse, 0),
+	}
+	for _, profile := range profiles {
+		if profile.Nickname == "" {
+			continue
+		}
+		iccid, err := sgp22.NewICCID(profile.ICCID)
+		if err != nil {
+			response.Failed = append(response.Failed, ImportFailureResponse{ICCID: profile.ICCID, Error: err.Error()})
+			continue
+		}
+		current, ok := nicknames[iccid.String()]
+		switch {
+		case !ok:
+			response.Missing = append(response.Missing, iccid.String())
+		case current == profile.Nickname:
+			response.Unchanged = append(response.Unchanged, iccid.String())
+		default:
+			if err := validateNickname(profile.Nickname); err != nil {
+				response.Failed = append(response.Failed, ImportFailureResponse{ICCID: iccid.String(), Error: err.Error()})
+				continue
+			}
+			if err := client.SetNickname(iccid, profile.Nickname); err != nil {
+				slog.Warn("failed to set nickname", "modem", modem.EquipmentIdentifier, "iccid", iccid.String(), "error", err)
+				response.Failed = append(response.Failed, ImportFailureResponse{ICCID: iccid.String(), Error: err.Error()})
+				continue
+			}
+			response.Updated = append(response.Updated, iccid.String())
+		}
+	}
+	return response, nil
+}
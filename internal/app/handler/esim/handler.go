@@ -62,7 +62,7 @@ func (h *Handler) List(c echo.Context) error {
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.List(modem)
+	response, err := h.service.List(c.Request().Context(), modem)
 	if err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
@@ -72,12 +72,20 @@ func (h *Handler) List(c echo.Context) error {
 	return h.Respond(c, response)
 }
 
+func (h *Handler) Inventory(c echo.Context) error {
+	response, err := h.service.Inventory(c.Request().Context(), c.QueryParam("q"))
+	if err != nil {
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
 func (h *Handler) Discover(c echo.Context) error {
 	modem, err := h.FindModem(h.manager, c.Param("id"))
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.Discover(modem)
+	response, err := h.service.Discover(c.Request().Context(), modem)
 	if err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
@@ -122,7 +130,7 @@ func (h *Handler) Delete(c echo.Context) error {
 	if err != nil {
 		return h.BadRequest(c, err)
 	}
-	if err := h.service.Delete(modem, iccid); err != nil {
+	if err := h.service.Delete(c.Request().Context(), modem, iccid); err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
 		}
@@ -155,7 +163,8 @@ func (h *Handler) Download(c echo.Context) error {
 		return nil
 	}
 
-	downloadCtx, cancel := context.WithCancel(context.Background())
+	// The download outlives the upgraded request, but keeps its values such as the operation name.
+	downloadCtx, cancel := context.WithCancel(context.WithoutCancel(c.Request().Context()))
 	defer cancel()
 
 	session := newDownloadSession(conn, cancel)
@@ -208,7 +217,7 @@ func (h *Handler) UpdateNickname(c echo.Context) error {
 	if err := h.BindAndValidate(c, &req); err != nil {
 		return err
 	}
-	if err := h.service.UpdateNickname(modem, iccid, req.Nickname); err != nil {
+	if err := h.service.UpdateNickname(c.Request().Context(), modem, iccid, req.Nickname); err != nil {
 		if errors.Is(err, errInvalidNickname) {
 			return h.BadRequest(c, err)
 		}
@@ -220,6 +229,58 @@ func (h *Handler) UpdateNickname(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// Export sends the profiles of the eUICC as a JSON or, with format=csv, CSV file download.
+func (h *Handler) Export(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return h.BadRequest(c, fmt.Errorf("unsupported format %q, must be json or csv", format))
+	}
+	response, err := h.service.Export(c.Request().Context(), modem)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	filename := fmt.Sprintf("esim-%s-%s", response.EID, response.ExportedAt.Format("20060102T150405Z"))
+	if format == "csv" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().WriteHeader(http.StatusOK)
+		return response.WriteCSV(c.Response())
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".json"))
+	return c.JSONPretty(http.StatusOK, response, "  ")
+}
+
+// Import sets nicknames from a JSON or CSV export on the eUICC.
+func (h *Handler) Import(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req ImportRequest
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		if req.Profiles, err = readImportCSV(c.Request().Body); err != nil {
+			return h.BadRequest(c, err)
+		}
+	} else if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	response, err := h.service.Import(c.Request().Context(), modem, req.Profiles)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
 func iccidFromParam(c echo.Context) (sgp22.ICCID, error) {
 	iccidParam := c.Param("iccid")
 	if iccidParam == "" {
@@ -0,0 +1,168 @@
+package esim
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/carrier"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const (
+	// inventoryConcurrency bounds how many eUICCs are read at the same time.
+	inventoryConcurrency = 4
+	// inventoryModemTimeout bounds how long a single modem may take, including waiting for its lock.
+	inventoryModemTimeout = 30 * time.Second
+)
+
+// Inventory collects the eUICC and profiles of every modem. Modems without an eUICC
+// are left out, and modems that fail are reported in Failures instead of failing the
+// whole inventory. When query is not empty, only profiles whose ICCID, name, service
+// provider or operator contains it are kept, along with the modems holding them.
+func (s *Service) Inventory(ctx context.Context, query string) (*InventoryResponse, error) {
+	modems, err := s.manager.Modems()
+	if err != nil {
+		slog.Error("failed to list modems", "error", err)
+		return nil, err
+	}
+	sorted := make([]*mmodem.Modem, 0, len(modems))
+	for _, modem := range modems {
+		sorted = append(sorted, modem)
+	}
+	slices.SortFunc(sorted, func(a, b *mmodem.Modem) int {
+		return cmp.Compare(a.EquipmentIdentifier, b.EquipmentIdentifier)
+	})
+
+	type result struct {
+		modem *InventoryModemResponse
+		err   error
+	}
+	results := make([]result, len(sorted))
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, inventoryConcurrency)
+	)
+	for i, modem := range sorted {
+		wg.Add(1)
+		go func(i int, modem *mmodem.Modem) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			modemCtx, cancel := context.WithTimeout(ctx, inventoryModemTimeout)
+			defer cancel()
+			results[i].modem, results[i].err = s.collect(modemCtx, modem)
+		}(i, modem)
+	}
+	wg.Wait()
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	response := &InventoryResponse{
+		Modems:   make([]InventoryModemResponse, 0, len(sorted)),
+		Failures: make([]InventoryFailureResponse, 0),
+	}
+	for i, result := range results {
+		modem := sorted[i]
+		if result.err != nil {
+			if errors.Is(result.err, lpa.ErrNoSupportedAID) {
+				continue
+			}
+			slog.Warn("failed to collect eSIM inventory", "modem", modem.EquipmentIdentifier, "error", result.err)
+			response.Failures = append(response.Failures, InventoryFailureResponse{
+				ID:    modem.EquipmentIdentifier,
+				Name:  s.modemName(modem),
+				Error: result.err.Error(),
+			})
+			continue
+		}
+		if query != "" {
+			result.modem.Profiles = slices.DeleteFunc(result.modem.Profiles, func(profile InventoryProfileResponse) bool {
+				return !profile.matches(query)
+			})
+			if len(result.modem.Profiles) == 0 {
+				continue
+			}
+		}
+		response.Modems = append(response.Modems, *result.modem)
+	}
+	return response, nil
+}
+
+// collect reads the EID, free space, profiles and pending notifications of a single modem.
+func (s *Service) collect(ctx context.Context, modem *mmodem.Modem) (*InventoryModemResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	info, err := client.Info()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := client.ListProfile(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := client.ListNotification()
+	if err != nil {
+		return nil, err
+	}
+	response := &InventoryModemResponse{
+		ID:                   modem.EquipmentIdentifier,
+		Name:                 s.modemName(modem),
+		EID:                  info.EID,
+		FreeSpace:            info.FreeSpace,
+		PendingNotifications: len(notifications),
+		Profiles:             make([]InventoryProfileResponse, 0, len(profiles)),
+	}
+	for _, profile := range profiles {
+		name := profile.ProfileNickname
+		if name == "" {
+			name = profile.ProfileName
+		}
+		carrierInfo := carrier.Lookup(profile.ProfileOwner.MCC() + profile.ProfileOwner.MNC())
+		response.Profiles = append(response.Profiles, InventoryProfileResponse{
+			ICCID:               profile.ICCID.String(),
+			Name:                name,
+			ServiceProviderName: profile.ServiceProviderName,
+			OperatorName:        carrierInfo.Name,
+			ProfileState:        uint8(profile.ProfileState),
+			RegionCode:          carrierInfo.Region,
+		})
+	}
+	return response, nil
+}
+
+func (s *Service) modemName(modem *mmodem.Modem) string {
+	if alias := s.cfg.FindModem(modem.EquipmentIdentifier).Alias; alias != "" {
+		return alias
+	}
+	return modem.Model
+}
+
+// matches reports whether query, already lowercased, appears in the profile's ICCID, name,
+// service provider or operator.
+func (p InventoryProfileResponse) matches(query string) bool {
+	for _, field := range []string{p.ICCID, p.Name, p.ServiceProviderName, p.OperatorName} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package esim
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -10,28 +11,44 @@ import (
 
 	elpa "github.com/damonto/euicc-go/lpa"
 	sgp22 "github.com/damonto/euicc-go/v2"
+	"github.com/damonto/sigmo/internal/pkg/audit"
 	"github.com/damonto/sigmo/internal/pkg/carrier"
 	"github.com/damonto/sigmo/internal/pkg/config"
 	"github.com/damonto/sigmo/internal/pkg/lpa"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
 )
 
 type Service struct {
 	cfg     *config.Config
 	manager *mmodem.Manager
+	audit   *audit.Log
 }
 
 var errInvalidNickname = errors.New("nickname must be valid utf-8 and 64 bytes or fewer")
 
+var operations = metrics.NewCounter("sigmo_esim_operations_total", "eSIM profile operations by result.", "operation", "result")
+
+// observe counts an operation that returned *err. Canceled downloads are counted
+// separately, as they are not failures.
+func observe(operation string, err *error) {
+	result := metrics.Result(*err)
+	if errors.Is(*err, lpa.ErrDownloadCanceled) || errors.Is(*err, context.Canceled) {
+		result = "canceled"
+	}
+	operations.Inc(operation, result)
+}
+
 func NewService(cfg *config.Config, manager *mmodem.Manager) *Service {
 	return &Service{
 		cfg:     cfg,
 		manager: manager,
+		audit:   audit.New(cfg.DataPath(audit.FileName)),
 	}
 }
 
-func (s *Service) List(modem *mmodem.Modem) ([]ProfileResponse, error) {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) List(ctx context.Context, modem *mmodem.Modem) ([]ProfileResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return nil, err
@@ -72,8 +89,8 @@ func (s *Service) List(modem *mmodem.Modem) ([]ProfileResponse, error) {
 	return response, nil
 }
 
-func (s *Service) Discover(modem *mmodem.Modem) ([]DiscoverResponse, error) {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Discover(ctx context.Context, modem *mmodem.Modem) (*DiscoverResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return nil, err
@@ -95,24 +112,45 @@ func (s *Service) Discover(modem *mmodem.Modem) ([]DiscoverResponse, error) {
 		return nil, err
 	}
 
-	entries, err := client.Discover(imei)
-	if err != nil {
-		slog.Error("failed to discover profiles", "modem", modem.EquipmentIdentifier, "error", err)
-		return nil, err
+	results := client.Discover(imei, s.cfg.SMDSServers())
+	response := &DiscoverResponse{
+		Entries: make([]DiscoverEntryResponse, 0),
+		Servers: make([]DiscoverServerResponse, 0, len(results)),
 	}
-
-	response := make([]DiscoverResponse, 0, len(entries))
-	for _, entry := range entries {
-		response = append(response, DiscoverResponse{
-			EventID: entry.EventID,
-			Address: entry.Address,
-		})
+	var (
+		errs   error
+		failed int
+	)
+	for _, result := range results {
+		server := DiscoverServerResponse{
+			Address: result.Address,
+			Count:   len(result.Entries),
+		}
+		if result.Err != nil {
+			slog.Warn("failed to discover profiles", "modem", modem.EquipmentIdentifier, "address", result.Address, "error", result.Err)
+			server.Error = result.Err.Error()
+			failed++
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", result.Address, result.Err))
+		}
+		response.Servers = append(response.Servers, server)
+		for _, entry := range result.Entries {
+			response.Entries = append(response.Entries, DiscoverEntryResponse{
+				EventID: entry.EventID,
+				Address: entry.Address,
+				Server:  result.Address,
+			})
+		}
+	}
+	if failed > 0 && failed == len(results) {
+		slog.Error("failed to discover profiles", "modem", modem.EquipmentIdentifier, "error", errs)
+		return nil, errs
 	}
 	return response, nil
 }
 
-func (s *Service) Enable(ctx context.Context, modem *mmodem.Modem, iccid sgp22.ICCID) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Enable(ctx context.Context, modem *mmodem.Modem, iccid sgp22.ICCID) (err error) {
+	defer observe("enable", &err)
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -143,6 +181,8 @@ func (s *Service) Enable(ctx context.Context, modem *mmodem.Modem, iccid sgp22.I
 		return err
 	}
 
+	// Refreshing the eUICC resets the channel, so the session cannot be reused.
+	lpa.Invalidate(modem.EquipmentIdentifier)
 	closeClient()
 
 	if err := modem.Restart(s.cfg.FindModem(modem.EquipmentIdentifier).Compatible); err != nil {
@@ -155,14 +195,15 @@ func (s *Service) Enable(ctx context.Context, modem *mmodem.Modem, iccid sgp22.I
 		slog.Error("failed to wait for modem", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
-	if err := s.sendPendingNotifications(target, lastSeq); err != nil {
+	if err := s.sendPendingNotifications(ctx, target, lastSeq); err != nil {
 		slog.Warn("failed to handle modem notifications", "error", err, "modem", modem.EquipmentIdentifier)
 	}
 	return nil
 }
 
-func (s *Service) Delete(modem *mmodem.Modem, iccid sgp22.ICCID) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Delete(ctx context.Context, modem *mmodem.Modem, iccid sgp22.ICCID) (err error) {
+	defer observe("delete", &err)
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -180,8 +221,9 @@ func (s *Service) Delete(modem *mmodem.Modem, iccid sgp22.ICCID) error {
 	return nil
 }
 
-func (s *Service) Download(ctx context.Context, modem *mmodem.Modem, activationCode *elpa.ActivationCode, opts *elpa.DownloadOptions) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Download(ctx context.Context, modem *mmodem.Modem, activationCode *elpa.ActivationCode, opts *elpa.DownloadOptions) (err error) {
+	defer observe("download", &err)
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -192,18 +234,36 @@ func (s *Service) Download(ctx context.Context, modem *mmodem.Modem, activationC
 		}
 	}()
 
-	if err := client.Download(ctx, activationCode, opts); err != nil {
+	eid, err := client.EID()
+	if err != nil {
+		slog.Error("failed to read EID", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	iccid, err := client.Download(ctx, activationCode, opts)
+	// The profile may be installed even if its notification could not be sent.
+	if len(iccid) > 0 {
+		record := downloadAudit{ICCID: iccid.String(), SMDPAddress: activationCode.SMDP.Host}
+		if aerr := s.audit.Record(auditActionDownload, modem.EquipmentIdentifier, hex.EncodeToString(eid), record); aerr != nil {
+			slog.Warn("failed to record profile download", "modem", modem.EquipmentIdentifier, "iccid", record.ICCID, "error", aerr)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, lpa.ErrDownloadCanceled) {
+			slog.Info("profile download canceled", "modem", modem.EquipmentIdentifier)
+			return err
+		}
 		slog.Error("failed to download profile", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
 	return nil
 }
 
-func (s *Service) UpdateNickname(modem *mmodem.Modem, iccid sgp22.ICCID, nickname string) error {
+func (s *Service) UpdateNickname(ctx context.Context, modem *mmodem.Modem, iccid sgp22.ICCID, nickname string) (err error) {
+	defer observe("nickname", &err)
 	if err := validateNickname(nickname); err != nil {
 		return err
 	}
-	client, err := lpa.New(modem, s.cfg)
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -231,8 +291,8 @@ func validateNickname(nickname string) error {
 	return nil
 }
 
-func (s *Service) sendPendingNotifications(modem *mmodem.Modem, lastSeq sgp22.SequenceNumber) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) sendPendingNotifications(ctx context.Context, modem *mmodem.Modem, lastSeq sgp22.SequenceNumber) error {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -0,0 +1,156 @@
+package esim
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/fakecard"
+	"github.com/damonto/sigmo/internal/pkg/fakemm"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const (
+	testEID    = "89049032000000000000000000000001"
+	testICCID1 = "89860000000000000011"
+	testICCID2 = "89860000000000000029"
+)
+
+var bus *fakemm.Bus
+
+func TestMain(m *testing.M) {
+	bus = fakemm.NewBus()
+	code := m.Run()
+	bus.Close()
+	os.Exit(code)
+}
+
+// setup adds a modem to the fake ModemManager with a fake card holding profiles,
+// and returns a service, a context that routes the modem's LPA clients to the card,
+// the card and the modem.
+func setup(t *testing.T, profiles ...fakecard.Profile) (*Service, context.Context, *fakecard.Card, *mmodem.Modem) {
+	t.Helper()
+	_, modem := bus.AddModem(t, fakemm.ModemConfig{
+		SIMs: []fakemm.SIMConfig{{Identifier: testICCID1, EID: testEID}},
+	})
+	t.Cleanup(func() { lpa.Invalidate(modem.EquipmentIdentifier) })
+	card, err := fakecard.New(testEID, profiles...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	cfg := &config.Config{App: config.App{DataDir: t.TempDir()}}
+	return NewService(cfg, bus.Manager), lpa.WithChannel(ctx, card), card, modem
+}
+
+func iccid(t *testing.T, value string) sgp22.ICCID {
+	t.Helper()
+	id, err := sgp22.NewICCID(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func enabled(card *fakecard.Card) string {
+	for _, profile := range card.Profiles() {
+		if profile.Enabled {
+			return profile.ICCID
+		}
+	}
+	return ""
+}
+
+func TestList(t *testing.T) {
+	service, ctx, _, modem := setup(t,
+		fakecard.Profile{ICCID: testICCID1, Enabled: true, ProfileName: "Home", Nickname: "Daily", ServiceProviderName: "Operator A"},
+		fakecard.Profile{ICCID: testICCID2, ProfileName: "Travel", ServiceProviderName: "Operator B"},
+	)
+	profiles, err := service.List(ctx, modem)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []ProfileResponse{
+		{Name: "Daily", ServiceProviderName: "Operator A", ICCID: testICCID1, ProfileState: uint8(sgp22.ProfileEnabled)},
+		{Name: "Travel", ServiceProviderName: "Operator B", ICCID: testICCID2, ProfileState: uint8(sgp22.ProfileDisabled)},
+	}
+	if len(profiles) != len(want) {
+		t.Fatalf("List() returned %d profiles, want %d", len(profiles), len(want))
+	}
+	for i, profile := range profiles {
+		got := ProfileResponse{Name: profile.Name, ServiceProviderName: profile.ServiceProviderName, ICCID: profile.ICCID, ProfileState: profile.ProfileState}
+		if got != want[i] {
+			t.Errorf("List()[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestEnable(t *testing.T) {
+	service, ctx, card, modem := setup(t,
+		fakecard.Profile{ICCID: testICCID1, Enabled: true},
+		fakecard.Profile{ICCID: testICCID2},
+	)
+	if err := service.Enable(ctx, modem, iccid(t, testICCID2)); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if got := enabled(card); got != testICCID2 {
+		t.Errorf("enabled profile = %q, want %q", got, testICCID2)
+	}
+	// The modem was restarted; the service must still work with it.
+	profiles, err := service.List(ctx, bus.WaitModem(t, modem.EquipmentIdentifier))
+	if err != nil {
+		t.Fatalf("List() after Enable() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[1].ProfileState != uint8(sgp22.ProfileEnabled) {
+		t.Errorf("List() after Enable() = %+v, want %s enabled", profiles, testICCID2)
+	}
+}
+
+func TestEnableEnabledProfile(t *testing.T) {
+	service, ctx, card, modem := setup(t, fakecard.Profile{ICCID: testICCID1, Enabled: true})
+	if err := service.Enable(ctx, modem, iccid(t, testICCID1)); err == nil {
+		t.Error("Enable() of the enabled profile = nil error, want an error")
+	}
+	if got := enabled(card); got != testICCID1 {
+		t.Errorf("enabled profile = %q, want %q", got, testICCID1)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		iccid   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "disabled profile", iccid: testICCID2, want: []string{testICCID1}},
+		{name: "enabled profile", iccid: testICCID1, want: []string{testICCID1, testICCID2}, wantErr: true},
+		{name: "unknown profile", iccid: "89860000000000000037", want: []string{testICCID1, testICCID2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, ctx, card, modem := setup(t,
+				fakecard.Profile{ICCID: testICCID1, Enabled: true},
+				fakecard.Profile{ICCID: testICCID2},
+			)
+			err := service.Delete(ctx, modem, iccid(t, tt.iccid))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, profile := range card.Profiles() {
+				got = append(got, profile.ICCID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("profiles after Delete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
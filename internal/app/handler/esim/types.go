@@ -1,5 +1,7 @@
 package esim
 
+import "time"
+
 type ProfileResponse struct {
 	Name                string `json:"name"`
 	ServiceProviderName string `json:"serviceProviderName"`
@@ -10,19 +12,110 @@ type ProfileResponse struct {
 }
 
 type DiscoverResponse struct {
+	Entries []DiscoverEntryResponse  `json:"entries"`
+	Servers []DiscoverServerResponse `json:"servers"`
+}
+
+type DiscoverEntryResponse struct {
 	EventID string `json:"eventId"`
 	Address string `json:"address"`
+	Server  string `json:"server"`
+}
+
+type DiscoverServerResponse struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+	Error   string `json:"error,omitempty"`
+}
+
+type InventoryResponse struct {
+	Modems   []InventoryModemResponse   `json:"modems"`
+	Failures []InventoryFailureResponse `json:"failures"`
+}
+
+type InventoryModemResponse struct {
+	ID                   string                     `json:"id"`
+	Name                 string                     `json:"name"`
+	EID                  string                     `json:"eid"`
+	FreeSpace            int32                      `json:"freeSpace"`
+	PendingNotifications int                        `json:"pendingNotifications"`
+	Profiles             []InventoryProfileResponse `json:"profiles"`
+}
+
+type InventoryProfileResponse struct {
+	ICCID               string `json:"iccid"`
+	Name                string `json:"name"`
+	ServiceProviderName string `json:"serviceProviderName"`
+	OperatorName        string `json:"operatorName"`
+	ProfileState        uint8  `json:"profileState"`
+	RegionCode          string `json:"regionCode,omitempty"`
+}
+
+type InventoryFailureResponse struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+type ExportResponse struct {
+	EID        string                  `json:"eid"`
+	Modem      string                  `json:"modem"`
+	ExportedAt time.Time               `json:"exportedAt"`
+	Profiles   []ExportProfileResponse `json:"profiles"`
+}
+
+type ExportProfileResponse struct {
+	ICCID               string     `json:"iccid"`
+	Nickname            string     `json:"nickname"`
+	ServiceProviderName string     `json:"serviceProviderName"`
+	ProfileName         string     `json:"profileName"`
+	MCC                 string     `json:"mcc"`
+	MNC                 string     `json:"mnc"`
+	ProfileClass        string     `json:"profileClass"`
+	Icon                string     `json:"icon,omitempty"`
+	DownloadedAt        *time.Time `json:"downloadedAt,omitempty"`
+	SMDPAddress         string     `json:"smdpAddress,omitempty"`
+}
+
+// ImportRequest accepts an export, so an export of a failed eUICC can be uploaded as is.
+type ImportRequest struct {
+	Profiles []ImportProfileRequest `json:"profiles" validate:"required,dive"`
+}
+
+type ImportProfileRequest struct {
+	ICCID    string `json:"iccid" validate:"required"`
+	Nickname string `json:"nickname"`
+}
+
+type ImportResponse struct {
+	Updated   []string                `json:"updated"`
+	Unchanged []string                `json:"unchanged"`
+	Missing   []string                `json:"missing"`
+	Failed    []ImportFailureResponse `json:"failed"`
+}
+
+type ImportFailureResponse struct {
+	ICCID string `json:"iccid"`
+	Error string `json:"error"`
 }
 
 type UpdateNicknameRequest struct {
 	Nickname string `json:"nickname"`
 }
 
+const auditActionDownload = "esim.download"
+
+type downloadAudit struct {
+	ICCID       string `json:"iccid"`
+	SMDPAddress string `json:"smdpAddress"`
+}
+
 type downloadClientMessage struct {
 	Type             string `json:"type"`
 	SMDP             string `json:"smdp,omitempty"`
 	ActivationCode   string `json:"activationCode,omitempty"`
 	ConfirmationCode string `json:"confirmationCode,omitempty"`
+	EventID          string `json:"eventId,omitempty"`
 	Accept           *bool  `json:"accept,omitempty"`
 	Code             string `json:"code,omitempty"`
 }
@@ -2,10 +2,13 @@ package euicc
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/pkg/audit"
 	"github.com/damonto/sigmo/internal/pkg/config"
 	"github.com/damonto/sigmo/internal/pkg/lpa"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
@@ -18,11 +21,11 @@ type Handler struct {
 	service *Service
 }
 
-func New(cfg *config.Config, manager *mmodem.Manager) *Handler {
+func New(cfg *config.Config, manager *mmodem.Manager, auditLog *audit.Log) *Handler {
 	return &Handler{
 		cfg:     cfg,
 		manager: manager,
-		service: NewService(cfg),
+		service: NewService(cfg, auditLog),
 	}
 }
 
@@ -31,7 +34,7 @@ func (h *Handler) Get(c echo.Context) error {
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.Get(modem)
+	response, err := h.service.Get(c.Request().Context(), modem)
 	if err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
@@ -40,3 +43,98 @@ func (h *Handler) Get(c echo.Context) error {
 	}
 	return h.Respond(c, response)
 }
+
+func (h *Handler) GetDetails(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.GetDetails(c.Request().Context(), modem)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) GetAddresses(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.GetAddresses(c.Request().Context(), modem)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) UpdateAddresses(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req UpdateAddressesRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.service.UpdateDefaultSMDPAddress(c.Request().Context(), modem, req.DefaultSMDPAddress); err != nil {
+		if errors.Is(err, errInvalidAddress) {
+			return h.BadRequest(c, err)
+		}
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) ResetMemory(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req MemoryResetRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.service.ResetMemory(c.Request().Context(), modem, req); err != nil {
+		if errors.Is(err, errNoResetOption) || errors.Is(err, errConfirmationMismatch) {
+			return h.BadRequest(c, err)
+		}
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return h.NotFound(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) ListTraces(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	return h.Respond(c, h.service.ListTraces(modem))
+}
+
+// GetTrace sends a recorded APDU trace as a JSON file download.
+func (h *Handler) GetTrace(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.GetTrace(modem, c.Param("traceId"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	filename := fmt.Sprintf("apdu-trace-%s-%s.json", modem.EquipmentIdentifier, response.ID)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.JSONPretty(http.StatusOK, response, "  ")
+}
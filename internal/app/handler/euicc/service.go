@@ -1,26 +1,43 @@
 package euicc
 
 import (
+	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/pkg/audit"
 	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/euicc"
 	"github.com/damonto/sigmo/internal/pkg/lpa"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
 )
 
+var (
+	errInvalidAddress       = errors.New("invalid SM-DP+ address")
+	errNoResetOption        = errors.New("at least one reset option must be selected")
+	errConfirmationMismatch = errors.New("confirmation must match the EID of the eUICC")
+	errTraceNotFound        = errors.New("trace not found")
+)
+
 type Service struct {
-	cfg *config.Config
+	cfg   *config.Config
+	audit *audit.Log
 }
 
-func NewService(cfg *config.Config) *Service {
+func NewService(cfg *config.Config, auditLog *audit.Log) *Service {
 	return &Service{
-		cfg: cfg,
+		cfg:   cfg,
+		audit: auditLog,
 	}
 }
 
-func (s *Service) Get(modem *mmodem.Modem) (*EuiccResponse, error) {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Get(ctx context.Context, modem *mmodem.Modem) (*EuiccResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return nil, err
@@ -39,10 +56,281 @@ func (s *Service) Get(modem *mmodem.Modem) (*EuiccResponse, error) {
 		slog.Error("failed to fetch eUICC info", "modem", modem.EquipmentIdentifier, "error", err)
 		return nil, err
 	}
-	return &EuiccResponse{
+	response := &EuiccResponse{
 		EID:          info.EID,
 		FreeSpace:    info.FreeSpace,
 		SASUP:        info.SASUP,
 		Certificates: info.Certificates,
+	}
+	// Not every eUICC implements ES10a, so missing addresses should not hide the rest.
+	addresses, err := client.EUICCConfiguredAddresses()
+	if err != nil {
+		slog.Warn("failed to fetch eUICC configured addresses", "modem", modem.EquipmentIdentifier, "error", err)
+		return response, nil
+	}
+	response.DefaultSMDPAddress = addresses.DefaultSMDPAddress
+	response.RootSMDSAddress = addresses.RootSMDSAddress
+	return response, nil
+}
+
+func (s *Service) GetDetails(ctx context.Context, modem *mmodem.Modem) (*DetailsResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return nil, err
+		}
+		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	details, err := client.Details()
+	if err != nil {
+		slog.Error("failed to fetch eUICC details", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	response := &DetailsResponse{
+		EID: details.EID,
+		Info1: Info1Response{
+			SVN:                    details.Info1.SVN,
+			CIPKIDsForVerification: certificatesFrom(details.Info1.CIPKIDsForVerification),
+			CIPKIDsForSigning:      certificatesFrom(details.Info1.CIPKIDsForSigning),
+		},
+		ProfileVersion:              details.ProfileVersion,
+		SVN:                         details.SVN,
+		FirmwareVersion:             details.FirmwareVersion,
+		TS102241Version:             details.TS102241Version,
+		GlobalPlatformVersion:       details.GlobalPlatformVersion,
+		PPVersion:                   details.PPVersion,
+		InstalledApplications:       details.InstalledApplications,
+		FreeNonVolatileMemory:       details.FreeNonVolatileMemory,
+		FreeVolatileMemory:          details.FreeVolatileMemory,
+		UICCCapabilities:            nonNil(details.UICCCapabilities),
+		RSPCapabilities:             nonNil(details.RSPCapabilities),
+		Category:                    details.Category,
+		ForbiddenProfilePolicyRules: nonNil(details.ForbiddenProfilePolicyRules),
+		SASAccreditationNumber:      details.SASAccreditationNumber,
+		SASUP:                       euicc.LookupSASUP(details.EID, details.SASAccreditationNumber),
+		CIPKIDsForVerification:      certificatesFrom(details.CIPKIDsForVerification),
+		CIPKIDsForSigning:           certificatesFrom(details.CIPKIDsForSigning),
+	}
+	if details.CertificationData != nil {
+		response.CertificationData = &CertificationDataResponse{
+			PlatformLabel:    details.CertificationData.PlatformLabel,
+			DiscoveryBaseURL: details.CertificationData.DiscoveryBaseURL,
+		}
+	}
+	if eid, err := euicc.DecodeEID(details.EID); err == nil {
+		response.EIDInfo = &EIDInfoResponse{
+			CountryCode:      eid.CountryCode,
+			IssuerID:         eid.IssuerID,
+			IssuerSpecific:   eid.IssuerSpecific,
+			CheckDigitsValid: eid.CheckDigitsValid,
+			EUM:              eid.EUM,
+			EUMRegion:        eid.EUMRegion,
+		}
+	} else {
+		slog.Warn("failed to decode EID", "modem", modem.EquipmentIdentifier, "eid", details.EID, "error", err)
+	}
+	return response, nil
+}
+
+func certificatesFrom(keyIDs []string) []CertificateResponse {
+	certificates := make([]CertificateResponse, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		certificates = append(certificates, CertificateResponse{
+			KeyID:  keyID,
+			Issuer: euicc.LookupCertificateIssuer(keyID),
+		})
+	}
+	return certificates
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func (s *Service) GetAddresses(ctx context.Context, modem *mmodem.Modem) (*AddressesResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return nil, err
+		}
+		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	addresses, err := client.EUICCConfiguredAddresses()
+	if err != nil {
+		slog.Error("failed to fetch eUICC configured addresses", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	return &AddressesResponse{
+		DefaultSMDPAddress: addresses.DefaultSMDPAddress,
+		RootSMDSAddress:    addresses.RootSMDSAddress,
 	}, nil
 }
+
+func (s *Service) UpdateDefaultSMDPAddress(ctx context.Context, modem *mmodem.Modem, address string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return err
+	}
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return err
+		}
+		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	if err := client.SetDefaultDPAddress(address); err != nil {
+		slog.Error("failed to set default SM-DP+ address", "modem", modem.EquipmentIdentifier, "address", address, "error", err)
+		return err
+	}
+	return nil
+}
+
+// normalizeAddress reduces an SM-DP+ address to the bare host the eUICC stores.
+// An empty address clears the default SM-DP+ address.
+func normalizeAddress(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	parsed, err := lpa.ParseAddress(raw)
+	if err != nil || strings.Trim(parsed.Path, "/") != "" {
+		return "", fmt.Errorf("%w: %q", errInvalidAddress, raw)
+	}
+	return parsed.Host, nil
+}
+
+func (s *Service) ResetMemory(ctx context.Context, modem *mmodem.Modem, req MemoryResetRequest) error {
+	opts := lpa.MemoryResetOptions{
+		DeleteOperationalProfiles: req.DeleteOperationalProfiles,
+		DeleteTestProfiles:        req.DeleteTestProfiles,
+		ResetDefaultSMDPAddress:   req.ResetDefaultSMDPAddress,
+	}
+	if !opts.DeleteOperationalProfiles && !opts.DeleteTestProfiles && !opts.ResetDefaultSMDPAddress {
+		return errNoResetOption
+	}
+	client, err := lpa.New(ctx, modem, s.cfg)
+	if err != nil {
+		if errors.Is(err, lpa.ErrNoSupportedAID) {
+			return err
+		}
+		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+
+	eidBytes, err := client.EID()
+	if err != nil {
+		slog.Error("failed to read EID", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	eid := hex.EncodeToString(eidBytes)
+	if !strings.EqualFold(strings.TrimSpace(req.Confirmation), eid) {
+		return errConfirmationMismatch
+	}
+
+	profiles, err := client.ListProfile(nil, nil)
+	if err != nil {
+		slog.Error("failed to list profiles", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	snapshot := memoryResetAudit{
+		Options:  req,
+		Profiles: make([]auditProfile, 0, len(profiles)),
+	}
+	for _, profile := range profiles {
+		snapshot.Profiles = append(snapshot.Profiles, auditProfile{
+			ICCID:               profile.ICCID.String(),
+			ProfileName:         profile.ProfileName,
+			ProfileNickname:     profile.ProfileNickname,
+			ServiceProviderName: profile.ServiceProviderName,
+			ProfileState:        profile.ProfileState.String(),
+			ProfileClass:        profile.ProfileClass.String(),
+			ProfileOwner:        profile.ProfileOwner.MCC() + profile.ProfileOwner.MNC(),
+		})
+	}
+	// The profile list is the only record left once the reset succeeds, so refuse to reset without it.
+	if err := s.audit.Record(auditActionMemoryReset, modem.EquipmentIdentifier, eid, snapshot); err != nil {
+		slog.Error("failed to record memory reset", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+
+	slog.Info("resetting eUICC memory", "modem", modem.EquipmentIdentifier, "eid", eid, "profiles", len(profiles))
+	if err := client.ResetMemory(opts); err != nil {
+		if errors.Is(err, sgp22.ErrNothingToDelete) {
+			// An empty card is already what the reset asked for.
+			slog.Info("eUICC memory is already empty", "modem", modem.EquipmentIdentifier, "eid", eid)
+			return nil
+		}
+		slog.Error("failed to reset eUICC memory", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Service) ListTraces(modem *mmodem.Modem) []TraceSummaryResponse {
+	traces := lpa.Traces(modem.EquipmentIdentifier)
+	response := make([]TraceSummaryResponse, 0, len(traces))
+	for _, trace := range traces {
+		response = append(response, traceSummaryFrom(&trace))
+	}
+	return response
+}
+
+func (s *Service) GetTrace(modem *mmodem.Modem, id string) (*TraceResponse, error) {
+	trace, ok := lpa.FindTrace(modem.EquipmentIdentifier, id)
+	if !ok {
+		return nil, errTraceNotFound
+	}
+	response := &TraceResponse{
+		TraceSummaryResponse: traceSummaryFrom(trace),
+		Modem:                trace.Modem,
+		Exchanges:            make([]ExchangeResponse, 0, len(trace.Exchanges)),
+	}
+	for _, exchange := range trace.Exchanges {
+		response.Exchanges = append(response.Exchanges, ExchangeResponse{
+			Time:       exchange.Time,
+			DurationMs: float64(exchange.Duration.Microseconds()) / 1000,
+			Kind:       exchange.Kind,
+			Command:    exchange.Command,
+			Response:   exchange.Response,
+			Error:      exchange.Error,
+		})
+	}
+	return response, nil
+}
+
+func traceSummaryFrom(trace *lpa.Trace) TraceSummaryResponse {
+	return TraceSummaryResponse{
+		ID:        trace.ID,
+		Operation: trace.Operation,
+		StartedAt: trace.StartedAt,
+		EndedAt:   trace.EndedAt,
+	}
+}
@@ -1,8 +1,117 @@
 package euicc
 
+import "time"
+
 type EuiccResponse struct {
 	EID          string   `json:"eid"`
 	FreeSpace    int32    `json:"freeSpace"`
 	SASUP        string   `json:"sasUp"`
 	Certificates []string `json:"certificates"`
+
+	DefaultSMDPAddress string `json:"defaultSmdpAddress"`
+	RootSMDSAddress    string `json:"rootSmdsAddress"`
+}
+
+type DetailsResponse struct {
+	EID                         string                     `json:"eid"`
+	EIDInfo                     *EIDInfoResponse           `json:"eidInfo,omitempty"`
+	Info1                       Info1Response              `json:"info1"`
+	ProfileVersion              string                     `json:"profileVersion"`
+	SVN                         string                     `json:"svn"`
+	FirmwareVersion             string                     `json:"firmwareVersion"`
+	TS102241Version             string                     `json:"ts102241Version,omitempty"`
+	GlobalPlatformVersion       string                     `json:"globalPlatformVersion,omitempty"`
+	PPVersion                   string                     `json:"ppVersion"`
+	InstalledApplications       int64                      `json:"installedApplications"`
+	FreeNonVolatileMemory       int64                      `json:"freeNonVolatileMemory"`
+	FreeVolatileMemory          int64                      `json:"freeVolatileMemory"`
+	UICCCapabilities            []string                   `json:"uiccCapabilities"`
+	RSPCapabilities             []string                   `json:"rspCapabilities"`
+	Category                    string                     `json:"category,omitempty"`
+	ForbiddenProfilePolicyRules []string                   `json:"forbiddenProfilePolicyRules"`
+	SASAccreditationNumber      string                     `json:"sasAccreditationNumber"`
+	SASUP                       string                     `json:"sasUp"`
+	CertificationData           *CertificationDataResponse `json:"certificationData,omitempty"`
+	CIPKIDsForVerification      []CertificateResponse      `json:"ciPkIdsForVerification"`
+	CIPKIDsForSigning           []CertificateResponse      `json:"ciPkIdsForSigning"`
+}
+
+type EIDInfoResponse struct {
+	CountryCode      string `json:"countryCode"`
+	IssuerID         string `json:"issuerId"`
+	IssuerSpecific   string `json:"issuerSpecific"`
+	CheckDigitsValid bool   `json:"checkDigitsValid"`
+	EUM              string `json:"eum,omitempty"`
+	EUMRegion        string `json:"eumRegion,omitempty"`
+}
+
+type Info1Response struct {
+	SVN                    string                `json:"svn"`
+	CIPKIDsForVerification []CertificateResponse `json:"ciPkIdsForVerification"`
+	CIPKIDsForSigning      []CertificateResponse `json:"ciPkIdsForSigning"`
+}
+
+type CertificationDataResponse struct {
+	PlatformLabel    string `json:"platformLabel"`
+	DiscoveryBaseURL string `json:"discoveryBaseUrl"`
+}
+
+type CertificateResponse struct {
+	KeyID  string `json:"keyId"`
+	Issuer string `json:"issuer"`
+}
+
+type AddressesResponse struct {
+	DefaultSMDPAddress string `json:"defaultSmdpAddress"`
+	RootSMDSAddress    string `json:"rootSmdsAddress"`
+}
+
+type UpdateAddressesRequest struct {
+	DefaultSMDPAddress string `json:"defaultSmdpAddress" validate:"max=255"`
+}
+
+type MemoryResetRequest struct {
+	DeleteOperationalProfiles bool   `json:"deleteOperationalProfiles"`
+	DeleteTestProfiles        bool   `json:"deleteTestProfiles"`
+	ResetDefaultSMDPAddress   bool   `json:"resetDefaultSmdpAddress"`
+	Confirmation              string `json:"confirmation" validate:"required"`
+}
+
+const auditActionMemoryReset = "euicc.memory_reset"
+
+type memoryResetAudit struct {
+	Options  MemoryResetRequest `json:"options"`
+	Profiles []auditProfile     `json:"profiles"`
+}
+
+type auditProfile struct {
+	ICCID               string `json:"iccid"`
+	ProfileName         string `json:"profileName"`
+	ProfileNickname     string `json:"profileNickname,omitempty"`
+	ServiceProviderName string `json:"serviceProviderName"`
+	ProfileState        string `json:"profileState"`
+	ProfileClass        string `json:"profileClass"`
+	ProfileOwner        string `json:"profileOwner"`
+}
+
+type TraceSummaryResponse struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+}
+
+type TraceResponse struct {
+	TraceSummaryResponse
+	Modem     string             `json:"modem"`
+	Exchanges []ExchangeResponse `json:"exchanges"`
+}
+
+type ExchangeResponse struct {
+	Time       time.Time `json:"time"`
+	DurationMs float64   `json:"durationMs"`
+	Kind       string    `json:"kind"`
+	Command    string    `json:"command,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
 }
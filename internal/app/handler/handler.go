@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/damonto/sigmo/internal/pkg/keymutex"
 	"github.com/damonto/sigmo/internal/pkg/modem"
 	"github.com/labstack/echo/v4"
 )
@@ -53,7 +55,17 @@ func (*Handler) Conflict(c echo.Context, err error) error {
 	return c.JSON(http.StatusConflict, HTTPError{Code: http.StatusConflict, Message: err.Error()})
 }
 
-func (*Handler) InternalServerError(c echo.Context, err error) error {
+func (*Handler) Locked(c echo.Context, err error) error {
+	return c.JSON(http.StatusLocked, HTTPError{Code: http.StatusLocked, Message: err.Error()})
+}
+
+// InternalServerError reports a failed operation. Errors caused by another
+// operation holding the eUICC are reported as 423 Locked instead.
+func (h *Handler) InternalServerError(c echo.Context, err error) error {
+	var busy *keymutex.BusyError
+	if errors.As(err, &busy) {
+		return h.Locked(c, err)
+	}
 	return c.JSON(http.StatusInternalServerError, HTTPError{Code: http.StatusInternalServerError, Message: err.Error()})
 }
 
@@ -0,0 +1,36 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/forwarder"
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+type Handler struct {
+	handler.Handler
+	service *Service
+}
+
+func New(cfg *config.Config, manager *mmodem.Manager, registry *mmodem.Registry, relay *forwarder.Relay) *Handler {
+	return &Handler{service: NewService(cfg, manager, registry, relay)}
+}
+
+// Health reports that the process is up and serving requests.
+func (h *Handler) Health(c echo.Context) error {
+	return h.Respond(c, HealthResponse{Status: statusOK})
+}
+
+// Ready reports whether sigmo can reach its dependencies, with 503 Service
+// Unavailable if it cannot.
+func (h *Handler) Ready(c echo.Context) error {
+	response, ready := h.service.Readiness(c.Request().Context())
+	if !ready {
+		return c.JSON(http.StatusServiceUnavailable, handler.DataResponse{Data: response})
+	}
+	return h.Respond(c, response)
+}
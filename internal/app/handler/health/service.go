@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/damonto/sigmo/internal/app/forwarder"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	statusDisabled    = "disabled"
+
+	// pingTimeout keeps a hung ModemManager from stalling frequent probes.
+	pingTimeout = 2 * time.Second
+)
+
+var (
+	errDBusDisconnected = errors.New("system bus connection closed")
+	errRelayStopped     = errors.New("message relay is not running")
+)
+
+type Service struct {
+	cfg      *config.Config
+	manager  *mmodem.Manager
+	registry *mmodem.Registry
+	relay    *forwarder.Relay
+}
+
+func NewService(cfg *config.Config, manager *mmodem.Manager, registry *mmodem.Registry, relay *forwarder.Relay) *Service {
+	return &Service{cfg: cfg, manager: manager, registry: registry, relay: relay}
+}
+
+// Readiness checks the services sigmo depends on. The modem summary only uses
+// the cached modems, so it costs no D-Bus calls; modems do not affect readiness.
+func (s *Service) Readiness(ctx context.Context) (*ReadinessResponse, bool) {
+	response := &ReadinessResponse{
+		Status: statusOK,
+		Checks: map[string]CheckResult{
+			"dbus":         s.checkDBus(),
+			"modemManager": s.checkModemManager(ctx),
+			"relay":        s.checkRelay(),
+		},
+		Modems: s.modems(),
+	}
+	for _, check := range response.Checks {
+		if check.Status == statusUnavailable {
+			response.Status = statusUnavailable
+		}
+	}
+	return response, response.Status == statusOK
+}
+
+func (s *Service) checkDBus() CheckResult {
+	if !s.manager.Connected() {
+		return failed(errDBusDisconnected)
+	}
+	return CheckResult{Status: statusOK}
+}
+
+func (s *Service) checkModemManager(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := s.manager.Ping(ctx); err != nil {
+		return failed(err)
+	}
+	return CheckResult{Status: statusOK}
+}
+
+func (s *Service) checkRelay() CheckResult {
+	if !s.relay.Enabled() {
+		return CheckResult{Status: statusDisabled}
+	}
+	if !s.relay.Running() {
+		return failed(errRelayStopped)
+	}
+	return CheckResult{Status: statusOK}
+}
+
+// modems summarizes every known modem. /readyz is not authenticated, so the
+// summary leaves out identifiers such as the IMEI and ICCID.
+func (s *Service) modems() []ModemStatusResponse {
+	statuses := make(map[string]ModemStatusResponse)
+	for _, known := range s.registry.Known() {
+		statuses[known.EquipmentIdentifier] = ModemStatusResponse{
+			Name: s.name(known.EquipmentIdentifier, known.Model),
+		}
+	}
+	// Without ModemManager every modem is reported as disconnected.
+	if modems, err := s.manager.Modems(); err == nil {
+		for _, m := range modems {
+			statuses[m.EquipmentIdentifier] = ModemStatusResponse{
+				Name:      s.name(m.EquipmentIdentifier, m.Model),
+				Connected: true,
+				State:     m.State.String(),
+			}
+		}
+	}
+	response := make([]ModemStatusResponse, 0, len(statuses))
+	for _, id := range slices.Sorted(maps.Keys(statuses)) {
+		response = append(response, statuses[id])
+	}
+	return response
+}
+
+func (s *Service) name(id, model string) string {
+	if alias := s.cfg.FindModem(id).Alias; alias != "" {
+		return alias
+	}
+	return model
+}
+
+func failed(err error) CheckResult {
+	return CheckResult{Status: statusUnavailable, Error: err.Error()}
+}
@@ -0,0 +1,23 @@
+package health
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+	Modems []ModemStatusResponse  `json:"modems"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ModemStatusResponse is the public summary of a modem, named by its alias or model.
+type ModemStatusResponse struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	State     string `json:"state,omitempty"`
+}
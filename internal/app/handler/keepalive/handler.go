@@ -0,0 +1,64 @@
+package keepalive
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/keepalive"
+)
+
+type Handler struct {
+	handler.Handler
+	service *Service
+}
+
+func New(keeper *keepalive.Service) *Handler {
+	return &Handler{service: NewService(keeper)}
+}
+
+func (h *Handler) List(c echo.Context) error {
+	return h.Respond(c, h.service.List())
+}
+
+func (h *Handler) Put(c echo.Context) error {
+	var req PolicyRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	response, err := h.service.Put(c.Param("iccid"), req)
+	if err != nil {
+		return h.policyError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) Delete(c echo.Context) error {
+	if err := h.service.Delete(c.Param("iccid")); err != nil {
+		return h.policyError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Run starts a keep-alive immediately, ignoring its windows. The outcome is reported
+// to the policy's channels and shown as its last run.
+func (h *Handler) Run(c echo.Context) error {
+	if err := h.service.Run(c.Request().Context(), c.Param("iccid")); err != nil {
+		return h.policyError(c, err)
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (h *Handler) policyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, keepalive.ErrPolicyNotFound):
+		return h.NotFound(c, err)
+	case errors.Is(err, keepalive.ErrInvalidPolicy):
+		return h.BadRequest(c, err)
+	case errors.Is(err, keepalive.ErrAlreadyRunning):
+		return h.Conflict(c, err)
+	}
+	return h.InternalServerError(c, err)
+}
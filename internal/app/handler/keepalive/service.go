@@ -0,0 +1,89 @@
+package keepalive
+
+import (
+	"context"
+
+	"github.com/damonto/sigmo/internal/app/keepalive"
+	"github.com/damonto/sigmo/internal/pkg/config"
+)
+
+type Service struct {
+	keeper *keepalive.Service
+}
+
+func NewService(keeper *keepalive.Service) *Service {
+	return &Service{keeper: keeper}
+}
+
+func (s *Service) List() []PolicyResponse {
+	policies := s.keeper.Policies()
+	response := make([]PolicyResponse, 0, len(policies))
+	for _, policy := range policies {
+		response = append(response, s.responseFrom(policy))
+	}
+	return response
+}
+
+func (s *Service) Put(iccid string, req PolicyRequest) (*PolicyResponse, error) {
+	policy, err := s.keeper.Put(config.KeepAlive{
+		ICCID:        iccid,
+		IntervalDays: req.IntervalDays,
+		USSD:         req.USSD,
+		SMSTo:        req.SMSTo,
+		SMSText:      req.SMSText,
+		Windows:      req.Windows,
+		Channels:     req.Channels,
+		Enabled:      *req.Enabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := s.responseFrom(policy)
+	return &response, nil
+}
+
+func (s *Service) Delete(iccid string) error {
+	return s.keeper.Delete(iccid)
+}
+
+func (s *Service) Run(ctx context.Context, iccid string) error {
+	return s.keeper.Trigger(ctx, iccid)
+}
+
+func (s *Service) responseFrom(policy config.KeepAlive) PolicyResponse {
+	response := PolicyResponse{
+		ICCID:        policy.ICCID,
+		IntervalDays: policy.IntervalDays,
+		USSD:         policy.USSD,
+		SMSTo:        policy.SMSTo,
+		SMSText:      policy.SMSText,
+		Windows:      nonNil(policy.Windows),
+		Channels:     nonNil(policy.Channels),
+		Enabled:      policy.Enabled,
+	}
+	lastActivity, due, result := s.keeper.Status(policy)
+	if !lastActivity.IsZero() {
+		response.LastActivityAt = &lastActivity
+	}
+	if !due.IsZero() {
+		response.DueAt = &due
+	}
+	if result != nil {
+		response.LastRun = &RunResponse{
+			Modem:     result.Modem,
+			StartedAt: result.Run.StartedAt,
+			EndedAt:   result.Run.EndedAt,
+			Previous:  result.Run.Previous,
+			Steps:     nonNil(result.Run.Steps),
+			Error:     result.Run.Error,
+		}
+	}
+	return response
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,36 @@
+package keepalive
+
+import "time"
+
+type PolicyRequest struct {
+	IntervalDays int      `json:"intervalDays" validate:"gte=1,lte=3650"`
+	USSD         string   `json:"ussd"`
+	SMSTo        string   `json:"smsTo"`
+	SMSText      string   `json:"smsText"`
+	Windows      []string `json:"windows"`
+	Channels     []string `json:"channels"`
+	Enabled      *bool    `json:"enabled" validate:"required"`
+}
+
+type PolicyResponse struct {
+	ICCID          string       `json:"iccid"`
+	IntervalDays   int          `json:"intervalDays"`
+	USSD           string       `json:"ussd,omitempty"`
+	SMSTo          string       `json:"smsTo,omitempty"`
+	SMSText        string       `json:"smsText,omitempty"`
+	Windows        []string     `json:"windows"`
+	Channels       []string     `json:"channels"`
+	Enabled        bool         `json:"enabled"`
+	LastActivityAt *time.Time   `json:"lastActivityAt,omitempty"`
+	DueAt          *time.Time   `json:"dueAt,omitempty"`
+	LastRun        *RunResponse `json:"lastRun,omitempty"`
+}
+
+type RunResponse struct {
+	Modem     string    `json:"modem,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	Previous  string    `json:"previous,omitempty"`
+	Steps     []string  `json:"steps"`
+	Error     string    `json:"error,omitempty"`
+}
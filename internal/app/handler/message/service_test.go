@@ -0,0 +1,131 @@
+package message
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/damonto/sigmo/internal/pkg/fakemm"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var bus *fakemm.Bus
+
+func TestMain(m *testing.M) {
+	bus = fakemm.NewBus()
+	code := m.Run()
+	bus.Close()
+	os.Exit(code)
+}
+
+// setup adds a modem to the fake ModemManager and returns the fake and the modem.
+func setup(t *testing.T) (*fakemm.Modem, *mmodem.Modem) {
+	t.Helper()
+	return bus.AddModem(t, fakemm.ModemConfig{})
+}
+
+func receive(t *testing.T, fake *fakemm.Modem, number, text string) {
+	t.Helper()
+	if _, err := fake.Receive(number, text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		to      string
+		text    string
+		wantErr error
+	}{
+		{name: "message", to: "+8613800000000", text: "hello"},
+		{name: "missing recipient", to: " ", text: "hello", wantErr: errRecipientRequired},
+		{name: "missing text", to: "+8613800000000", text: "\n", wantErr: errTextRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, modem := setup(t)
+			err := NewService().Send(modem, tt.to, tt.text)
+			if err != tt.wantErr {
+				t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+			}
+			messages := fake.Messages()
+			if tt.wantErr != nil {
+				if len(messages) != 0 {
+					t.Errorf("Send() stored %d messages, want none", len(messages))
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("Send() stored %d messages, want 1", len(messages))
+			}
+			got := messages[0]
+			if got.Number != tt.to || got.Text != tt.text || got.State != mmodem.SMSStateSent {
+				t.Errorf("sent message = %s %q %s, want %s %q %s", got.Number, got.Text, got.State, tt.to, tt.text, mmodem.SMSStateSent)
+			}
+		})
+	}
+}
+
+func TestListConversations(t *testing.T) {
+	fake, modem := setup(t)
+	service := NewService()
+	receive(t, fake, "10086", "hello")
+	if err := service.Send(modem, "+8613800000000", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	conversations, err := service.ListConversations(modem)
+	if err != nil {
+		t.Fatalf("ListConversations() error = %v", err)
+	}
+	if len(conversations) != 2 {
+		t.Fatalf("ListConversations() returned %d conversations, want 2: %+v", len(conversations), conversations)
+	}
+	// Newest first.
+	if got := conversations[0]; got.Recipient != "+8613800000000" || got.Text != "hi" || got.Incoming || got.Status != "sent" {
+		t.Errorf("ListConversations()[0] = %+v, want the message sent to +8613800000000", got)
+	}
+	if got := conversations[1]; got.Sender != "10086" || got.Text != "hello" || !got.Incoming || got.Status != "received" {
+		t.Errorf("ListConversations()[1] = %+v, want the message from 10086", got)
+	}
+}
+
+func TestListByParticipant(t *testing.T) {
+	fake, modem := setup(t)
+	service := NewService()
+	receive(t, fake, "10086", "first")
+	receive(t, fake, "+8613800000000", "hi")
+	receive(t, fake, "10086", "second")
+
+	messages, err := service.ListByParticipant(modem, "10086")
+	if err != nil {
+		t.Fatalf("ListByParticipant() error = %v", err)
+	}
+	var texts []string
+	for _, message := range messages {
+		texts = append(texts, message.Text)
+	}
+	if want := []string{"first", "second"}; !slices.Equal(texts, want) {
+		t.Errorf("ListByParticipant() texts = %v, want %v", texts, want)
+	}
+	if _, err := service.ListByParticipant(modem, ""); err != errParticipantRequired {
+		t.Errorf("ListByParticipant() without participant error = %v, want %v", err, errParticipantRequired)
+	}
+}
+
+func TestDeleteByParticipant(t *testing.T) {
+	fake, modem := setup(t)
+	service := NewService()
+	receive(t, fake, "10086", "first")
+	receive(t, fake, "+8613800000000", "hi")
+	receive(t, fake, "10086", "second")
+
+	if err := service.DeleteByParticipant(modem, "10086"); err != nil {
+		t.Fatalf("DeleteByParticipant() error = %v", err)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || messages[0].Number != "+8613800000000" {
+		t.Errorf("messages after DeleteByParticipant() = %+v, want only the one from +8613800000000", messages)
+	}
+}
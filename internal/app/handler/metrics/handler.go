@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+type Handler struct {
+	handler.Handler
+	service *Service
+}
+
+func New(manager *mmodem.Manager, registry *mmodem.Registry, recorder *telemetry.Recorder) *Handler {
+	return &Handler{service: NewService(manager, registry, recorder)}
+}
+
+func (h *Handler) Get(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	return h.service.Write(c.Response())
+}
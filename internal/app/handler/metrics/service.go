@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+// The modem gauges are set from the current state of the modems on every scrape.
+var (
+	modemConnected    = metrics.NewGauge("sigmo_modem_connected", "Whether a modem that has been seen before is connected.", "modem")
+	modemState        = metrics.NewGauge("sigmo_modem_state", "ModemManager state of a modem (MMModemState).", "modem")
+	signalQuality     = metrics.NewGauge("sigmo_modem_signal_quality_percent", "Signal quality of a modem in percent.", "modem")
+	registrationState = metrics.NewGauge("sigmo_modem_registration_state", "3GPP registration state of a modem (MMModem3gppRegistrationState).", "modem")
+	registered        = metrics.NewGauge("sigmo_modem_registered", "Whether a modem is registered on a home or roaming network.", "modem")
+	accessTechnology  = metrics.NewGauge("sigmo_modem_access_technology", "Access technologies a modem is using.", "modem", "technology")
+	signalRSSI        = metrics.NewGauge("sigmo_modem_signal_rssi_dbm", "Received signal strength in dBm.", "modem", "technology")
+	signalRSRP        = metrics.NewGauge("sigmo_modem_signal_rsrp_dbm", "Reference signal received power in dBm.", "modem", "technology")
+	signalRSRQ        = metrics.NewGauge("sigmo_modem_signal_rsrq_db", "Reference signal received quality in dB.", "modem", "technology")
+	signalSINR        = metrics.NewGauge("sigmo_modem_signal_sinr_db", "Signal to interference plus noise ratio in dB.", "modem", "technology")
+
+	modemGauges = []*metrics.Gauge{
+		modemConnected, modemState, signalQuality, registrationState, registered,
+		accessTechnology, signalRSSI, signalRSRP, signalRSRQ, signalSINR,
+	}
+)
+
+type Service struct {
+	manager  *mmodem.Manager
+	registry *mmodem.Registry
+	recorder *telemetry.Recorder
+	mu       sync.Mutex
+}
+
+func NewService(manager *mmodem.Manager, registry *mmodem.Registry, recorder *telemetry.Recorder) *Service {
+	return &Service{manager: manager, registry: registry, recorder: recorder}
+}
+
+// Write updates the modem gauges and writes all metrics to w.
+func (s *Service) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, gauge := range modemGauges {
+		gauge.Reset()
+	}
+	for _, known := range s.registry.Known() {
+		modemConnected.Set(0, known.EquipmentIdentifier)
+	}
+	modems, err := s.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+	}
+	for _, m := range modems {
+		s.collect(m)
+	}
+	return metrics.Write(w)
+}
+
+// collect sets the gauges of a connected modem. Values that cannot be read are left out.
+func (s *Service) collect(m *mmodem.Modem) {
+	id := m.EquipmentIdentifier
+	modemConnected.Set(1, id)
+	modemState.Set(float64(m.State), id)
+	if percent, _, err := m.SignalQuality(); err == nil {
+		signalQuality.Set(float64(percent), id)
+	} else {
+		slog.Debug("failed to read signal quality", "modem", id, "error", err)
+	}
+	if state, err := m.ThreeGPP().RegistrationState(); err == nil {
+		registrationState.Set(float64(state), id)
+		registered.Set(boolValue(state.Registered()), id)
+	} else {
+		slog.Debug("failed to read registration state", "modem", id, "error", err)
+	}
+	if access, err := m.AccessTechnologies(); err == nil {
+		for _, tech := range access {
+			accessTechnology.Set(1, id, tech.String())
+		}
+	} else {
+		slog.Debug("failed to read access technologies", "modem", id, "error", err)
+	}
+
+	sample, ok := s.recorder.Latest(id)
+	if !ok {
+		return
+	}
+	for tech, measurement := range map[string]*mmodem.SignalMeasurement{
+		"GSM":  sample.Metrics.GSM,
+		"UMTS": sample.Metrics.UMTS,
+		"LTE":  sample.Metrics.LTE,
+		"5GNR": sample.Metrics.NR5G,
+	} {
+		if measurement == nil {
+			continue
+		}
+		for gauge, value := range map[*metrics.Gauge]*float64{
+			signalRSSI: measurement.RSSI,
+			signalRSRP: measurement.RSRP,
+			signalRSRQ: measurement.RSRQ,
+			signalSINR: measurement.SINR,
+		} {
+			if value != nil {
+				gauge.Set(*value, id, tech)
+			}
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/damonto/sigmo/internal/app/handler"
 	"github.com/damonto/sigmo/internal/pkg/config"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/modem/plmn"
 )
 
 type Handler struct {
@@ -22,22 +23,24 @@ type Handler struct {
 const (
 	switchSimSlotTimeout = time.Minute
 	updateMSISDNTimeout  = time.Minute
+	clearFPLMNTimeout    = time.Minute
 )
 
 var (
 	errSwitchSimSlotTimeout = errors.New("switching SIM slot timed out, please refresh to confirm the active slot")
 	errUpdateMSISDNTimeout  = errors.New("updating MSISDN timed out, please refresh to confirm the active slot")
+	errClearFPLMNTimeout    = errors.New("restarting the modem timed out after clearing forbidden networks, please refresh")
 )
 
-func New(cfg *config.Config, manager *mmodem.Manager) *Handler {
+func New(cfg *config.Config, manager *mmodem.Manager, registry *mmodem.Registry) *Handler {
 	return &Handler{
 		manager: manager,
-		service: NewService(cfg, manager),
+		service: NewService(cfg, manager, registry),
 	}
 }
 
 func (h *Handler) List(c echo.Context) error {
-	response, err := h.service.List()
+	response, err := h.service.List(c.Request().Context())
 	if err != nil {
 		return h.InternalServerError(c, err)
 	}
@@ -49,13 +52,27 @@ func (h *Handler) Get(c echo.Context) error {
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.Get(modem)
+	response, err := h.service.Get(c.Request().Context(), modem)
 	if err != nil {
 		return h.InternalServerError(c, err)
 	}
 	return h.Respond(c, response)
 }
 
+// Forget removes a disconnected modem from the modem list.
+func (h *Handler) Forget(c echo.Context) error {
+	if err := h.service.Forget(c.Param("id")); err != nil {
+		if errors.Is(err, mmodem.ErrUnknownModem) {
+			return h.NotFound(c, err)
+		}
+		if errors.Is(err, mmodem.ErrModemConnected) {
+			return h.Conflict(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
 func (h *Handler) SwitchSimSlot(c echo.Context) error {
 	modem, err := h.FindModem(h.manager, c.Param("id"))
 	if err != nil {
@@ -109,6 +126,64 @@ func (h *Handler) UpdateMSISDN(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *Handler) GetPLMNs(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.PLMNs(c.Request().Context(), modem)
+	if err != nil {
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) UpdatePLMNs(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req UpdatePLMNsRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.service.UpdatePLMNs(c.Request().Context(), modem, req); err != nil {
+		if errors.Is(err, plmn.ErrInvalidOperator) || errors.Is(err, plmn.ErrListFull) {
+			return h.BadRequest(c, err)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) GetForbiddenNetworks(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.ForbiddenNetworks(c.Request().Context(), modem)
+	if err != nil {
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) ClearForbiddenNetworks(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), clearFPLMNTimeout)
+	defer cancel()
+	if err := h.service.ClearForbiddenNetworks(ctx, modem); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return h.Error(c, http.StatusRequestTimeout, errClearFPLMNTimeout)
+		}
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
 func (h *Handler) UpdateSettings(c echo.Context) error {
 	modem, err := h.FindModem(h.manager, c.Param("id"))
 	if err != nil {
@@ -119,7 +194,7 @@ func (h *Handler) UpdateSettings(c echo.Context) error {
 		return err
 	}
 	if err := h.service.UpdateSettings(modem.EquipmentIdentifier, req); err != nil {
-		if errors.Is(err, errCompatibleRequired) {
+		if errors.Is(err, errCompatibleRequired) || errors.Is(err, errInvalidAID) {
 			return h.BadRequest(c, err)
 		}
 		return h.InternalServerError(c, err)
@@ -2,6 +2,7 @@ package modem
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"log/slog"
 	"regexp"
@@ -12,12 +13,15 @@ import (
 	"github.com/damonto/sigmo/internal/pkg/config"
 	"github.com/damonto/sigmo/internal/pkg/lpa"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/modem/fplmn"
 	"github.com/damonto/sigmo/internal/pkg/modem/msisdn"
+	"github.com/damonto/sigmo/internal/pkg/modem/plmn"
 )
 
 type Service struct {
-	cfg     *config.Config
-	manager *mmodem.Manager
+	cfg      *config.Config
+	manager  *mmodem.Manager
+	registry *mmodem.Registry
 }
 
 var (
@@ -27,30 +31,40 @@ var (
 	errSimSlotAlreadyActive  = errors.New("sim slot already active")
 	errMSISDNInvalidNumber   = errors.New("invalid phone number")
 	errCompatibleRequired    = errors.New("compatible is required")
+	errInvalidAID            = errors.New("aid must be 5 to 16 bytes of hex")
 )
 
 var msisdnPhoneRE = regexp.MustCompile(`^\+?[0-9]{1,15}$`)
 
-func NewService(cfg *config.Config, manager *mmodem.Manager) *Service {
+func NewService(cfg *config.Config, manager *mmodem.Manager, registry *mmodem.Registry) *Service {
 	return &Service{
-		cfg:     cfg,
-		manager: manager,
+		cfg:      cfg,
+		manager:  manager,
+		registry: registry,
 	}
 }
 
-func (s *Service) List() ([]*ModemResponse, error) {
+func (s *Service) List(ctx context.Context) ([]*ModemResponse, error) {
 	modems, err := s.manager.Modems()
 	if err != nil {
 		slog.Error("failed to list modems", "error", err)
 		return nil, err
 	}
 	response := make([]*ModemResponse, 0, len(modems))
+	connected := make(map[string]bool, len(modems))
 	for _, m := range modems {
-		modemResp, err := s.buildModemResponse(m)
+		modemResp, err := s.buildModemResponse(ctx, m)
 		if err != nil {
 			return nil, err
 		}
 		response = append(response, modemResp)
+		connected[m.EquipmentIdentifier] = true
+	}
+	// Modems seen before but not connected now are listed too, so they do not silently vanish.
+	for _, known := range s.registry.Known() {
+		if !connected[known.EquipmentIdentifier] {
+			response = append(response, s.buildDisconnectedResponse(known))
+		}
 	}
 	slices.SortFunc(response, func(a, b *ModemResponse) int {
 		return strings.Compare(a.ID, b.ID)
@@ -58,8 +72,8 @@ func (s *Service) List() ([]*ModemResponse, error) {
 	return response, nil
 }
 
-func (s *Service) Get(modem *mmodem.Modem) (*ModemResponse, error) {
-	resp, err := s.buildModemResponse(modem)
+func (s *Service) Get(ctx context.Context, modem *mmodem.Modem) (*ModemResponse, error) {
+	resp, err := s.buildModemResponse(ctx, modem)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +88,11 @@ func (s *Service) SwitchSimSlot(ctx context.Context, modem *mmodem.Modem, identi
 	if err != nil {
 		return err
 	}
-	if err := modem.SetPrimarySimSlot(slotIndex); err != nil {
+	// Switching resets the SIM, which the eUICC must not be in use for.
+	err = lpa.Exclusive(ctx, modem.EquipmentIdentifier, func() error {
+		return modem.SetPrimarySimSlot(slotIndex)
+	})
+	if err != nil {
 		slog.Error("failed to set primary SIM slot", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
@@ -90,49 +108,239 @@ func (s *Service) UpdateMSISDN(ctx context.Context, modem *mmodem.Modem, number
 	if !msisdnPhoneRE.MatchString(number) {
 		return errMSISDNInvalidNumber
 	}
-	port, err := modem.Port(mmodem.ModemPortTypeAt)
+	device, err := s.atDevice(modem)
 	if err != nil {
-		slog.Error("failed to find AT port", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
-	client, err := msisdn.New(port.Device)
+	err = lpa.Exclusive(ctx, modem.EquipmentIdentifier, func() error {
+		client, err := msisdn.New(device)
+		if err != nil {
+			slog.Error("failed to open MSISDN client", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		defer func() {
+			if cerr := client.Close(); cerr != nil {
+				slog.Warn("failed to close MSISDN client", "error", cerr, "modem", modem.EquipmentIdentifier)
+			}
+		}()
+		if err := client.Update("", number); err != nil {
+			slog.Error("failed to update MSISDN", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		slog.Error("failed to open MSISDN client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
-	defer func() {
+	return s.restart(ctx, modem)
+}
+
+// ForbiddenNetworks returns the forbidden network list of the SIM.
+func (s *Service) ForbiddenNetworks(ctx context.Context, modem *mmodem.Modem) ([]ForbiddenNetworkResponse, error) {
+	device, err := s.atDevice(modem)
+	if err != nil {
+		return nil, err
+	}
+	var operators []string
+	err = lpa.Exclusive(ctx, modem.EquipmentIdentifier, func() error {
+		client, err := fplmn.New(device)
+		if err != nil {
+			slog.Error("failed to open FPLMN client", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		defer func() {
+			if cerr := client.Close(); cerr != nil {
+				slog.Warn("failed to close FPLMN client", "error", cerr, "modem", modem.EquipmentIdentifier)
+			}
+		}()
+		operators, err = client.List()
+		if err != nil {
+			slog.Error("failed to read forbidden networks", "modem", modem.EquipmentIdentifier, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := make([]ForbiddenNetworkResponse, 0, len(operators))
+	for _, operator := range operators {
+		response = append(response, ForbiddenNetworkResponse{
+			OperatorCode: operator,
+			OperatorName: carrier.Lookup(operator).Name,
+		})
+	}
+	return response, nil
+}
+
+// ClearForbiddenNetworks empties the forbidden network list of the SIM and
+// restarts the modem, which keeps its own copy of the list until then.
+func (s *Service) ClearForbiddenNetworks(ctx context.Context, modem *mmodem.Modem) error {
+	device, err := s.atDevice(modem)
+	if err != nil {
+		return err
+	}
+	err = lpa.Exclusive(ctx, modem.EquipmentIdentifier, func() error {
+		client, err := fplmn.New(device)
+		if err != nil {
+			slog.Error("failed to open FPLMN client", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		err = client.Clear()
 		if cerr := client.Close(); cerr != nil {
-			slog.Warn("failed to close MSISDN client", "error", cerr, "modem", modem.EquipmentIdentifier)
+			slog.Warn("failed to close FPLMN client", "error", cerr, "modem", modem.EquipmentIdentifier)
 		}
-	}()
-	if err := client.Update("", number); err != nil {
-		slog.Error("failed to update MSISDN", "modem", modem.EquipmentIdentifier, "error", err)
+		if err != nil {
+			slog.Error("failed to clear forbidden networks", "modem", modem.EquipmentIdentifier, "error", err)
+		}
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	return s.restart(ctx, modem)
+}
+
+// atDevice returns the AT port of a modem. The LPA session may hold the same
+// port open, so it must only be used within lpa.Exclusive.
+func (s *Service) atDevice(modem *mmodem.Modem) (string, error) {
+	port, err := modem.Port(mmodem.ModemPortTypeAt)
+	if err != nil {
+		slog.Error("failed to find AT port", "modem", modem.EquipmentIdentifier, "error", err)
+		return "", err
+	}
+	return port.Device, nil
+}
+
+// restart restarts a modem so that it reads the SIM again, and waits for it to come back.
+func (s *Service) restart(ctx context.Context, modem *mmodem.Modem) error {
 	if err := modem.Restart(s.cfg.FindModem(modem.EquipmentIdentifier).Compatible); err != nil {
 		slog.Error("failed to restart modem", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
 	}
-	_, err = s.manager.WaitForModem(ctx, modem.EquipmentIdentifier)
+	_, err := s.manager.WaitForModem(ctx, modem.EquipmentIdentifier)
 	if err != nil {
 		slog.Error("failed to wait for modem", "modem", modem.EquipmentIdentifier, "error", err)
 	}
 	return err
 }
 
+// PLMNs returns the preferred network list of the SIM, most preferred first.
+func (s *Service) PLMNs(ctx context.Context, modem *mmodem.Modem) ([]PLMNResponse, error) {
+	var entries []plmn.Entry
+	err := s.withPLMN(ctx, modem, func(client *plmn.PLMN) error {
+		var err error
+		entries, err = client.List()
+		if err != nil {
+			slog.Error("failed to read preferred networks", "modem", modem.EquipmentIdentifier, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := make([]PLMNResponse, 0, len(entries))
+	for _, entry := range entries {
+		access := make([]string, 0, 4)
+		for _, tech := range []struct {
+			name    string
+			enabled bool
+		}{{"GSM", entry.GSM}, {"UMTS", entry.UTRAN}, {"LTE", entry.EUTRAN}, {"5GNR", entry.NGRAN}} {
+			if tech.enabled {
+				access = append(access, tech.name)
+			}
+		}
+		response = append(response, PLMNResponse{
+			OperatorCode:       entry.OperatorCode,
+			OperatorName:       carrier.Lookup(entry.OperatorCode).Name,
+			AccessTechnologies: access,
+		})
+	}
+	return response, nil
+}
+
+// UpdatePLMNs replaces the preferred network list of the SIM. The modem reads it
+// the next time it selects a network automatically.
+func (s *Service) UpdatePLMNs(ctx context.Context, modem *mmodem.Modem, req UpdatePLMNsRequest) error {
+	entries := make([]plmn.Entry, 0, len(req.Networks))
+	for _, network := range req.Networks {
+		entry := plmn.Entry{OperatorCode: strings.TrimSpace(network.OperatorCode)}
+		access := network.AccessTechnologies
+		if len(access) == 0 {
+			access = []string{"GSM", "UMTS", "LTE"}
+		}
+		entry.GSM = slices.Contains(access, "GSM")
+		entry.UTRAN = slices.Contains(access, "UMTS")
+		entry.EUTRAN = slices.Contains(access, "LTE")
+		entry.NGRAN = slices.Contains(access, "5GNR")
+		entries = append(entries, entry)
+	}
+	return s.withPLMN(ctx, modem, func(client *plmn.PLMN) error {
+		if err := client.Replace(entries); err != nil {
+			slog.Error("failed to update preferred networks", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// withPLMN runs fn with a PLMN client on the AT port of a modem, holding its eUICC.
+func (s *Service) withPLMN(ctx context.Context, modem *mmodem.Modem, fn func(client *plmn.PLMN) error) error {
+	device, err := s.atDevice(modem)
+	if err != nil {
+		return err
+	}
+	return lpa.Exclusive(ctx, modem.EquipmentIdentifier, func() error {
+		client, err := plmn.New(device)
+		if err != nil {
+			slog.Error("failed to open PLMN client", "modem", modem.EquipmentIdentifier, "error", err)
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				slog.Warn("failed to close PLMN client", "error", err, "modem", modem.EquipmentIdentifier)
+			}
+		}()
+		return fn(client)
+	})
+}
+
 func (s *Service) UpdateSettings(modemID string, req UpdateModemSettingsRequest) error {
 	if req.Compatible == nil {
 		return errCompatibleRequired
 	}
-	modem := s.cfg.FindModem(modemID)
-	modem.Alias = strings.TrimSpace(req.Alias)
-	modem.Compatible = *req.Compatible
-	modem.MSS = req.MSS
-	if s.cfg.Modems == nil {
-		s.cfg.Modems = make(map[string]config.Modem)
-	}
-	s.cfg.Modems[modemID] = modem
-	if err := s.cfg.Save(); err != nil {
+	var aid *string
+	if req.AID != nil {
+		var value string
+		if strings.TrimSpace(*req.AID) != "" {
+			parsed, err := lpa.ParseAID(*req.AID)
+			if err != nil {
+				return errInvalidAID
+			}
+			value = strings.ToUpper(hex.EncodeToString(parsed))
+		}
+		aid = &value
+	}
+	err := s.cfg.Update(func(c *config.Config) error {
+		modem, ok := c.Modems[modemID]
+		if !ok {
+			modem = config.DefaultModem()
+		}
+		modem.Alias = strings.TrimSpace(req.Alias)
+		modem.Compatible = *req.Compatible
+		modem.MSS = req.MSS
+		if aid != nil {
+			modem.AID = *aid
+		}
+		if req.APDUTrace != nil {
+			modem.APDUTrace = *req.APDUTrace
+		}
+		if c.Modems == nil {
+			c.Modems = make(map[string]config.Modem)
+		}
+		c.Modems[modemID] = modem
+		return nil
+	})
+	if err != nil {
 		slog.Error("failed to save config", "modem", modemID, "error", err)
 		return err
 	}
@@ -145,10 +353,33 @@ func (s *Service) GetSettings(modemID string) *ModemSettingsResponse {
 		Alias:      modem.Alias,
 		Compatible: modem.Compatible,
 		MSS:        modem.MSS,
+		AID:        modem.AID,
+		APDUTrace:  modem.APDUTrace,
+	}
+}
+
+// Forget removes a disconnected modem from the list of known modems.
+func (s *Service) Forget(modemID string) error {
+	return s.registry.Forget(modemID)
+}
+
+func (s *Service) buildDisconnectedResponse(known mmodem.KnownModem) *ModemResponse {
+	name := known.Model
+	if alias := s.cfg.FindModem(known.EquipmentIdentifier).Alias; alias != "" {
+		name = alias
+	}
+	return &ModemResponse{
+		Manufacturer: known.Manufacturer,
+		ID:           known.EquipmentIdentifier,
+		Name:         name,
+		Slots:        []SlotResponse{},
+		Connected:    false,
+		FirstSeenAt:  &known.FirstSeen,
+		LastSeenAt:   &known.LastSeen,
 	}
 }
 
-func (s *Service) buildModemResponse(m *mmodem.Modem) (*ModemResponse, error) {
+func (s *Service) buildModemResponse(ctx context.Context, m *mmodem.Modem) (*ModemResponse, error) {
 	sim, err := m.SIMs().Primary()
 	if err != nil {
 		slog.Error("failed to fetch SIM", "modem", m.EquipmentIdentifier, "error", err)
@@ -187,7 +418,7 @@ func (s *Service) buildModemResponse(m *mmodem.Modem) (*ModemResponse, error) {
 	}
 
 	carrierInfo := carrier.Lookup(sim.OperatorIdentifier)
-	supportsEsim, err := supportsEsim(m, s.cfg)
+	supportsEsim, err := supportsEsim(ctx, m, s.cfg)
 	if err != nil {
 		slog.Error("failed to detect eSIM support", "modem", m.EquipmentIdentifier, "error", err)
 		return nil, err
@@ -208,7 +439,7 @@ func (s *Service) buildModemResponse(m *mmodem.Modem) (*ModemResponse, error) {
 	if sim.OperatorName != "" {
 		simOperatorName = sim.OperatorName
 	}
-	return &ModemResponse{
+	response := &ModemResponse{
 		Manufacturer:     m.Manufacturer,
 		ID:               m.EquipmentIdentifier,
 		FirmwareRevision: m.FirmwareRevision,
@@ -231,7 +462,13 @@ func (s *Service) buildModemResponse(m *mmodem.Modem) (*ModemResponse, error) {
 		},
 		SignalQuality: percent,
 		SupportsEsim:  supportsEsim,
-	}, nil
+		Connected:     true,
+	}
+	if known, ok := s.registry.Find(m.EquipmentIdentifier); ok {
+		response.FirstSeenAt = &known.FirstSeen
+		response.LastSeenAt = &known.LastSeen
+	}
+	return response, nil
 }
 
 func (s *Service) buildSimSlotsResponse(m *mmodem.Modem) ([]SlotResponse, error) {
@@ -278,21 +515,13 @@ func (s *Service) findSimSlotIndex(modem *mmodem.Modem, identifier string) (uint
 	return 0, errSimSlotNotFound
 }
 
-func supportsEsim(m *mmodem.Modem, cfg *config.Config) (bool, error) {
-	client, err := lpa.New(m, cfg)
+func supportsEsim(ctx context.Context, m *mmodem.Modem, cfg *config.Config) (bool, error) {
+	supported, err := lpa.SupportsEsim(ctx, m, cfg)
 	if err != nil {
-		if errors.Is(err, lpa.ErrNoSupportedAID) {
-			return false, nil
-		}
 		slog.Error("failed to create LPA client", "modem", m.EquipmentIdentifier, "error", err)
 		return false, err
 	}
-	defer func() {
-		if cerr := client.Close(); cerr != nil {
-			slog.Warn("failed to close LPA client", "error", cerr)
-		}
-	}()
-	return true, nil
+	return supported, nil
 }
 
 func accessTechnologyString(access []mmodem.ModemAccessTechnology) string {
@@ -1,5 +1,7 @@
 package modem
 
+import "time"
+
 type SlotResponse struct {
 	Active             bool   `json:"active"`
 	OperatorName       string `json:"operatorName"`
@@ -17,16 +19,43 @@ type UpdateMSISDNRequest struct {
 	Number string `json:"number" validate:"required"`
 }
 
+// PLMNRequest is a network of the SIM's preferred network list. Access
+// technologies are "GSM", "UMTS", "LTE" and "5GNR"; empty means GSM, UMTS and LTE.
+type PLMNRequest struct {
+	OperatorCode       string   `json:"operatorCode" validate:"required"`
+	AccessTechnologies []string `json:"accessTechnologies" validate:"dive,oneof=GSM UMTS LTE 5GNR"`
+}
+
+type UpdatePLMNsRequest struct {
+	// Networks replace the list, most preferred first.
+	Networks []PLMNRequest `json:"networks" validate:"dive"`
+}
+
+type PLMNResponse struct {
+	OperatorCode       string   `json:"operatorCode"`
+	OperatorName       string   `json:"operatorName"`
+	AccessTechnologies []string `json:"accessTechnologies"`
+}
+
+type ForbiddenNetworkResponse struct {
+	OperatorCode string `json:"operatorCode"`
+	OperatorName string `json:"operatorName"`
+}
+
 type UpdateModemSettingsRequest struct {
-	Alias      string `json:"alias"`
-	Compatible *bool  `json:"compatible" validate:"required"`
-	MSS        int    `json:"mss" validate:"gte=64,lte=254"`
+	Alias      string  `json:"alias"`
+	Compatible *bool   `json:"compatible" validate:"required"`
+	MSS        int     `json:"mss" validate:"gte=64,lte=254"`
+	AID        *string `json:"aid"`
+	APDUTrace  *bool   `json:"apduTrace"`
 }
 
 type ModemSettingsResponse struct {
 	Alias      string `json:"alias"`
 	Compatible bool   `json:"compatible"`
 	MSS        int    `json:"mss"`
+	AID        string `json:"aid"`
+	APDUTrace  bool   `json:"apduTrace"`
 }
 
 type ModemResponse struct {
@@ -43,4 +72,7 @@ type ModemResponse struct {
 	RegisteredOperator RegisteredOperatorResponse `json:"registeredOperator"`
 	SignalQuality      uint32                     `json:"signalQuality"`
 	SupportsEsim       bool                       `json:"supportsEsim"`
+	Connected          bool                       `json:"connected"`
+	FirstSeenAt        *time.Time                 `json:"firstSeenAt,omitempty"`
+	LastSeenAt         *time.Time                 `json:"lastSeenAt,omitempty"`
 }
@@ -1,12 +1,14 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/registrar"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
 )
 
@@ -16,21 +18,40 @@ type Handler struct {
 	service *Service
 }
 
-func New(manager *mmodem.Manager) *Handler {
+func New(ctx context.Context, manager *mmodem.Manager, reg *registrar.Service) *Handler {
 	return &Handler{
 		manager: manager,
-		service: NewService(),
+		service: NewService(ctx, reg),
 	}
 }
 
+// List returns the networks found by the last successful scan, without scanning.
 func (h *Handler) List(c echo.Context) error {
 	modem, err := h.FindModem(h.manager, c.Param("id"))
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.List(modem)
+	return h.Respond(c, h.service.List(modem))
+}
+
+// Scan starts a scan in the background and returns it, to be polled with GetScan.
+// Scans take up to a few minutes, longer than many proxies keep a request open.
+func (h *Handler) Scan(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
 	if err != nil {
-		return h.InternalServerError(c, err)
+		return h.NotFound(c, err)
+	}
+	return c.JSON(http.StatusAccepted, handler.DataResponse{Data: h.service.Scan(modem)})
+}
+
+func (h *Handler) GetScan(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.FindScan(modem, c.Param("scanId"))
+	if err != nil {
+		return h.NotFound(c, err)
 	}
 	return h.Respond(c, response)
 }
@@ -49,3 +70,60 @@ func (h *Handler) Register(c echo.Context) error {
 	}
 	return c.NoContent(http.StatusNoContent)
 }
+
+// RegisterAutomatic returns the modem to automatic network selection.
+func (h *Handler) RegisterAutomatic(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	if err := h.service.RegisterAutomatic(modem); err != nil {
+		return h.InternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) ListPolicies(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	return h.Respond(c, h.service.Policies(modem))
+}
+
+func (h *Handler) PutPolicy(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req PolicyRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	response, err := h.service.PutPolicy(modem, c.Param("scope"), req)
+	if err != nil {
+		return h.policyError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) DeletePolicy(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	if err := h.service.DeletePolicy(modem, c.Param("scope")); err != nil {
+		return h.policyError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) policyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, registrar.ErrPolicyNotFound):
+		return h.NotFound(c, err)
+	case errors.Is(err, registrar.ErrInvalidPolicy), errors.Is(err, errInvalidScope), errors.Is(err, errSIMUnavailable):
+		return h.BadRequest(c, err)
+	}
+	return h.InternalServerError(c, err)
+}
@@ -1,31 +1,119 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/damonto/sigmo/internal/app/registrar"
+	"github.com/damonto/sigmo/internal/pkg/carrier"
+	"github.com/damonto/sigmo/internal/pkg/config"
 	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/randid"
 )
 
-type Service struct{}
+const (
+	scanRunning   = "running"
+	scanSucceeded = "succeeded"
+	scanFailed    = "failed"
 
-var errOperatorCodeRequired = errors.New("operator code is required")
+	scopeModem = "modem"
+	scopeSIM   = "sim"
 
-func NewService() *Service {
-	return &Service{}
+	// scanTimeout bounds a scan. ModemManager gives up on its own well before.
+	scanTimeout = 5 * time.Minute
+)
+
+var (
+	errScanNotFound         = errors.New("scan not found")
+	errOperatorCodeRequired = errors.New("operator code is required")
+	errInvalidScope         = errors.New("scope must be modem or sim")
+	errSIMUnavailable       = errors.New("modem has no active SIM")
+)
+
+// Service runs network scans in the background. Only the latest scan and the last
+// successful result of each modem are kept, so a scan can be polled until it is
+// replaced by the next one. Network policies are kept by the registrar.
+type Service struct {
+	ctx       context.Context // canceled on shutdown, which ends running scans
+	registrar *registrar.Service
+
+	mu      sync.Mutex
+	scans   map[string]*ScanResponse     // keyed by modem
+	results map[string]*NetworksResponse // keyed by modem
+}
+
+func NewService(ctx context.Context, reg *registrar.Service) *Service {
+	return &Service{
+		ctx:       ctx,
+		registrar: reg,
+		scans:     make(map[string]*ScanResponse),
+		results:   make(map[string]*NetworksResponse),
+	}
+}
+
+// List returns the networks found by the last successful scan of a modem.
+func (s *Service) List(modem *mmodem.Modem) NetworksResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result, ok := s.results[modem.EquipmentIdentifier]; ok {
+		return *result
+	}
+	return NetworksResponse{Networks: []NetworkResponse{}}
 }
 
-func (s *Service) List(modem *mmodem.Modem) ([]NetworkResponse, error) {
-	networks, err := modem.ThreeGPP().ScanNetworks()
+// Scan starts scanning the networks of a modem. If a scan is already running on
+// the modem, that scan is returned instead of starting another.
+func (s *Service) Scan(modem *mmodem.Modem) ScanResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if scan, ok := s.scans[modem.EquipmentIdentifier]; ok && scan.Status == scanRunning {
+		return *scan
+	}
+	scan := &ScanResponse{
+		ID:        randid.New(),
+		Status:    scanRunning,
+		StartedAt: time.Now(),
+		Networks:  []NetworkResponse{},
+	}
+	s.scans[modem.EquipmentIdentifier] = scan
+	go s.run(modem, scan)
+	return *scan
+}
+
+// FindScan returns a scan of a modem by ID.
+func (s *Service) FindScan(modem *mmodem.Modem, id string) (ScanResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scan, ok := s.scans[modem.EquipmentIdentifier]
+	if !ok || scan.ID != id {
+		return ScanResponse{}, errScanNotFound
+	}
+	return *scan, nil
+}
+
+func (s *Service) run(modem *mmodem.Modem, scan *ScanResponse) {
+	ctx, cancel := context.WithTimeout(s.ctx, scanTimeout)
+	defer cancel()
+	networks, err := modem.ThreeGPP().ScanNetworks(ctx)
+	finishedAt := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scan.FinishedAt = &finishedAt
 	if err != nil {
 		slog.Error("failed to scan networks", "modem", modem.EquipmentIdentifier, "error", err)
-		return nil, err
+		scan.Status = scanFailed
+		scan.Error = err.Error()
+		return
 	}
-
-	response := make([]NetworkResponse, 0, len(networks))
+	scan.Status = scanSucceeded
+	scan.Networks = make([]NetworkResponse, 0, len(networks))
 	for _, network := range networks {
-		response = append(response, NetworkResponse{
+		scan.Networks = append(scan.Networks, NetworkResponse{
 			Status:             network.Status.String(),
 			OperatorName:       network.OperatorName,
 			OperatorShortName:  network.OperatorShortName,
@@ -33,7 +121,7 @@ func (s *Service) List(modem *mmodem.Modem) ([]NetworkResponse, error) {
 			AccessTechnologies: accessTechnologyStrings(network.AccessTechnology),
 		})
 	}
-	return response, nil
+	s.results[modem.EquipmentIdentifier] = &NetworksResponse{ScannedAt: &finishedAt, Networks: scan.Networks}
 }
 
 func (s *Service) Register(modem *mmodem.Modem, operatorCode string) error {
@@ -48,6 +136,94 @@ func (s *Service) Register(modem *mmodem.Modem, operatorCode string) error {
 	return nil
 }
 
+// RegisterAutomatic lets the modem select a network on its own again. A network
+// policy that applies to the modem moves it back on its next check.
+func (s *Service) RegisterAutomatic(modem *mmodem.Modem) error {
+	if err := modem.ThreeGPP().RegisterNetwork(""); err != nil {
+		slog.Error("failed to register network automatically", "modem", modem.EquipmentIdentifier, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Policies returns the policy of the modem and of its active SIM, if they exist.
+func (s *Service) Policies(modem *mmodem.Modem) []PolicyResponse {
+	iccid := activeICCID(modem)
+	active, found := s.registrar.Find(modem.EquipmentIdentifier, iccid)
+	response := make([]PolicyResponse, 0, 2)
+	for _, policy := range s.registrar.Policies() {
+		if policy.Modem == modem.EquipmentIdentifier || iccid != "" && policy.ICCID == iccid {
+			r := policyResponse(policy)
+			r.Active = found && policy.Modem == active.Modem && policy.ICCID == active.ICCID
+			response = append(response, r)
+		}
+	}
+	return response
+}
+
+func (s *Service) PutPolicy(modem *mmodem.Modem, scope string, req PolicyRequest) (*PolicyResponse, error) {
+	policy := config.NetworkPolicy{Operators: req.Operators, AllowRoaming: *req.AllowRoaming}
+	var err error
+	policy.Modem, policy.ICCID, err = target(modem, scope)
+	if err != nil {
+		return nil, err
+	}
+	policy, err = s.registrar.Put(policy)
+	if err != nil {
+		return nil, err
+	}
+	response := policyResponse(policy)
+	active, _ := s.registrar.Find(modem.EquipmentIdentifier, activeICCID(modem))
+	response.Active = policy.Modem == active.Modem && policy.ICCID == active.ICCID
+	return &response, nil
+}
+
+func (s *Service) DeletePolicy(modem *mmodem.Modem, scope string) error {
+	modemID, iccid, err := target(modem, scope)
+	if err != nil {
+		return err
+	}
+	return s.registrar.Delete(modemID, iccid)
+}
+
+// target returns the modem ID or ICCID a policy in scope is kept under.
+func target(modem *mmodem.Modem, scope string) (string, string, error) {
+	switch scope {
+	case scopeModem:
+		return modem.EquipmentIdentifier, "", nil
+	case scopeSIM:
+		iccid := activeICCID(modem)
+		if iccid == "" {
+			return "", "", errSIMUnavailable
+		}
+		return "", iccid, nil
+	}
+	return "", "", errInvalidScope
+}
+
+func activeICCID(modem *mmodem.Modem) string {
+	if modem.Sim == nil {
+		return ""
+	}
+	return modem.Sim.Identifier
+}
+
+func policyResponse(policy config.NetworkPolicy) PolicyResponse {
+	response := PolicyResponse{
+		Scope:        scopeModem,
+		ICCID:        policy.ICCID,
+		Operators:    make([]OperatorResponse, 0, len(policy.Operators)),
+		AllowRoaming: policy.AllowRoaming,
+	}
+	if policy.ICCID != "" {
+		response.Scope = scopeSIM
+	}
+	for _, code := range policy.Operators {
+		response.Operators = append(response.Operators, OperatorResponse{Code: code, Name: carrier.Lookup(code).Name})
+	}
+	return response
+}
+
 func accessTechnologyStrings(access []mmodem.ModemAccessTechnology) []string {
 	names := make([]string, 0, len(access))
 	for _, tech := range access {
@@ -0,0 +1,137 @@
+package network
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/fakemm"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var bus *fakemm.Bus
+
+func TestMain(m *testing.M) {
+	bus = fakemm.NewBus()
+	code := m.Run()
+	bus.Close()
+	os.Exit(code)
+}
+
+var testNetworks = []fakemm.Network{
+	{OperatorCode: "46001", OperatorName: "China Unicom", OperatorShortName: "CU", Status: mmodem.Modem3gppNetworkAvailabilityCurrent, AccessTechnology: mmodem.ModemAccessTechnologyLte},
+	{OperatorCode: "46000", OperatorName: "China Mobile", Status: mmodem.Modem3gppNetworkAvailabilityAvailable, AccessTechnology: mmodem.ModemAccessTechnologyGsm},
+	{OperatorCode: "46011", OperatorName: "China Telecom", Status: mmodem.Modem3gppNetworkAvailabilityForbidden, AccessTechnology: mmodem.ModemAccessTechnologyLte},
+}
+
+// setup adds a modem registered on the first of testNetworks.
+func setup(t *testing.T) *mmodem.Modem {
+	t.Helper()
+	_, modem := bus.AddModem(t, fakemm.ModemConfig{
+		OperatorCode: testNetworks[0].OperatorCode,
+		OperatorName: testNetworks[0].OperatorName,
+		Networks:     slices.Clone(testNetworks),
+	})
+	return modem
+}
+
+// wait polls a scan until it is no longer running.
+func wait(t *testing.T, s *Service, modem *mmodem.Modem, id string) ScanResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		scan, err := s.FindScan(modem, id)
+		if err != nil {
+			t.Fatalf("FindScan() error = %v", err)
+		}
+		if scan.Status != scanRunning {
+			return scan
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scan %s is still running", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestScan(t *testing.T) {
+	modem := setup(t)
+	s := NewService(t.Context(), nil)
+	if got := s.List(modem); got.ScannedAt != nil || len(got.Networks) != 0 {
+		t.Fatalf("List() before a scan = %+v, want no networks", got)
+	}
+
+	started := s.Scan(modem)
+	if started.ID == "" || started.Status != scanRunning || started.FinishedAt != nil {
+		t.Fatalf("Scan() = %+v, want a running scan", started)
+	}
+	scan := wait(t, s, modem, started.ID)
+	if scan.Status != scanSucceeded || scan.Error != "" || scan.FinishedAt == nil {
+		t.Fatalf("scan = %+v, want succeeded", scan)
+	}
+	want := []NetworkResponse{
+		{Status: "Current", OperatorName: "China Unicom", OperatorShortName: "CU", OperatorCode: "46001", AccessTechnologies: []string{"LTE"}},
+		{Status: "Available", OperatorName: "China Mobile", OperatorCode: "46000", AccessTechnologies: []string{"GSM"}},
+		{Status: "Forbidden", OperatorName: "China Telecom", OperatorCode: "46011", AccessTechnologies: []string{"LTE"}},
+	}
+	equal := func(a, b NetworkResponse) bool {
+		return a.Status == b.Status && a.OperatorName == b.OperatorName && a.OperatorShortName == b.OperatorShortName &&
+			a.OperatorCode == b.OperatorCode && slices.Equal(a.AccessTechnologies, b.AccessTechnologies)
+	}
+	if !slices.EqualFunc(scan.Networks, want, equal) {
+		t.Errorf("scan networks = %+v, want %+v", scan.Networks, want)
+	}
+
+	result := s.List(modem)
+	if result.ScannedAt == nil || !result.ScannedAt.Equal(*scan.FinishedAt) {
+		t.Errorf("List() scanned at %v, want %v", result.ScannedAt, scan.FinishedAt)
+	}
+	if !slices.EqualFunc(result.Networks, want, equal) {
+		t.Errorf("List() networks = %+v, want %+v", result.Networks, want)
+	}
+
+	if _, err := s.FindScan(modem, "unknown"); !errors.Is(err, errScanNotFound) {
+		t.Errorf("FindScan() of an unknown scan error = %v, want %v", err, errScanNotFound)
+	}
+	// A finished scan is replaced by the next one.
+	next := s.Scan(modem)
+	if next.ID == started.ID {
+		t.Errorf("Scan() after a finished scan = %s, want a new scan", next.ID)
+	}
+	wait(t, s, modem, next.ID)
+	if _, err := s.FindScan(modem, started.ID); !errors.Is(err, errScanNotFound) {
+		t.Errorf("FindScan() of a replaced scan error = %v, want %v", err, errScanNotFound)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name         string
+		operatorCode string
+		wantErr      bool
+		wantOperator string
+	}{
+		{name: "available network", operatorCode: " 46000 ", wantOperator: "46000"},
+		{name: "empty operator code", operatorCode: " ", wantErr: true, wantOperator: "46001"},
+		{name: "forbidden network", operatorCode: "46011", wantErr: true, wantOperator: "46001"},
+		{name: "unknown network", operatorCode: "46099", wantErr: true, wantOperator: "46001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := setup(t)
+			err := NewService(t.Context(), nil).Register(modem, tt.operatorCode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			operator, err := modem.ThreeGPP().OperatorCode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if operator != tt.wantOperator {
+				t.Errorf("operator after Register() = %q, want %q", operator, tt.wantOperator)
+			}
+		})
+	}
+}
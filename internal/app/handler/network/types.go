@@ -1,5 +1,7 @@
 package network
 
+import "time"
+
 type NetworkResponse struct {
 	Status             string   `json:"status"`
 	OperatorName       string   `json:"operatorName"`
@@ -7,3 +9,39 @@ type NetworkResponse struct {
 	OperatorCode       string   `json:"operatorCode"`
 	AccessTechnologies []string `json:"accessTechnologies"`
 }
+
+// NetworksResponse is the result of the last successful scan of a modem.
+type NetworksResponse struct {
+	ScannedAt *time.Time        `json:"scannedAt"`
+	Networks  []NetworkResponse `json:"networks"`
+}
+
+type ScanResponse struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Networks   []NetworkResponse `json:"networks"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type PolicyRequest struct {
+	// Operators are MCC and MNC codes, most preferred first.
+	Operators    []string `json:"operators"`
+	AllowRoaming *bool    `json:"allowRoaming" validate:"required"`
+}
+
+// PolicyResponse is a network policy of the modem, or of the SIM or profile active on it.
+type PolicyResponse struct {
+	Scope        string             `json:"scope"`
+	ICCID        string             `json:"iccid,omitempty"`
+	Operators    []OperatorResponse `json:"operators"`
+	AllowRoaming bool               `json:"allowRoaming"`
+	// Active is set on the policy that is applied, the SIM policy when there are both.
+	Active bool `json:"active"`
+}
+
+type OperatorResponse struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
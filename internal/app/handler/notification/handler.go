@@ -34,7 +34,7 @@ func (h *Handler) List(c echo.Context) error {
 	if err != nil {
 		return h.NotFound(c, err)
 	}
-	response, err := h.service.List(modem)
+	response, err := h.service.List(c.Request().Context(), modem)
 	if err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
@@ -53,7 +53,7 @@ func (h *Handler) Resend(c echo.Context) error {
 	if err != nil {
 		return h.BadRequest(c, err)
 	}
-	if err := h.service.Resend(modem, sequence); err != nil {
+	if err := h.service.Resend(c.Request().Context(), modem, sequence); err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
 		}
@@ -71,7 +71,7 @@ func (h *Handler) Delete(c echo.Context) error {
 	if err != nil {
 		return h.BadRequest(c, err)
 	}
-	if err := h.service.Delete(modem, sequence); err != nil {
+	if err := h.service.Delete(c.Request().Context(), modem, sequence); err != nil {
 		if errors.Is(err, lpa.ErrNoSupportedAID) {
 			return h.NotFound(c, err)
 		}
@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -19,8 +20,8 @@ func NewService(cfg *config.Config) *Service {
 	return &Service{cfg: cfg}
 }
 
-func (s *Service) List(modem *mmodem.Modem) ([]NotificationResponse, error) {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) List(ctx context.Context, modem *mmodem.Modem) ([]NotificationResponse, error) {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return nil, err
@@ -47,8 +48,8 @@ func (s *Service) List(modem *mmodem.Modem) ([]NotificationResponse, error) {
 	return response, nil
 }
 
-func (s *Service) Resend(modem *mmodem.Modem, sequence sgp22.SequenceNumber) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Resend(ctx context.Context, modem *mmodem.Modem, sequence sgp22.SequenceNumber) error {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
@@ -65,8 +66,8 @@ func (s *Service) Resend(modem *mmodem.Modem, sequence sgp22.SequenceNumber) err
 	return nil
 }
 
-func (s *Service) Delete(modem *mmodem.Modem, sequence sgp22.SequenceNumber) error {
-	client, err := lpa.New(modem, s.cfg)
+func (s *Service) Delete(ctx context.Context, modem *mmodem.Modem, sequence sgp22.SequenceNumber) error {
+	client, err := lpa.New(ctx, modem, s.cfg)
 	if err != nil {
 		slog.Error("failed to create LPA client", "modem", modem.EquipmentIdentifier, "error", err)
 		return err
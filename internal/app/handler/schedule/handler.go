@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/scheduler"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+type Handler struct {
+	handler.Handler
+	manager *mmodem.Manager
+	service *Service
+}
+
+func New(manager *mmodem.Manager, scheduler *scheduler.Scheduler) *Handler {
+	return &Handler{
+		manager: manager,
+		service: NewService(scheduler),
+	}
+}
+
+func (h *Handler) List(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	return h.Respond(c, h.service.List(modem.EquipmentIdentifier))
+}
+
+func (h *Handler) Create(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req ScheduleRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	response, err := h.service.Create(modem.EquipmentIdentifier, req)
+	if err != nil {
+		return h.scheduleError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) Update(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	var req ScheduleRequest
+	if err := h.BindAndValidate(c, &req); err != nil {
+		return err
+	}
+	response, err := h.service.Update(modem.EquipmentIdentifier, c.Param("scheduleId"), req)
+	if err != nil {
+		return h.scheduleError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) Delete(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	if err := h.service.Delete(modem.EquipmentIdentifier, c.Param("scheduleId")); err != nil {
+		return h.scheduleError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Run starts a schedule immediately. The run happens in the background; its outcome
+// appears in the schedule's runs.
+func (h *Handler) Run(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	if err := h.service.Run(modem.EquipmentIdentifier, c.Param("scheduleId")); err != nil {
+		return h.scheduleError(c, err)
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (h *Handler) ListRuns(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.ListRuns(modem.EquipmentIdentifier, c.Param("scheduleId"))
+	if err != nil {
+		return h.scheduleError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+func (h *Handler) scheduleError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, scheduler.ErrScheduleNotFound):
+		return h.NotFound(c, err)
+	case errors.Is(err, scheduler.ErrInvalidSchedule):
+		return h.BadRequest(c, err)
+	case errors.Is(err, scheduler.ErrModemBusy):
+		return h.Conflict(c, err)
+	}
+	return h.InternalServerError(c, err)
+}
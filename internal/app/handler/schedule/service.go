@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"github.com/damonto/sigmo/internal/app/scheduler"
+	"github.com/damonto/sigmo/internal/pkg/config"
+)
+
+type Service struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewService(scheduler *scheduler.Scheduler) *Service {
+	return &Service{scheduler: scheduler}
+}
+
+func (s *Service) List(modemID string) []ScheduleResponse {
+	schedules := s.scheduler.Schedules(modemID)
+	response := make([]ScheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		response = append(response, s.responseFrom(schedule))
+	}
+	return response
+}
+
+func (s *Service) Create(modemID string, req ScheduleRequest) (*ScheduleResponse, error) {
+	schedule, err := s.scheduler.Add(scheduleFrom(modemID, req))
+	if err != nil {
+		return nil, err
+	}
+	response := s.responseFrom(schedule)
+	return &response, nil
+}
+
+func (s *Service) Update(modemID, id string, req ScheduleRequest) (*ScheduleResponse, error) {
+	schedule, err := s.scheduler.Update(modemID, id, scheduleFrom(modemID, req))
+	if err != nil {
+		return nil, err
+	}
+	response := s.responseFrom(schedule)
+	return &response, nil
+}
+
+func (s *Service) Delete(modemID, id string) error {
+	return s.scheduler.Delete(modemID, id)
+}
+
+func (s *Service) Run(modemID, id string) error {
+	return s.scheduler.Trigger(modemID, id)
+}
+
+func (s *Service) ListRuns(modemID, id string) ([]RunResponse, error) {
+	if _, err := s.scheduler.Find(modemID, id); err != nil {
+		return nil, err
+	}
+	runs := s.scheduler.Runs(id)
+	response := make([]RunResponse, 0, len(runs))
+	for _, run := range runs {
+		steps := run.Steps
+		if steps == nil {
+			steps = []string{}
+		}
+		response = append(response, RunResponse{
+			Trigger:   run.Trigger,
+			StartedAt: run.StartedAt,
+			EndedAt:   run.EndedAt,
+			Previous:  run.Previous,
+			Steps:     steps,
+			Error:     run.Error,
+		})
+	}
+	return response, nil
+}
+
+func (s *Service) responseFrom(schedule config.Schedule) ScheduleResponse {
+	channels := schedule.Channels
+	if channels == nil {
+		channels = []string{}
+	}
+	response := ScheduleResponse{
+		ID:          schedule.ID,
+		Name:        schedule.Name,
+		Cron:        schedule.Cron,
+		ICCID:       schedule.ICCID,
+		USSD:        schedule.USSD,
+		SMSTo:       schedule.SMSTo,
+		SMSText:     schedule.SMSText,
+		SwitchBack:  schedule.SwitchBack,
+		HoldSeconds: schedule.HoldSeconds,
+		Channels:    channels,
+		Enabled:     schedule.Enabled,
+	}
+	if next := s.scheduler.Next(schedule.ID); schedule.Enabled && !next.IsZero() {
+		response.NextRunAt = &next
+	}
+	return response
+}
+
+func scheduleFrom(modemID string, req ScheduleRequest) config.Schedule {
+	return config.Schedule{
+		Name:        req.Name,
+		Modem:       modemID,
+		Cron:        req.Cron,
+		ICCID:       req.ICCID,
+		USSD:        req.USSD,
+		SMSTo:       req.SMSTo,
+		SMSText:     req.SMSText,
+		SwitchBack:  req.SwitchBack,
+		HoldSeconds: req.HoldSeconds,
+		Channels:    req.Channels,
+		Enabled:     *req.Enabled,
+	}
+}
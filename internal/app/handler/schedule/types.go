@@ -0,0 +1,40 @@
+package schedule
+
+import "time"
+
+type ScheduleRequest struct {
+	Name        string   `json:"name" validate:"max=64"`
+	Cron        string   `json:"cron" validate:"required"`
+	ICCID       string   `json:"iccid" validate:"required"`
+	USSD        string   `json:"ussd"`
+	SMSTo       string   `json:"smsTo"`
+	SMSText     string   `json:"smsText"`
+	SwitchBack  bool     `json:"switchBack"`
+	HoldSeconds int      `json:"holdSeconds" validate:"gte=0,lte=86400"`
+	Channels    []string `json:"channels"`
+	Enabled     *bool    `json:"enabled" validate:"required"`
+}
+
+type ScheduleResponse struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Cron        string     `json:"cron"`
+	ICCID       string     `json:"iccid"`
+	USSD        string     `json:"ussd,omitempty"`
+	SMSTo       string     `json:"smsTo,omitempty"`
+	SMSText     string     `json:"smsText,omitempty"`
+	SwitchBack  bool       `json:"switchBack"`
+	HoldSeconds int        `json:"holdSeconds"`
+	Channels    []string   `json:"channels"`
+	Enabled     bool       `json:"enabled"`
+	NextRunAt   *time.Time `json:"nextRunAt,omitempty"`
+}
+
+type RunResponse struct {
+	Trigger   string    `json:"trigger"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	Previous  string    `json:"previous,omitempty"`
+	Steps     []string  `json:"steps"`
+	Error     string    `json:"error,omitempty"`
+}
@@ -0,0 +1,60 @@
+package signal
+
+import (
+	"errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var errInvalidTime = errors.New("since and until must be RFC 3339 times")
+
+type Handler struct {
+	handler.Handler
+	manager *mmodem.Manager
+	service *Service
+}
+
+func New(manager *mmodem.Manager, recorder *telemetry.Recorder) *Handler {
+	return &Handler{
+		manager: manager,
+		service: NewService(recorder),
+	}
+}
+
+func (h *Handler) Get(c echo.Context) error {
+	modem, err := h.FindModem(h.manager, c.Param("id"))
+	if err != nil {
+		return h.NotFound(c, err)
+	}
+	response, err := h.service.Get(modem)
+	if err != nil {
+		return h.InternalServerError(c, err)
+	}
+	return h.Respond(c, response)
+}
+
+// History returns the recorded signal of a modem. It is kept in memory, so it
+// survives the modem being unplugged but not a restart.
+func (h *Handler) History(c echo.Context) error {
+	since, err := parseTime(c.QueryParam("since"))
+	if err != nil {
+		return h.BadRequest(c, errInvalidTime)
+	}
+	until, err := parseTime(c.QueryParam("until"))
+	if err != nil {
+		return h.BadRequest(c, errInvalidTime)
+	}
+	return h.Respond(c, h.service.History(c.Param("id"), since, until))
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,134 @@
+package signal
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+type Service struct {
+	recorder *telemetry.Recorder
+}
+
+func NewService(recorder *telemetry.Recorder) *Service {
+	return &Service{recorder: recorder}
+}
+
+func (s *Service) Get(modem *mmodem.Modem) (*SignalResponse, error) {
+	percent, _, err := modem.SignalQuality()
+	if err != nil {
+		slog.Error("failed to fetch signal quality", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	access, err := modem.AccessTechnologies()
+	if err != nil {
+		slog.Error("failed to fetch access technologies", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	response := &SignalResponse{
+		Quality:            percent,
+		AccessTechnologies: make([]string, 0, len(access)),
+	}
+	for _, tech := range access {
+		response.AccessTechnologies = append(response.AccessTechnologies, tech.String())
+	}
+
+	// Modems without the Signal interface still have the coarse quality.
+	signal := modem.Signal()
+	if response.RefreshRate, err = signal.Rate(); err != nil {
+		slog.Debug("detailed signal metrics not available", "modem", modem.EquipmentIdentifier, "error", err)
+		return response, nil
+	}
+	metrics, err := signal.Metrics()
+	if err != nil {
+		slog.Error("failed to fetch signal metrics", "modem", modem.EquipmentIdentifier, "error", err)
+		return nil, err
+	}
+	response.Metrics = buildMetricsResponse(metrics)
+	return response, nil
+}
+
+func (s *Service) History(id string, since, until time.Time) *HistoryResponse {
+	samples := s.recorder.History(id, since, until)
+	response := &HistoryResponse{
+		IntervalSeconds: int(s.recorder.Interval() / time.Second),
+		Samples:         make([]SampleResponse, 0, len(samples)),
+		Summary:         make(map[string]map[string]StatsResponse),
+	}
+	for _, sample := range samples {
+		metrics := buildMetricsResponse(&sample.Metrics)
+		response.Samples = append(response.Samples, SampleResponse{Time: sample.Time, Metrics: metrics})
+		for tech, measurement := range map[string]*MeasurementResponse{
+			"gsm":  metrics.GSM,
+			"umts": metrics.UMTS,
+			"lte":  metrics.LTE,
+			"nr5g": metrics.NR5G,
+		} {
+			if measurement != nil {
+				summarize(response.Summary, tech, measurement)
+			}
+		}
+	}
+	for _, stats := range response.Summary {
+		for name, stat := range stats {
+			stat.Average /= float64(stat.Count)
+			stats[name] = stat
+		}
+	}
+	return response
+}
+
+// summarize adds a measurement to the statistics of tech. Averages are left as sums.
+func summarize(summary map[string]map[string]StatsResponse, tech string, measurement *MeasurementResponse) {
+	for name, value := range map[string]*float64{
+		"rssi":      measurement.RSSI,
+		"rsrp":      measurement.RSRP,
+		"rsrq":      measurement.RSRQ,
+		"sinr":      measurement.SINR,
+		"rscp":      measurement.RSCP,
+		"ecio":      measurement.ECIO,
+		"errorRate": measurement.ErrorRate,
+	} {
+		if value == nil {
+			continue
+		}
+		if summary[tech] == nil {
+			summary[tech] = make(map[string]StatsResponse)
+		}
+		stats, ok := summary[tech][name]
+		if !ok {
+			stats = StatsResponse{Min: *value, Max: *value}
+		}
+		stats.Count++
+		stats.Min = min(stats.Min, *value)
+		stats.Max = max(stats.Max, *value)
+		stats.Average += *value
+		summary[tech][name] = stats
+	}
+}
+
+func buildMetricsResponse(metrics *mmodem.SignalMetrics) MetricsResponse {
+	return MetricsResponse{
+		GSM:  buildMeasurementResponse(metrics.GSM),
+		UMTS: buildMeasurementResponse(metrics.UMTS),
+		LTE:  buildMeasurementResponse(metrics.LTE),
+		NR5G: buildMeasurementResponse(metrics.NR5G),
+	}
+}
+
+func buildMeasurementResponse(measurement *mmodem.SignalMeasurement) *MeasurementResponse {
+	if measurement == nil {
+		return nil
+	}
+	return &MeasurementResponse{
+		RSSI:      measurement.RSSI,
+		RSRP:      measurement.RSRP,
+		RSRQ:      measurement.RSRQ,
+		SINR:      measurement.SINR,
+		RSCP:      measurement.RSCP,
+		ECIO:      measurement.ECIO,
+		ErrorRate: measurement.ErrorRate,
+	}
+}
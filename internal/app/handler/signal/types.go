@@ -0,0 +1,48 @@
+package signal
+
+import "time"
+
+type SignalResponse struct {
+	// Quality is the coarse signal quality in percent.
+	Quality            uint32          `json:"quality"`
+	AccessTechnologies []string        `json:"accessTechnologies"`
+	RefreshRate        uint32          `json:"refreshRate"`
+	Metrics            MetricsResponse `json:"metrics"`
+}
+
+type MetricsResponse struct {
+	GSM  *MeasurementResponse `json:"gsm,omitempty"`
+	UMTS *MeasurementResponse `json:"umts,omitempty"`
+	LTE  *MeasurementResponse `json:"lte,omitempty"`
+	NR5G *MeasurementResponse `json:"nr5g,omitempty"`
+}
+
+type MeasurementResponse struct {
+	RSSI      *float64 `json:"rssi,omitempty"`
+	RSRP      *float64 `json:"rsrp,omitempty"`
+	RSRQ      *float64 `json:"rsrq,omitempty"`
+	SINR      *float64 `json:"sinr,omitempty"`
+	RSCP      *float64 `json:"rscp,omitempty"`
+	ECIO      *float64 `json:"ecio,omitempty"`
+	ErrorRate *float64 `json:"errorRate,omitempty"`
+}
+
+type HistoryResponse struct {
+	IntervalSeconds int              `json:"intervalSeconds"`
+	Samples         []SampleResponse `json:"samples"`
+	// Summary holds the statistics of each metric over the samples, keyed by
+	// access technology and then by metric, e.g. summary.lte.rsrp.
+	Summary map[string]map[string]StatsResponse `json:"summary"`
+}
+
+type SampleResponse struct {
+	Time    time.Time       `json:"time"`
+	Metrics MetricsResponse `json:"metrics"`
+}
+
+type StatsResponse struct {
+	Count   int     `json:"count"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Average float64 `json:"average"`
+}
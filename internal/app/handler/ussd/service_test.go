@@ -0,0 +1,95 @@
+package ussd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/fakemm"
+	mmodem "github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var bus *fakemm.Bus
+
+func TestMain(m *testing.M) {
+	bus = fakemm.NewBus()
+	code := m.Run()
+	bus.Close()
+	os.Exit(code)
+}
+
+// setup adds a modem that answers USSD with menu to the fake ModemManager.
+func setup(t *testing.T, menu func(request string) (string, error)) *mmodem.Modem {
+	t.Helper()
+	_, modem := bus.AddModem(t, fakemm.ModemConfig{USSD: menu})
+	return modem
+}
+
+func menu(request string) (string, error) {
+	switch request {
+	case "*100#":
+		return "1. Balance 2. Data", nil
+	case "1":
+		return "Your balance is 10.00", nil
+	}
+	return "", fmt.Errorf("unknown request %q", request)
+}
+
+func TestExecute(t *testing.T) {
+	type step struct {
+		action  string
+		code    string
+		want    string
+		wantErr bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "menu",
+			steps: []step{
+				{action: actionInitialize, code: "*100#", want: "1. Balance 2. Data"},
+				{action: actionReply, code: "1", want: "Your balance is 10.00"},
+			},
+		},
+		{
+			name: "new session cancels the previous one",
+			steps: []step{
+				{action: actionInitialize, code: "*100#", want: "1. Balance 2. Data"},
+				{action: actionInitialize, code: "*100#", want: "1. Balance 2. Data"},
+			},
+		},
+		{
+			name:  "reply without session",
+			steps: []step{{action: actionReply, code: "1", wantErr: true}},
+		},
+		{
+			name:  "rejected code",
+			steps: []step{{action: actionInitialize, code: "*999#", wantErr: true}},
+		},
+		{
+			name:  "unknown action",
+			steps: []step{{action: "dial", code: "*100#", wantErr: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := setup(t, menu)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			service := NewService()
+			for i, step := range tt.steps {
+				response, err := service.Execute(ctx, modem, step.action, step.code)
+				if (err != nil) != step.wantErr {
+					t.Fatalf("step %d: Execute(%s, %s) error = %v, wantErr %v", i, step.action, step.code, err, step.wantErr)
+				}
+				if err == nil && response.Reply != step.want {
+					t.Errorf("step %d: Execute(%s, %s) = %q, want %q", i, step.action, step.code, response.Reply, step.want)
+				}
+			}
+		})
+	}
+}
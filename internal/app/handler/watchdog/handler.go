@@ -0,0 +1,23 @@
+package watchdog
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/app/handler"
+	"github.com/damonto/sigmo/internal/app/watchdog"
+)
+
+type Handler struct {
+	handler.Handler
+	service *Service
+}
+
+func New(w *watchdog.Watchdog) *Handler {
+	return &Handler{service: NewService(w)}
+}
+
+// Incidents lists the registration incidents of a modem, newest first. They are
+// kept after the modem is unplugged.
+func (h *Handler) Incidents(c echo.Context) error {
+	return h.Respond(c, h.service.Incidents(c.Param("id")))
+}
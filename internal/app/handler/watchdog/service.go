@@ -0,0 +1,30 @@
+package watchdog
+
+import "github.com/damonto/sigmo/internal/app/watchdog"
+
+type Service struct {
+	watchdog *watchdog.Watchdog
+}
+
+func NewService(w *watchdog.Watchdog) *Service {
+	return &Service{watchdog: w}
+}
+
+func (s *Service) Incidents(modemID string) []IncidentResponse {
+	incidents := s.watchdog.Incidents(modemID)
+	response := make([]IncidentResponse, 0, len(incidents))
+	for _, incident := range incidents {
+		attempts := make([]AttemptResponse, 0, len(incident.Attempts))
+		for _, attempt := range incident.Attempts {
+			attempts = append(attempts, AttemptResponse{Step: attempt.Step, At: attempt.At, Error: attempt.Error})
+		}
+		response = append(response, IncidentResponse{
+			ID:         incident.ID,
+			Reason:     incident.Reason,
+			StartedAt:  incident.StartedAt,
+			ResolvedAt: incident.ResolvedAt,
+			Attempts:   attempts,
+		})
+	}
+	return response
+}
@@ -0,0 +1,17 @@
+package watchdog
+
+import "time"
+
+type IncidentResponse struct {
+	ID         string            `json:"id"`
+	Reason     string            `json:"reason"`
+	StartedAt  time.Time         `json:"startedAt"`
+	ResolvedAt *time.Time        `json:"resolvedAt,omitempty"`
+	Attempts   []AttemptResponse `json:"attempts"`
+}
+
+type AttemptResponse struct {
+	Step  string    `json:"step"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
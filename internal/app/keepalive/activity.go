@@ -0,0 +1,74 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Activity records when each profile last sent or received an SMS, keyed by ICCID,
+// in a JSON file so that it survives restarts.
+type Activity struct {
+	mu   sync.Mutex
+	path string
+	last map[string]time.Time
+}
+
+// LoadActivity reads the activity file at path. A missing file is not an error.
+func LoadActivity(path string) (*Activity, error) {
+	a := &Activity{path: path, last: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return a, nil
+		}
+		return nil, fmt.Errorf("reading activity file: %w", err)
+	}
+	if err := json.Unmarshal(data, &a.last); err != nil {
+		return nil, fmt.Errorf("parsing activity file: %w", err)
+	}
+	return a, nil
+}
+
+// Last returns the last activity of a profile, or the zero time if none was seen.
+func (a *Activity) Last(iccid string) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last[normalizeICCID(iccid)]
+}
+
+// Touch records activity on a profile at t. Older activity than already recorded is ignored.
+func (a *Activity) Touch(iccid string, t time.Time) error {
+	iccid = normalizeICCID(iccid)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !t.After(a.last[iccid]) {
+		return nil
+	}
+	a.last[iccid] = t.UTC()
+	data, err := json.MarshalIndent(a.last, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding activity file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("creating activity directory: %w", err)
+	}
+	temp := a.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return fmt.Errorf("writing activity file: %w", err)
+	}
+	if err := os.Rename(temp, a.path); err != nil {
+		return fmt.Errorf("writing activity file: %w", err)
+	}
+	return nil
+}
+
+// normalizeICCID strips the padding some modems report in the SIM identifier.
+func normalizeICCID(iccid string) string {
+	return strings.TrimRight(strings.ToUpper(strings.TrimSpace(iccid)), "F")
+}
@@ -0,0 +1,553 @@
+// Package keepalive switches to rarely used profiles and uses them before they expire.
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+	"github.com/godbus/dbus/v5"
+
+	"github.com/damonto/sigmo/internal/app/scheduler"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/notify"
+)
+
+var (
+	ErrPolicyNotFound  = errors.New("keep-alive policy not found")
+	ErrInvalidPolicy   = errors.New("invalid keep-alive policy")
+	ErrAlreadyRunning  = errors.New("keep-alive is already running for this profile")
+	errNoAction        = errors.New("at least one of ussd or sms_to must be set")
+	errSMSIncomplete   = errors.New("sms_to and sms_text must be set together")
+	errInvalidInterval = errors.New("interval_days must be at least 1")
+	errProfileNotFound = errors.New("profile not found on any modem")
+)
+
+const (
+	// checkInterval is how often the SMS archive is scanned and policies are checked.
+	checkInterval = time.Minute
+	// retryInterval is how long to wait before retrying a failed keep-alive.
+	retryInterval = 6 * time.Hour
+	// runTimeout bounds a whole keep-alive, including both profile switches.
+	runTimeout = 10 * time.Minute
+)
+
+// Result is the outcome of the last keep-alive of a profile.
+type Result struct {
+	Modem string
+	Run   scheduler.Run
+}
+
+// Service runs the keep-alive policies in the config.
+type Service struct {
+	cfg       *config.Config
+	manager   *modem.Manager
+	scheduler *scheduler.Scheduler
+	notifier  *notify.Notifier
+	activity  *Activity
+	// started is when the service was created. Policies without a creation time
+	// count from it.
+	started time.Time
+
+	mu         sync.Mutex
+	watches    map[string]*watch // keyed by modem
+	generation uint64            // incremented on every modem event
+	locations  map[string]string // modem each profile was last seen on, keyed by ICCID
+	attempts   map[string]time.Time
+	results    map[string]Result
+	running    map[string]bool
+	wg         sync.WaitGroup
+}
+
+// watch tracks the messages of a modem while one profile is enabled on it.
+type watch struct {
+	iccid string
+	seen  map[dbus.ObjectPath]bool
+}
+
+func New(cfg *config.Config, manager *modem.Manager, sched *scheduler.Scheduler) (*Service, error) {
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating notifier: %w", err)
+	}
+	activity, err := LoadActivity(cfg.DataPath("activity.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		cfg:       cfg,
+		manager:   manager,
+		scheduler: sched,
+		notifier:  notifier,
+		activity:  activity,
+		started:   time.Now(),
+		watches:   make(map[string]*watch),
+		locations: make(map[string]string),
+		attempts:  make(map[string]time.Time),
+		results:   make(map[string]Result),
+		running:   make(map[string]bool),
+	}, nil
+}
+
+// Run tracks SMS activity and runs due keep-alives until ctx is canceled.
+func (s *Service) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	// Switching profiles makes ModemManager probe the modem again. Messages that show up
+	// around a switch cannot be told apart, so tracking starts over whenever a modem is
+	// added or removed.
+	unsubscribe, err := s.manager.Subscribe(func(event modem.ModemEvent) error {
+		if event.Type != modem.ModemEventAdded && event.Type != modem.ModemEventRemoved {
+			return nil
+		}
+		s.mu.Lock()
+		s.generation++
+		clear(s.watches)
+		s.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to modem manager: %w", err)
+	}
+	defer unsubscribe()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.scan()
+		s.check(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot is the enabled profile and stored messages of a modem at one point in time.
+type snapshot struct {
+	iccid    string
+	messages []*modem.SMS
+}
+
+// scan credits new messages in each modem's SMS archive to the profile enabled on it.
+// Messages that were already there when the profile was first seen enabled are not
+// credited, as they may have been received on another profile.
+func (s *Service) scan() {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+	modems, err := s.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	snapshots := make(map[string]snapshot, len(modems))
+	for _, m := range modems {
+		sim, err := m.SIMs().Primary()
+		if err != nil || sim.Identifier == "" {
+			continue
+		}
+		messages, err := m.Messaging().List()
+		if err != nil {
+			slog.Warn("failed to list messages", "modem", m.EquipmentIdentifier, "error", err)
+			continue
+		}
+		snapshots[m.EquipmentIdentifier] = snapshot{iccid: sim.Identifier, messages: messages}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return
+	}
+	for id := range s.watches {
+		if _, ok := snapshots[id]; !ok {
+			delete(s.watches, id)
+		}
+	}
+	for id, snapshot := range snapshots {
+		seen := make(map[dbus.ObjectPath]bool, len(snapshot.messages))
+		for _, message := range snapshot.messages {
+			seen[message.Path()] = true
+		}
+		w := s.watches[id]
+		s.watches[id] = &watch{iccid: snapshot.iccid, seen: seen}
+		s.locations[normalizeICCID(snapshot.iccid)] = id
+		if w == nil || w.iccid != snapshot.iccid {
+			continue
+		}
+		for _, message := range snapshot.messages {
+			if w.seen[message.Path()] {
+				continue
+			}
+			at := message.Timestamp
+			if at.IsZero() {
+				at = time.Now()
+			}
+			if err := s.activity.Touch(snapshot.iccid, at); err != nil {
+				slog.Error("failed to record profile activity", "iccid", snapshot.iccid, "error", err)
+			}
+		}
+	}
+}
+
+func (s *Service) check(ctx context.Context, now time.Time) {
+	policies := s.Policies()
+	s.mu.Lock()
+	due := make([]config.KeepAlive, 0)
+	for _, policy := range policies {
+		if !policy.Enabled || s.running[policy.ICCID] {
+			continue
+		}
+		if next := s.nextDue(policy); next.After(now) {
+			continue
+		}
+		if attempt, ok := s.attempts[policy.ICCID]; ok && now.Sub(attempt) < retryInterval {
+			continue
+		}
+		if !inWindows(policy.Windows, now) {
+			continue
+		}
+		due = append(due, policy)
+	}
+	s.mu.Unlock()
+	for _, policy := range due {
+		if err := s.start(ctx, policy); err != nil {
+			slog.Warn("skipping keep-alive", "iccid", policy.ICCID, "error", err)
+		}
+	}
+}
+
+// nextDue returns when policy is due: IntervalDays after the profile was last seen
+// active or, if it never was, after the policy was created.
+func (s *Service) nextDue(policy config.KeepAlive) time.Time {
+	since := s.activity.Last(policy.ICCID)
+	if since.IsZero() {
+		since = policy.CreatedAt
+	}
+	if since.IsZero() {
+		since = s.started
+	}
+	return since.AddDate(0, 0, policy.IntervalDays)
+}
+
+func (s *Service) start(ctx context.Context, policy config.KeepAlive) error {
+	s.mu.Lock()
+	if s.running[policy.ICCID] {
+		s.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	s.running[policy.ICCID] = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		// Like schedules, a keep-alive must not be left half done.
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runTimeout)
+		defer cancel()
+		result, err := s.perform(runCtx, policy)
+		s.mu.Lock()
+		delete(s.running, policy.ICCID)
+		if !errors.Is(err, scheduler.ErrModemBusy) {
+			s.attempts[policy.ICCID] = time.Now()
+		}
+		s.results[policy.ICCID] = result
+		s.mu.Unlock()
+		if errors.Is(err, scheduler.ErrModemBusy) {
+			slog.Info("keep-alive postponed, modem is busy", "iccid", policy.ICCID, "modem", result.Modem)
+			return
+		}
+		s.report(policy, result)
+	}()
+	return nil
+}
+
+func (s *Service) perform(ctx context.Context, policy config.KeepAlive) (Result, error) {
+	result := Result{Run: scheduler.Run{Trigger: "keep-alive", StartedAt: time.Now()}}
+	fail := func(err error) (Result, error) {
+		result.Run.EndedAt = time.Now()
+		result.Run.Error = err.Error()
+		slog.Error("keep-alive failed", "iccid", policy.ICCID, "modem", result.Modem, "error", err)
+		return result, err
+	}
+	id, err := s.locate(ctx, policy.ICCID)
+	if err != nil {
+		return fail(err)
+	}
+	result.Modem = id
+	run, err := s.scheduler.Perform(ctx, config.Schedule{
+		Modem:      result.Modem,
+		ICCID:      policy.ICCID,
+		USSD:       policy.USSD,
+		SMSTo:      policy.SMSTo,
+		SMSText:    policy.SMSText,
+		SwitchBack: true,
+	}, "keep-alive")
+	if err != nil {
+		return fail(err)
+	}
+	result.Run = run
+	if run.Error != "" {
+		return result, errors.New(run.Error)
+	}
+	if err := s.activity.Touch(policy.ICCID, run.EndedAt); err != nil {
+		slog.Error("failed to record profile activity", "iccid", policy.ICCID, "error", err)
+	}
+	return result, nil
+}
+
+// locate returns the modem holding a profile. The modem the profile was last seen
+// on is asked first and the others one at a time after it, so that a keep-alive
+// does not lock every eUICC.
+func (s *Service) locate(ctx context.Context, iccid string) (string, error) {
+	iccid = normalizeICCID(iccid)
+	modems, err := s.manager.Modems()
+	if err != nil {
+		return "", fmt.Errorf("listing modems: %w", err)
+	}
+	s.mu.Lock()
+	last := s.locations[iccid]
+	s.mu.Unlock()
+	candidates := make([]*modem.Modem, 0, len(modems))
+	for _, m := range modems {
+		candidates = append(candidates, m)
+	}
+	slices.SortFunc(candidates, func(a, b *modem.Modem) int {
+		return strings.Compare(a.EquipmentIdentifier, b.EquipmentIdentifier)
+	})
+	if i := slices.IndexFunc(candidates, func(m *modem.Modem) bool { return m.EquipmentIdentifier == last }); i > 0 {
+		m := candidates[i]
+		candidates = slices.Insert(slices.Delete(candidates, i, i+1), 0, m)
+	}
+	for _, m := range candidates {
+		found, err := s.holds(ctx, m, iccid)
+		if err != nil {
+			if !errors.Is(err, lpa.ErrNoSupportedAID) {
+				slog.Warn("failed to list profiles", "modem", m.EquipmentIdentifier, "error", err)
+			}
+			continue
+		}
+		if found {
+			s.mu.Lock()
+			s.locations[iccid] = m.EquipmentIdentifier
+			s.mu.Unlock()
+			return m.EquipmentIdentifier, nil
+		}
+	}
+	return "", errProfileNotFound
+}
+
+// holds reports whether the eUICC of m has the profile with iccid, already normalized.
+func (s *Service) holds(ctx context.Context, m *modem.Modem, iccid string) (bool, error) {
+	client, err := lpa.New(ctx, m, s.cfg)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			slog.Warn("failed to close LPA client", "error", cerr)
+		}
+	}()
+	profiles, err := client.ListProfile(nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(profiles, func(profile *sgp22.ProfileInfo) bool {
+		return normalizeICCID(profile.ICCID.String()) == iccid
+	}), nil
+}
+
+func (s *Service) report(policy config.KeepAlive, result Result) {
+	modemName := result.Modem
+	if alias := s.cfg.FindModem(result.Modem).Alias; alias != "" {
+		modemName = alias
+	}
+	var text string
+	if result.Run.Error == "" {
+		text = fmt.Sprintf("Keep-alive succeeded\nProfile: %s\nModem: %s\n\n%s",
+			policy.ICCID, modemName, strings.Join(result.Run.Steps, "\n"))
+	} else {
+		text = fmt.Sprintf("Keep-alive failed\nProfile: %s\nModem: %s\nRetry in: %s\n\n%s",
+			policy.ICCID, modemName, retryInterval, result.Run.Error)
+	}
+	if err := s.notifier.Send(notify.TextMessage{Text: text}, policy.Channels...); err != nil {
+		slog.Error("failed to send keep-alive notification", "iccid", policy.ICCID, "error", err)
+	}
+}
+
+// Policies returns the keep-alive policies.
+func (s *Service) Policies() []config.KeepAlive {
+	var policies []config.KeepAlive
+	s.cfg.View(func(c *config.Config) {
+		policies = slices.Clone(c.KeepAlives)
+	})
+	return policies
+}
+
+// Status returns the last activity, the next due time and the last result of a profile.
+func (s *Service) Status(policy config.KeepAlive) (time.Time, time.Time, *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last *Result
+	if result, ok := s.results[policy.ICCID]; ok {
+		last = &result
+	}
+	return s.activity.Last(policy.ICCID), s.nextDue(policy), last
+}
+
+// Put adds or replaces the policy of a profile and saves it to the config.
+func (s *Service) Put(policy config.KeepAlive) (config.KeepAlive, error) {
+	if err := validate(&policy); err != nil {
+		return config.KeepAlive{}, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := s.Policies()
+	if i := index(policies, policy.ICCID); i >= 0 {
+		policy.CreatedAt = policies[i].CreatedAt
+		policies[i] = policy
+	} else {
+		policy.CreatedAt = time.Now().UTC().Truncate(time.Second)
+		policies = append(policies, policy)
+	}
+	if err := s.save(policies); err != nil {
+		return config.KeepAlive{}, err
+	}
+	delete(s.attempts, policy.ICCID)
+	return policy, nil
+}
+
+// Delete removes the policy of a profile.
+func (s *Service) Delete(iccid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := s.Policies()
+	i := index(policies, iccid)
+	if i < 0 {
+		return ErrPolicyNotFound
+	}
+	if err := s.save(slices.Delete(policies, i, i+1)); err != nil {
+		return err
+	}
+	delete(s.attempts, iccid)
+	delete(s.results, iccid)
+	return nil
+}
+
+// Trigger runs the policy of a profile now, in the background, regardless of its windows.
+func (s *Service) Trigger(ctx context.Context, iccid string) error {
+	policies := s.Policies()
+	i := index(policies, iccid)
+	if i < 0 {
+		return ErrPolicyNotFound
+	}
+	return s.start(ctx, policies[i])
+}
+
+func index(policies []config.KeepAlive, iccid string) int {
+	return slices.IndexFunc(policies, func(policy config.KeepAlive) bool {
+		return policy.ICCID == iccid
+	})
+}
+
+// save writes policies to the config, keeping the previous policies if that fails.
+// It must be called with s.mu held, so that policies are not changed in between
+// being read and saved.
+func (s *Service) save(policies []config.KeepAlive) error {
+	err := s.cfg.Update(func(c *config.Config) error {
+		c.KeepAlives = policies
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save config", "error", err)
+	}
+	return err
+}
+
+func validate(policy *config.KeepAlive) error {
+	policy.ICCID = strings.TrimSpace(policy.ICCID)
+	policy.USSD = strings.TrimSpace(policy.USSD)
+	policy.SMSTo = strings.TrimSpace(policy.SMSTo)
+	iccid, err := sgp22.NewICCID(policy.ICCID)
+	if err != nil {
+		return fmt.Errorf("invalid iccid: %w", err)
+	}
+	policy.ICCID = iccid.String()
+	if policy.IntervalDays < 1 {
+		return errInvalidInterval
+	}
+	if (policy.SMSTo == "") != (strings.TrimSpace(policy.SMSText) == "") {
+		return errSMSIncomplete
+	}
+	if policy.USSD == "" && policy.SMSTo == "" {
+		return errNoAction
+	}
+	for i, window := range policy.Windows {
+		policy.Windows[i] = strings.TrimSpace(window)
+		if _, _, err := parseWindow(policy.Windows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inWindows reports whether t falls within any of windows. No windows means any time.
+func inWindows(windows []string, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range windows {
+		start, end, err := parseWindow(window)
+		if err != nil {
+			continue
+		}
+		if start <= end && minute >= start && minute < end {
+			return true
+		}
+		if start > end && (minute >= start || minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWindow parses "HH:MM-HH:MM" into minutes since midnight.
+func parseWindow(window string) (int, int, error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window %q, must be HH:MM-HH:MM", window)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid window %q: %w", window, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid window %q: %w", window, err)
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid window %q, start and end must differ", window)
+	}
+	return start, end, nil
+}
+
+func parseClock(clock string) (int, error) {
+	hour, minute, ok := strings.Cut(strings.TrimSpace(clock), ":")
+	h, herr := strconv.Atoi(hour)
+	m, merr := strconv.Atoi(minute)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 24 || m < 0 || m > 59 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time %q", clock)
+	}
+	return h*60 + m, nil
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 
@@ -15,21 +16,45 @@ const bearerPrefix = "Bearer "
 func Auth(store *auth.Store) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			header := c.Request().Header.Get("Authorization")
-			token := ""
-			if after, ok := strings.CutPrefix(header, bearerPrefix); ok {
-				token = strings.TrimSpace(after)
-			}
-			if token == "" {
-				token = strings.TrimSpace(c.QueryParam("token"))
-			}
+			token := requestToken(c)
 			if token == "" || !store.ValidateToken(token) {
-				return c.JSON(http.StatusUnauthorized, handler.HTTPError{
-					Code:    http.StatusUnauthorized,
-					Message: "missing or invalid token",
-				})
+				return unauthorized(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// StaticToken only lets requests through that carry the given token, for
+// clients such as Prometheus that cannot log in with an OTP.
+func StaticToken(expected string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := requestToken(c)
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+				return unauthorized(c)
 			}
 			return next(c)
 		}
 	}
 }
+
+// requestToken returns the bearer token of a request, or its token query parameter.
+func requestToken(c echo.Context) string {
+	header := c.Request().Header.Get("Authorization")
+	token := ""
+	if after, ok := strings.CutPrefix(header, bearerPrefix); ok {
+		token = strings.TrimSpace(after)
+	}
+	if token == "" {
+		token = strings.TrimSpace(c.QueryParam("token"))
+	}
+	return token
+}
+
+func unauthorized(c echo.Context) error {
+	return c.JSON(http.StatusUnauthorized, handler.HTTPError{
+		Code:    http.StatusUnauthorized,
+		Message: "missing or invalid token",
+	})
+}
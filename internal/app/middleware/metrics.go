@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/pkg/metrics"
+)
+
+var requestDuration = metrics.NewHistogram("sigmo_http_request_duration_seconds", "HTTP request latency by route and status.", metrics.DefaultBuckets, "method", "route", "status")
+
+// Metrics records the latency of each request under its route pattern rather than
+// its path, so that IDs in paths do not create a series per modem or profile.
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = http.StatusInternalServerError
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				}
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			requestDuration.Observe(time.Since(start).Seconds(), c.Request().Method, route, strconv.Itoa(status))
+			return err
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/damonto/sigmo/internal/pkg/keymutex"
+)
+
+// Operation names the request context after the matched route, so that
+// a request holding an eUICC lock can be identified by the ones waiting for it.
+func Operation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			c.SetRequest(req.WithContext(keymutex.WithOperation(req.Context(), req.Method+" "+c.Path())))
+			return next(c)
+		}
+	}
+}
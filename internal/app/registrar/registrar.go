@@ -0,0 +1,314 @@
+// Package registrar keeps modems registered on the networks preferred by the
+// network policies in the config.
+package registrar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+var (
+	ErrPolicyNotFound  = errors.New("network policy not found")
+	ErrInvalidPolicy   = errors.New("invalid network policy")
+	errTarget          = errors.New("exactly one of modem or iccid must be set")
+	errInvalidOperator = errors.New("operator code must be 5 or 6 digits")
+	errNoHomeNetwork   = errors.New("home network of the SIM is unknown")
+)
+
+const (
+	// checkInterval is how often every modem is checked against its policy.
+	checkInterval = time.Minute
+	// retryInterval is how long to leave a modem alone after moving it, or after
+	// failing to. Every attempt on an unavailable operator interrupts the
+	// registration for a moment, so they are kept rare.
+	retryInterval = 15 * time.Minute
+)
+
+var registrations = metrics.NewCounter("sigmo_network_policy_registrations_total", "Registrations made by network policies by operator and result.", "modem", "operator", "result")
+
+var operatorRE = regexp.MustCompile(`^[0-9]{5,6}$`)
+
+// Service applies the network policies every check interval and whenever a modem
+// is added or its SIM changes.
+type Service struct {
+	cfg     *config.Config
+	manager *modem.Manager
+	wake    chan struct{}
+
+	mu   sync.Mutex
+	next map[string]time.Time // keyed by modem, when it may be moved again
+}
+
+func New(cfg *config.Config, manager *modem.Manager) *Service {
+	return &Service{
+		cfg:     cfg,
+		manager: manager,
+		wake:    make(chan struct{}, 1),
+		next:    make(map[string]time.Time),
+	}
+}
+
+// Run applies the policies until ctx is canceled.
+func (s *Service) Run(ctx context.Context) error {
+	unsubscribe, err := s.manager.Subscribe(func(event modem.ModemEvent) error {
+		if event.Type != modem.ModemEventAdded && event.Type != modem.ModemEventSimChanged {
+			return nil
+		}
+		// A new modem or profile starts with its own policy, so it is not held
+		// back by an attempt made for the previous one.
+		if event.Modem != nil {
+			s.mu.Lock()
+			delete(s.next, event.Modem.EquipmentIdentifier)
+			s.mu.Unlock()
+		}
+		s.poke()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to modem manager: %w", err)
+	}
+	defer unsubscribe()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		s.check(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *Service) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) check(now time.Time) {
+	policies := s.Policies()
+	if len(policies) == 0 {
+		return
+	}
+	modems, err := s.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	for _, m := range modems {
+		id := m.EquipmentIdentifier
+		// Profile switches drop the registration, and would be undone by moving the modem.
+		if lpa.Busy(id) || m.State < modem.ModemStateEnabled {
+			continue
+		}
+		var iccid string
+		if m.Sim != nil {
+			iccid = m.Sim.Identifier
+		}
+		policy, ok := find(policies, id, iccid)
+		s.mu.Lock()
+		due := !now.Before(s.next[id])
+		s.mu.Unlock()
+		if !ok || !due {
+			continue
+		}
+		if s.apply(m, policy) {
+			s.mu.Lock()
+			s.next[id] = time.Now().Add(retryInterval)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// apply moves m to the network policy prefers, and reports whether it tried to.
+func (s *Service) apply(m *modem.Modem, policy config.NetworkPolicy) bool {
+	state, err := m.ThreeGPP().RegistrationState()
+	if err != nil {
+		slog.Warn("failed to read registration state", "modem", m.EquipmentIdentifier, "error", err)
+		return false
+	}
+	var operator string
+	if state.Registered() {
+		operator, _ = m.ThreeGPP().OperatorCode()
+	}
+	rank := slices.Index(policy.Operators, operator)
+	if state.Registered() && rank == 0 {
+		return false
+	}
+	acceptable := rank > 0 || state.Registered() && (policy.AllowRoaming || !state.Roaming())
+	candidates := policy.Operators
+	if rank > 0 {
+		candidates = policy.Operators[:rank]
+	}
+	if acceptable && len(candidates) == 0 {
+		return false
+	}
+	for _, code := range candidates {
+		if s.register(m, code) == nil {
+			return true
+		}
+	}
+	// None of the preferred operators took the modem, which may have lost its
+	// registration trying. Go back to the best network that is allowed.
+	switch {
+	case rank > 0:
+		_ = s.register(m, operator)
+	case policy.AllowRoaming:
+		_ = s.register(m, "")
+	default:
+		home := ""
+		if sim, err := m.SIMs().Primary(); err == nil {
+			home = sim.OperatorIdentifier
+		}
+		if home == "" {
+			slog.Warn("failed to apply network policy", "modem", m.EquipmentIdentifier, "error", errNoHomeNetwork)
+			return true
+		}
+		_ = s.register(m, home)
+	}
+	return true
+}
+
+// register registers m on operator, or on any network when operator is empty.
+func (s *Service) register(m *modem.Modem, operator string) error {
+	slog.Info("registering modem by network policy", "modem", m.EquipmentIdentifier, "operator", operator)
+	err := m.ThreeGPP().RegisterNetwork(operator)
+	label := operator
+	if label == "" {
+		label = "automatic"
+	}
+	registrations.Inc(m.EquipmentIdentifier, label, metrics.Result(err))
+	if err != nil {
+		slog.Warn("failed to register modem by network policy", "modem", m.EquipmentIdentifier, "operator", label, "error", err)
+	}
+	return err
+}
+
+// Policies returns the network policies.
+func (s *Service) Policies() []config.NetworkPolicy {
+	var policies []config.NetworkPolicy
+	s.cfg.View(func(c *config.Config) {
+		policies = slices.Clone(c.NetworkPolicies)
+	})
+	return policies
+}
+
+// Find returns the policy that applies to a modem with the profile iccid active.
+func (s *Service) Find(modemID, iccid string) (config.NetworkPolicy, bool) {
+	return find(s.Policies(), modemID, iccid)
+}
+
+func find(policies []config.NetworkPolicy, modemID, iccid string) (config.NetworkPolicy, bool) {
+	if iccid != "" {
+		if i := index(policies, "", iccid); i >= 0 {
+			return policies[i], true
+		}
+	}
+	if i := index(policies, modemID, ""); i >= 0 {
+		return policies[i], true
+	}
+	return config.NetworkPolicy{}, false
+}
+
+// Put adds or replaces the policy of a modem or profile and saves it to the config.
+// Modems it applies to are checked again straight away.
+func (s *Service) Put(policy config.NetworkPolicy) (config.NetworkPolicy, error) {
+	if err := validate(&policy); err != nil {
+		return config.NetworkPolicy{}, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := s.Policies()
+	if i := index(policies, policy.Modem, policy.ICCID); i >= 0 {
+		policies[i] = policy
+	} else {
+		policies = append(policies, policy)
+	}
+	if err := s.save(policies); err != nil {
+		return config.NetworkPolicy{}, err
+	}
+	clear(s.next)
+	s.poke()
+	return policy, nil
+}
+
+// Delete removes the policy of a modem, or of a profile when iccid is set.
+// Modems are left on the network they are registered on.
+func (s *Service) Delete(modemID, iccid string) error {
+	if iccid != "" {
+		modemID = ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := s.Policies()
+	i := index(policies, modemID, iccid)
+	if i < 0 {
+		return ErrPolicyNotFound
+	}
+	return s.save(slices.Delete(policies, i, i+1))
+}
+
+func index(policies []config.NetworkPolicy, modemID, iccid string) int {
+	return slices.IndexFunc(policies, func(policy config.NetworkPolicy) bool {
+		return policy.Modem == modemID && policy.ICCID == iccid
+	})
+}
+
+// save writes policies to the config, keeping the previous policies if that fails.
+// It must be called with s.mu held, so that policies are not changed in between
+// being read and saved.
+func (s *Service) save(policies []config.NetworkPolicy) error {
+	err := s.cfg.Update(func(c *config.Config) error {
+		c.NetworkPolicies = policies
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save config", "error", err)
+	}
+	return err
+}
+
+func validate(policy *config.NetworkPolicy) error {
+	policy.Modem = strings.TrimSpace(policy.Modem)
+	policy.ICCID = strings.TrimSpace(policy.ICCID)
+	if (policy.Modem == "") == (policy.ICCID == "") {
+		return errTarget
+	}
+	if policy.ICCID != "" {
+		iccid, err := sgp22.NewICCID(policy.ICCID)
+		if err != nil {
+			return fmt.Errorf("invalid iccid: %w", err)
+		}
+		policy.ICCID = iccid.String()
+	}
+	operators := make([]string, 0, len(policy.Operators))
+	for _, operator := range policy.Operators {
+		operator = strings.TrimSpace(operator)
+		if !operatorRE.MatchString(operator) {
+			return fmt.Errorf("%w: %q", errInvalidOperator, operator)
+		}
+		if !slices.Contains(operators, operator) {
+			operators = append(operators, operator)
+		}
+	}
+	policy.Operators = operators
+	return nil
+}
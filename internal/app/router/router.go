@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -8,21 +9,35 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 
 	"github.com/damonto/sigmo/internal/app/auth"
+	"github.com/damonto/sigmo/internal/app/forwarder"
 	hauth "github.com/damonto/sigmo/internal/app/handler/auth"
+	"github.com/damonto/sigmo/internal/app/handler/debug"
 	"github.com/damonto/sigmo/internal/app/handler/esim"
 	"github.com/damonto/sigmo/internal/app/handler/euicc"
+	"github.com/damonto/sigmo/internal/app/handler/health"
+	hkeepalive "github.com/damonto/sigmo/internal/app/handler/keepalive"
 	"github.com/damonto/sigmo/internal/app/handler/message"
+	hmetrics "github.com/damonto/sigmo/internal/app/handler/metrics"
 	hmodem "github.com/damonto/sigmo/internal/app/handler/modem"
 	"github.com/damonto/sigmo/internal/app/handler/network"
 	"github.com/damonto/sigmo/internal/app/handler/notification"
+	"github.com/damonto/sigmo/internal/app/handler/schedule"
+	"github.com/damonto/sigmo/internal/app/handler/signal"
 	"github.com/damonto/sigmo/internal/app/handler/ussd"
+	hwatchdog "github.com/damonto/sigmo/internal/app/handler/watchdog"
+	"github.com/damonto/sigmo/internal/app/keepalive"
 	appmiddleware "github.com/damonto/sigmo/internal/app/middleware"
+	"github.com/damonto/sigmo/internal/app/registrar"
+	"github.com/damonto/sigmo/internal/app/scheduler"
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	"github.com/damonto/sigmo/internal/app/watchdog"
+	"github.com/damonto/sigmo/internal/pkg/audit"
 	"github.com/damonto/sigmo/internal/pkg/config"
 	"github.com/damonto/sigmo/internal/pkg/modem"
 	"github.com/damonto/sigmo/web"
 )
 
-func Register(e *echo.Echo, cfg *config.Config, manager *modem.Manager) {
+func Register(ctx context.Context, e *echo.Echo, cfg *config.Config, manager *modem.Manager, registry *modem.Registry, sched *scheduler.Scheduler, keeper *keepalive.Service, recorder *telemetry.Recorder, relay *forwarder.Relay, dog *watchdog.Watchdog, reg *registrar.Service) {
 	e.Use(middleware.StaticWithConfig(middleware.StaticConfig{
 		Filesystem: http.FS(web.Root()),
 		Index:      "index.html",
@@ -33,24 +48,65 @@ func Register(e *echo.Echo, cfg *config.Config, manager *modem.Manager) {
 		},
 	}))
 
+	{
+		h := health.New(cfg, manager, registry, relay)
+		e.GET("/healthz", h.Health)
+		e.GET("/readyz", h.Ready)
+	}
+
+	if cfg.Metrics.Enabled {
+		h := hmetrics.New(manager, registry, recorder)
+		var middlewares []echo.MiddlewareFunc
+		if cfg.Metrics.Token != "" {
+			middlewares = append(middlewares, appmiddleware.StaticToken(cfg.Metrics.Token))
+		}
+		e.GET("/metrics", h.Get, middlewares...)
+	}
+
 	v1 := e.Group("/api/v1")
+	v1.Use(appmiddleware.Metrics())
 
 	authStore := auth.NewStore()
 	authHandler := hauth.New(cfg, authStore)
 	v1.GET("/auth/otp/required", authHandler.OTPRequirement)
 	v1.POST("/auth/otp", authHandler.SendOTP)
 	v1.POST("/auth/otp/verify", authHandler.VerifyOTP)
+	auditLog := audit.New(cfg.DataPath(audit.FileName))
 	protected := v1.Group("")
 	if cfg.App.OTPRequired {
 		protected.Use(appmiddleware.Auth(authStore))
 	}
+	protected.Use(appmiddleware.Operation())
+
+	{
+		h := debug.New()
+		protected.GET("/debug/locks", h.Locks)
+	}
+
+	{
+		h := esim.New(cfg, manager)
+		protected.GET("/esims", h.Inventory)
+	}
 
 	{
-		h := hmodem.New(cfg, manager)
+		h := hkeepalive.New(keeper)
+		protected.GET("/keep-alives", h.List)
+		protected.PUT("/keep-alives/:iccid", h.Put)
+		protected.DELETE("/keep-alives/:iccid", h.Delete)
+		protected.POST("/keep-alives/:iccid/runs", h.Run)
+	}
+
+	{
+		h := hmodem.New(cfg, manager, registry)
 		protected.GET("/modems", h.List)
 		protected.GET("/modems/:id", h.Get)
+		protected.DELETE("/modems/:id", h.Forget)
 		protected.PUT("/modems/:id/sim-slots/:identifier", h.SwitchSimSlot)
 		protected.PUT("/modems/:id/msisdn", h.UpdateMSISDN)
+		protected.GET("/modems/:id/sim/plmn", h.GetPLMNs)
+		protected.PUT("/modems/:id/sim/plmn", h.UpdatePLMNs)
+		protected.GET("/modems/:id/sim/fplmn", h.GetForbiddenNetworks)
+		protected.DELETE("/modems/:id/sim/fplmn", h.ClearForbiddenNetworks)
 		protected.GET("/modems/:id/settings", h.GetSettings)
 		protected.PUT("/modems/:id/settings", h.UpdateSettings)
 
@@ -68,14 +124,37 @@ func Register(e *echo.Echo, cfg *config.Config, manager *modem.Manager) {
 		}
 
 		{
-			h := network.New(manager)
+			h := network.New(ctx, manager, reg)
 			protected.GET("/modems/:id/networks", h.List)
+			protected.POST("/modems/:id/networks/scans", h.Scan)
+			protected.GET("/modems/:id/networks/scans/:scanId", h.GetScan)
+			protected.PUT("/modems/:id/networks/automatic", h.RegisterAutomatic)
+			protected.GET("/modems/:id/networks/policies", h.ListPolicies)
+			protected.PUT("/modems/:id/networks/policies/:scope", h.PutPolicy)
+			protected.DELETE("/modems/:id/networks/policies/:scope", h.DeletePolicy)
 			protected.PUT("/modems/:id/networks/:operatorCode", h.Register)
 		}
 
 		{
-			h := euicc.New(cfg, manager)
+			h := signal.New(manager, recorder)
+			protected.GET("/modems/:id/signal", h.Get)
+			protected.GET("/modems/:id/signal/history", h.History)
+		}
+
+		{
+			h := hwatchdog.New(dog)
+			protected.GET("/modems/:id/incidents", h.Incidents)
+		}
+
+		{
+			h := euicc.New(cfg, manager, auditLog)
 			protected.GET("/modems/:id/euicc", h.Get)
+			protected.GET("/modems/:id/euicc/details", h.GetDetails)
+			protected.GET("/modems/:id/euicc/addresses", h.GetAddresses)
+			protected.PUT("/modems/:id/euicc/addresses", h.UpdateAddresses)
+			protected.POST("/modems/:id/euicc/memory-reset", h.ResetMemory)
+			protected.GET("/modems/:id/euicc/traces", h.ListTraces)
+			protected.GET("/modems/:id/euicc/traces/:traceId", h.GetTrace)
 		}
 
 		{
@@ -83,6 +162,8 @@ func Register(e *echo.Echo, cfg *config.Config, manager *modem.Manager) {
 			protected.GET("/modems/:id/esims", h.List)
 			protected.GET("/modems/:id/esims/discover", h.Discover)
 			protected.GET("/modems/:id/esims/download", h.Download)
+			protected.GET("/modems/:id/esims/export", h.Export)
+			protected.POST("/modems/:id/esims/import", h.Import)
 			protected.POST("/modems/:id/esims/:iccid/enabling", h.Enable)
 			protected.PUT("/modems/:id/esims/:iccid/nickname", h.UpdateNickname)
 			protected.DELETE("/modems/:id/esims/:iccid", h.Delete)
@@ -94,5 +175,15 @@ func Register(e *echo.Echo, cfg *config.Config, manager *modem.Manager) {
 			protected.POST("/modems/:id/notifications/:sequence/resend", h.Resend)
 			protected.DELETE("/modems/:id/notifications/:sequence", h.Delete)
 		}
+
+		{
+			h := schedule.New(manager, sched)
+			protected.GET("/modems/:id/schedules", h.List)
+			protected.POST("/modems/:id/schedules", h.Create)
+			protected.PUT("/modems/:id/schedules/:scheduleId", h.Update)
+			protected.DELETE("/modems/:id/schedules/:scheduleId", h.Delete)
+			protected.POST("/modems/:id/schedules/:scheduleId/runs", h.Run)
+			protected.GET("/modems/:id/schedules/:scheduleId/runs", h.ListRuns)
+		}
 	}
 }
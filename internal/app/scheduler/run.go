@@ -0,0 +1,196 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/notify"
+)
+
+// execute switches to the schedule's profile, runs its USSD code and SMS, and switches
+// back when asked to. The previous profile is restored even if an action fails.
+func (s *Scheduler) execute(ctx context.Context, schedule config.Schedule, trigger string) Run {
+	run := Run{
+		ScheduleID: schedule.ID,
+		Modem:      schedule.Modem,
+		Trigger:    trigger,
+		StartedAt:  time.Now(),
+	}
+	slog.Info("running schedule", "schedule", schedule.ID, "modem", schedule.Modem, "iccid", schedule.ICCID, "trigger", trigger)
+	err := s.perform(ctx, schedule, &run)
+	run.EndedAt = time.Now()
+	if err != nil {
+		slog.Error("schedule failed", "schedule", schedule.ID, "modem", schedule.Modem, "error", err)
+		run.Error = err.Error()
+		return run
+	}
+	slog.Info("schedule finished", "schedule", schedule.ID, "modem", schedule.Modem)
+	return run
+}
+
+func (s *Scheduler) perform(ctx context.Context, schedule config.Schedule, run *Run) (err error) {
+	target, _ := sgp22.NewICCID(schedule.ICCID)
+	m, err := s.findModem(schedule.Modem)
+	if err != nil {
+		return err
+	}
+	profiles, err := s.esim.List(ctx, m)
+	if err != nil {
+		return fmt.Errorf("listing profiles: %w", err)
+	}
+	found := false
+	for _, profile := range profiles {
+		if profile.ICCID == target.String() {
+			found = true
+		}
+		if profile.ProfileState == uint8(sgp22.ProfileEnabled) {
+			run.Previous = profile.ICCID
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", errProfileNotFound, target)
+	}
+
+	if run.Previous != target.String() {
+		if err := s.enable(ctx, m, target); err != nil {
+			return err
+		}
+		run.Steps = append(run.Steps, "enabled "+target.String())
+		if schedule.SwitchBack && run.Previous != "" {
+			defer func() {
+				previous, _ := sgp22.NewICCID(run.Previous)
+				if serr := s.switchBack(ctx, schedule, previous); serr != nil {
+					err = errors.Join(err, serr)
+					return
+				}
+				run.Steps = append(run.Steps, "switched back to "+run.Previous)
+			}()
+		}
+	} else {
+		run.Steps = append(run.Steps, target.String()+" already enabled")
+	}
+
+	if schedule.USSD == "" && schedule.SMSTo == "" {
+		return nil
+	}
+	// Enabling restarts the modem, so look it up again.
+	if m, err = s.findModem(schedule.Modem); err != nil {
+		return err
+	}
+	if err := waitForRegistration(ctx, m); err != nil {
+		return err
+	}
+	if schedule.USSD != "" {
+		actionCtx, cancel := context.WithTimeout(ctx, actionTimeout)
+		response, err := s.ussd.Execute(actionCtx, m, "initialize", schedule.USSD)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("running USSD %s: %w", schedule.USSD, err)
+		}
+		run.Steps = append(run.Steps, fmt.Sprintf("USSD %s: %s", schedule.USSD, response.Reply))
+	}
+	if schedule.SMSTo != "" {
+		if err := s.message.Send(m, schedule.SMSTo, schedule.SMSText); err != nil {
+			return fmt.Errorf("sending SMS to %s: %w", schedule.SMSTo, err)
+		}
+		run.Steps = append(run.Steps, "sent SMS to "+schedule.SMSTo)
+	}
+	return nil
+}
+
+func (s *Scheduler) switchBack(ctx context.Context, schedule config.Schedule, previous sgp22.ICCID) error {
+	if schedule.HoldSeconds > 0 {
+		select {
+		case <-time.After(time.Duration(schedule.HoldSeconds) * time.Second):
+		case <-ctx.Done():
+			// Shutting down ends the hold early; the previous profile is still enabled.
+		}
+	}
+	m, err := s.findModem(schedule.Modem)
+	if err != nil {
+		return err
+	}
+	if err := s.enable(ctx, m, previous); err != nil {
+		return fmt.Errorf("switching back: %w", err)
+	}
+	return nil
+}
+
+// enable enables iccid and waits for the modem to restart. It is not interrupted
+// when ctx is canceled, as a switch must not be left half done.
+func (s *Scheduler) enable(ctx context.Context, m *modem.Modem, iccid sgp22.ICCID) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), switchTimeout)
+	defer cancel()
+	if err := s.esim.Enable(ctx, m, iccid); err != nil {
+		return fmt.Errorf("enabling %s: %w", iccid, err)
+	}
+	return nil
+}
+
+func (s *Scheduler) findModem(id string) (*modem.Modem, error) {
+	modems, err := s.manager.Modems()
+	if err != nil {
+		return nil, fmt.Errorf("listing modems: %w", err)
+	}
+	for _, m := range modems {
+		if m.EquipmentIdentifier == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("modem with ID %s not found", id)
+}
+
+// waitForRegistration polls the modem until it is registered on a network.
+func waitForRegistration(ctx context.Context, m *modem.Modem) error {
+	ctx, cancel := context.WithTimeout(ctx, registrationTimeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		state, err := m.ThreeGPP().RegistrationState()
+		if err == nil && registered(state) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("modem did not register within %s", registrationTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+func registered(state modem.Modem3gppRegistrationState) bool {
+	switch state {
+	case modem.Modem3gppRegistrationStateHome,
+		modem.Modem3gppRegistrationStateRoaming,
+		modem.Modem3gppRegistrationStateHomeSmsOnly,
+		modem.Modem3gppRegistrationStateRoamingSmsOnly:
+		return true
+	}
+	return false
+}
+
+func (s *Scheduler) notifyFailure(schedule config.Schedule, run Run) {
+	name := schedule.Name
+	if name == "" {
+		name = schedule.ID
+	}
+	modemName := schedule.Modem
+	if alias := s.cfg.FindModem(schedule.Modem).Alias; alias != "" {
+		modemName = alias
+	}
+	message := notify.TextMessage{
+		Text: fmt.Sprintf("Schedule %q failed\nModem: %s\nProfile: %s\nTime: %s\n\n%s",
+			name, modemName, schedule.ICCID, run.StartedAt.Format(time.RFC3339), run.Error),
+	}
+	if err := s.notifier.Send(message, schedule.Channels...); err != nil {
+		slog.Error("failed to send schedule failure notification", "schedule", schedule.ID, "error", err)
+	}
+}
@@ -0,0 +1,358 @@
+// Package scheduler switches eSIM profiles at the times configured in schedules.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	sgp22 "github.com/damonto/euicc-go/v2"
+
+	"github.com/damonto/sigmo/internal/app/handler/esim"
+	"github.com/damonto/sigmo/internal/app/handler/message"
+	"github.com/damonto/sigmo/internal/app/handler/ussd"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/cron"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/notify"
+	"github.com/damonto/sigmo/internal/pkg/randid"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	ErrModemBusy        = errors.New("another profile switch is running on this modem")
+	errICCIDRequired    = errors.New("iccid is required")
+	errSMSIncomplete    = errors.New("sms_to and sms_text must be set together")
+	errInvalidHold      = errors.New("hold must not be negative")
+	errProfileNotFound  = errors.New("profile not found on the eUICC")
+)
+
+// maxRuns is how many finished runs are kept per schedule.
+const maxRuns = 50
+
+const (
+	// switchTimeout bounds a single profile switch, including the modem restart.
+	switchTimeout = 2 * time.Minute
+	// registrationTimeout bounds how long to wait for the new profile to register
+	// before running the USSD code or sending the SMS.
+	registrationTimeout = 2 * time.Minute
+	// actionTimeout bounds the USSD code.
+	actionTimeout = 30 * time.Second
+)
+
+// Run is the outcome of one execution of a schedule.
+type Run struct {
+	ScheduleID string
+	Modem      string
+	Trigger    string // "schedule", "manual" or "keep-alive"
+	StartedAt  time.Time
+	EndedAt    time.Time
+	Previous   string // ICCID enabled before the switch
+	Steps      []string
+	Error      string
+}
+
+type Scheduler struct {
+	cfg      *config.Config
+	manager  *modem.Manager
+	notifier *notify.Notifier
+	esim     *esim.Service
+	ussd     *ussd.Service
+	message  *message.Service
+
+	mu      sync.Mutex
+	crons   map[string]*cron.Expression
+	running map[string]bool // keyed by modem
+	runs    map[string][]Run
+	wg      sync.WaitGroup
+
+	// ctx is canceled when Run returns. Runs follow it rather than the context
+	// they were started with, which may be that of an HTTP request.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(cfg *config.Config, manager *modem.Manager) (*Scheduler, error) {
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating notifier: %w", err)
+	}
+	s := &Scheduler{
+		cfg:      cfg,
+		manager:  manager,
+		notifier: notifier,
+		esim:     esim.NewService(cfg, manager),
+		ussd:     ussd.NewService(),
+		message:  message.NewService(),
+		crons:    make(map[string]*cron.Expression),
+		running:  make(map[string]bool),
+		runs:     make(map[string][]Run),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, schedule := range s.schedules() {
+		expression, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			slog.Error("ignoring schedule with invalid cron expression", "schedule", schedule.ID, "cron", schedule.Cron, "error", err)
+			continue
+		}
+		s.crons[schedule.ID] = expression
+	}
+	return s, nil
+}
+
+// Run triggers schedules every minute until ctx is canceled, then stops running
+// schedules at the next step that can be interrupted and waits for them.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	defer s.cancel()
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case tick := <-timer.C:
+			s.trigger(tick.Truncate(time.Minute))
+		}
+	}
+}
+
+func (s *Scheduler) trigger(at time.Time) {
+	schedules := s.schedules()
+	s.mu.Lock()
+	due := make([]config.Schedule, 0)
+	for _, schedule := range schedules {
+		if expression := s.crons[schedule.ID]; schedule.Enabled && expression != nil && expression.Match(at) {
+			due = append(due, schedule)
+		}
+	}
+	s.mu.Unlock()
+	for _, schedule := range due {
+		if err := s.start(schedule, "schedule"); err != nil {
+			slog.Warn("skipping schedule", "schedule", schedule.ID, "modem", schedule.Modem, "error", err)
+		}
+	}
+}
+
+// Schedules returns the schedules of a modem.
+func (s *Scheduler) Schedules(modemID string) []config.Schedule {
+	schedules := make([]config.Schedule, 0)
+	for _, schedule := range s.schedules() {
+		if schedule.Modem == modemID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules
+}
+
+// Find returns the schedule with the given ID on a modem.
+func (s *Scheduler) Find(modemID, id string) (config.Schedule, error) {
+	schedules := s.schedules()
+	i := index(schedules, modemID, id)
+	if i < 0 {
+		return config.Schedule{}, ErrScheduleNotFound
+	}
+	return schedules[i], nil
+}
+
+// Next returns the next time the schedule triggers, or the zero time if it never does.
+func (s *Scheduler) Next(id string) time.Time {
+	s.mu.Lock()
+	expression := s.crons[id]
+	s.mu.Unlock()
+	if expression == nil {
+		return time.Time{}
+	}
+	return expression.Next(time.Now())
+}
+
+// Add validates schedule, assigns it an ID and saves it to the config.
+func (s *Scheduler) Add(schedule config.Schedule) (config.Schedule, error) {
+	expression, err := validate(&schedule)
+	if err != nil {
+		return config.Schedule{}, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	schedule.ID = randid.New()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(append(s.schedules(), schedule)); err != nil {
+		return config.Schedule{}, err
+	}
+	s.crons[schedule.ID] = expression
+	return schedule, nil
+}
+
+// Update replaces the schedule with the given ID on a modem.
+func (s *Scheduler) Update(modemID, id string, schedule config.Schedule) (config.Schedule, error) {
+	expression, err := validate(&schedule)
+	if err != nil {
+		return config.Schedule{}, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := s.schedules()
+	i := index(schedules, modemID, id)
+	if i < 0 {
+		return config.Schedule{}, ErrScheduleNotFound
+	}
+	schedule.ID = id
+	schedule.Modem = modemID
+	schedules[i] = schedule
+	if err := s.save(schedules); err != nil {
+		return config.Schedule{}, err
+	}
+	s.crons[id] = expression
+	return schedule, nil
+}
+
+// Delete removes the schedule with the given ID on a modem, along with its runs.
+func (s *Scheduler) Delete(modemID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := s.schedules()
+	i := index(schedules, modemID, id)
+	if i < 0 {
+		return ErrScheduleNotFound
+	}
+	if err := s.save(slices.Delete(schedules, i, i+1)); err != nil {
+		return err
+	}
+	delete(s.crons, id)
+	delete(s.runs, id)
+	return nil
+}
+
+// Trigger runs the schedule with the given ID now, in the background.
+func (s *Scheduler) Trigger(modemID, id string) error {
+	schedule, err := s.Find(modemID, id)
+	if err != nil {
+		return err
+	}
+	return s.start(schedule, "manual")
+}
+
+// Runs returns the finished runs of a schedule, newest first.
+func (s *Scheduler) Runs(id string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := slices.Clone(s.runs[id])
+	slices.Reverse(runs)
+	return runs
+}
+
+// schedules returns a copy of the schedules in the config.
+func (s *Scheduler) schedules() []config.Schedule {
+	var schedules []config.Schedule
+	s.cfg.View(func(c *config.Config) {
+		schedules = slices.Clone(c.Schedules)
+	})
+	return schedules
+}
+
+func index(schedules []config.Schedule, modemID, id string) int {
+	return slices.IndexFunc(schedules, func(schedule config.Schedule) bool {
+		return schedule.ID == id && schedule.Modem == modemID
+	})
+}
+
+// save writes schedules to the config, keeping the previous schedules if that fails.
+// It must be called with s.mu held, so that schedules are not changed in between
+// being read and saved.
+func (s *Scheduler) save(schedules []config.Schedule) error {
+	err := s.cfg.Update(func(c *config.Config) error {
+		c.Schedules = schedules
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save config", "error", err)
+	}
+	return err
+}
+
+func validate(schedule *config.Schedule) (*cron.Expression, error) {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	schedule.Cron = strings.TrimSpace(schedule.Cron)
+	schedule.ICCID = strings.TrimSpace(schedule.ICCID)
+	schedule.USSD = strings.TrimSpace(schedule.USSD)
+	schedule.SMSTo = strings.TrimSpace(schedule.SMSTo)
+	expression, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.ICCID == "" {
+		return nil, errICCIDRequired
+	}
+	if _, err := sgp22.NewICCID(schedule.ICCID); err != nil {
+		return nil, fmt.Errorf("invalid iccid: %w", err)
+	}
+	if (schedule.SMSTo == "") != (strings.TrimSpace(schedule.SMSText) == "") {
+		return nil, errSMSIncomplete
+	}
+	if schedule.HoldSeconds < 0 {
+		return nil, errInvalidHold
+	}
+	return expression, nil
+}
+
+// start runs schedule in the background unless another schedule is running on the same modem.
+func (s *Scheduler) start(schedule config.Schedule, trigger string) error {
+	if !s.acquire(schedule.Modem) {
+		return ErrModemBusy
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		run := s.execute(s.ctx, schedule, trigger)
+		s.release(schedule.Modem)
+		s.mu.Lock()
+		if index(s.schedules(), schedule.Modem, schedule.ID) >= 0 {
+			runs := append(s.runs[schedule.ID], run)
+			if len(runs) > maxRuns {
+				runs = runs[len(runs)-maxRuns:]
+			}
+			s.runs[schedule.ID] = runs
+		}
+		s.mu.Unlock()
+		if run.Error != "" {
+			s.notifyFailure(schedule, run)
+		}
+	}()
+	return nil
+}
+
+// Perform runs a schedule that is not configured, such as a keep-alive, and waits for it.
+// It shares the per-modem guard with configured schedules, so the two never switch profiles
+// on the same modem at once. The run is neither recorded nor notified; that is up to the caller.
+func (s *Scheduler) Perform(ctx context.Context, schedule config.Schedule, trigger string) (Run, error) {
+	if !s.acquire(schedule.Modem) {
+		return Run{}, ErrModemBusy
+	}
+	defer s.release(schedule.Modem)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+	return s.execute(ctx, schedule, trigger), nil
+}
+
+func (s *Scheduler) acquire(modemID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[modemID] {
+		return false
+	}
+	s.running[modemID] = true
+	return true
+}
+
+func (s *Scheduler) release(modemID string) {
+	s.mu.Lock()
+	delete(s.running, modemID)
+	s.mu.Unlock()
+}
@@ -0,0 +1,206 @@
+// Package telemetry samples the detailed signal metrics of every modem and keeps
+// a bounded history of them in memory.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	// defaultHistory keeps a day of samples at the default interval.
+	defaultHistory = 2880
+)
+
+// Sample is the signal of a modem at one point in time.
+type Sample struct {
+	Time    time.Time
+	Metrics modem.SignalMetrics
+}
+
+// Recorder makes ModemManager take signal measurements and records them.
+type Recorder struct {
+	manager  *modem.Manager
+	interval time.Duration
+	size     int
+
+	mu      sync.Mutex
+	history map[string]*ring // keyed by modem
+	// setup tells whether the measurements of a modem object were set up
+	// successfully. Modems without the Signal interface are not retried.
+	setup map[dbus.ObjectPath]bool
+}
+
+func New(cfg *config.Config, manager *modem.Manager) *Recorder {
+	r := &Recorder{
+		manager:  manager,
+		interval: defaultInterval,
+		size:     defaultHistory,
+		history:  make(map[string]*ring),
+		setup:    make(map[dbus.ObjectPath]bool),
+	}
+	if cfg.Signal.IntervalSeconds > 0 {
+		r.interval = time.Duration(cfg.Signal.IntervalSeconds) * time.Second
+	}
+	if cfg.Signal.History > 0 {
+		r.size = cfg.Signal.History
+	}
+	return r
+}
+
+// Interval is how often measurements are taken.
+func (r *Recorder) Interval() time.Duration {
+	return r.interval
+}
+
+// Run samples every modem each interval until ctx is canceled.
+func (r *Recorder) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.sampleAll()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// History returns the samples of a modem taken within [since, until], oldest first.
+// A zero since or until leaves that end open.
+func (r *Recorder) History(id string, since, until time.Time) []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history, ok := r.history[id]
+	if !ok {
+		return nil
+	}
+	var samples []Sample
+	for _, sample := range history.all() {
+		if (!since.IsZero() && sample.Time.Before(since)) || (!until.IsZero() && sample.Time.After(until)) {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// Latest returns the latest sample of a modem if it was taken within the last two intervals.
+func (r *Recorder) Latest(id string) (Sample, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history, ok := r.history[id]
+	if !ok || len(history.samples) == 0 {
+		return Sample{}, false
+	}
+	latest := history.samples[(history.next+len(history.samples)-1)%len(history.samples)]
+	if time.Since(latest.Time) > 2*r.interval {
+		return Sample{}, false
+	}
+	return latest, true
+}
+
+func (r *Recorder) sampleAll() {
+	modems, err := r.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	r.mu.Lock()
+	for path := range r.setup {
+		if _, ok := modems[path]; !ok {
+			delete(r.setup, path)
+		}
+	}
+	r.mu.Unlock()
+	for path, m := range modems {
+		if !r.prepare(path, m) {
+			continue
+		}
+		metrics, err := m.Signal().Metrics()
+		if err != nil {
+			slog.Warn("failed to read signal", "modem", m.EquipmentIdentifier, "error", err)
+			continue
+		}
+		// The first measurement is only available one interval after setup.
+		if *metrics == (modem.SignalMetrics{}) {
+			continue
+		}
+		r.record(m.EquipmentIdentifier, Sample{Time: time.Now().UTC(), Metrics: *metrics})
+	}
+}
+
+// prepare sets up the measurements of a modem object the first time it is seen
+// and reports whether it can be sampled.
+func (r *Recorder) prepare(path dbus.ObjectPath, m *modem.Modem) bool {
+	r.mu.Lock()
+	supported, ok := r.setup[path]
+	r.mu.Unlock()
+	if ok {
+		return supported
+	}
+	err := m.Signal().Setup(uint32(r.interval / time.Second))
+	if err != nil && !unsupported(err) {
+		// The modem may be busy or still starting up, so setup is tried again next time.
+		slog.Warn("failed to set up signal metrics", "modem", m.EquipmentIdentifier, "error", err)
+		return false
+	}
+	if err != nil {
+		slog.Warn("modem does not provide detailed signal metrics", "modem", m.EquipmentIdentifier, "error", err)
+	}
+	r.mu.Lock()
+	r.setup[path] = err == nil
+	r.mu.Unlock()
+	return false
+}
+
+// unsupported reports whether err means the modem has no Signal interface.
+func unsupported(err error) bool {
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		return false
+	}
+	return dbusErr.Name == "org.freedesktop.DBus.Error.UnknownInterface" || dbusErr.Name == "org.freedesktop.DBus.Error.UnknownMethod"
+}
+
+func (r *Recorder) record(id string, sample Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history, ok := r.history[id]
+	if !ok {
+		history = &ring{samples: make([]Sample, 0, r.size)}
+		r.history[id] = history
+	}
+	history.add(sample)
+}
+
+// ring keeps the latest samples up to its capacity.
+type ring struct {
+	samples []Sample
+	next    int // where the next sample goes once the ring is full
+}
+
+func (r *ring) add(sample Sample) {
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// all returns the samples oldest first.
+func (r *ring) all() []Sample {
+	return slices.Concat(r.samples[r.next:], r.samples[:r.next])
+}
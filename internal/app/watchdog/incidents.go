@@ -0,0 +1,115 @@
+package watchdog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxIncidents is how many incidents are kept across all modems.
+const maxIncidents = 200
+
+// Incident is a period in which a modem stayed unregistered for longer than the
+// grace period, with the recovery steps run during it.
+type Incident struct {
+	ID    string `json:"id"`
+	Modem string `json:"modem"`
+	// Reason is the registration or modem state the modem was stuck in, e.g. "Searching" or "Failed".
+	Reason    string    `json:"reason"`
+	StartedAt time.Time `json:"startedAt"`
+	// ResolvedAt is when the modem registered again, nil while it is still unregistered.
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Attempts   []Attempt  `json:"attempts"`
+}
+
+// Attempt is one recovery step of an incident.
+type Attempt struct {
+	Step  string    `json:"step"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Incidents keeps the latest incidents in a JSON file, so that they survive restarts.
+type Incidents struct {
+	mu        sync.Mutex
+	path      string
+	incidents []Incident // oldest first
+}
+
+// LoadIncidents reads the incident file at path. A missing file is not an error.
+func LoadIncidents(path string) (*Incidents, error) {
+	s := &Incidents{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading incident file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.incidents); err != nil {
+		return nil, fmt.Errorf("parsing incident file: %w", err)
+	}
+	return s, nil
+}
+
+// List returns the incidents of a modem, newest first.
+func (s *Incidents) List(modem string) []Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incidents := make([]Incident, 0)
+	for _, incident := range slices.Backward(s.incidents) {
+		if incident.Modem == modem {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents
+}
+
+// Open returns the incidents that were not resolved, e.g. because sigmo was
+// restarted during them.
+func (s *Incidents) Open() []Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var incidents []Incident
+	for _, incident := range s.incidents {
+		if incident.ResolvedAt == nil {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents
+}
+
+// Put adds an incident or replaces the one with the same ID.
+func (s *Incidents) Put(incident Incident) error {
+	incident.Attempts = slices.Clone(incident.Attempts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.incidents, func(existing Incident) bool { return existing.ID == incident.ID }); i >= 0 {
+		s.incidents[i] = incident
+	} else {
+		s.incidents = append(s.incidents, incident)
+		if len(s.incidents) > maxIncidents {
+			s.incidents = slices.Delete(s.incidents, 0, len(s.incidents)-maxIncidents)
+		}
+	}
+	data, err := json.MarshalIndent(s.incidents, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding incident file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating incident directory: %w", err)
+	}
+	temp := s.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return fmt.Errorf("writing incident file: %w", err)
+	}
+	if err := os.Rename(temp, s.path); err != nil {
+		return fmt.Errorf("writing incident file: %w", err)
+	}
+	return nil
+}
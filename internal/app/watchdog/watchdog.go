@@ -0,0 +1,326 @@
+// Package watchdog recovers modems that lose their network registration and do
+// not get it back on their own.
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+	"github.com/damonto/sigmo/internal/pkg/notify"
+	"github.com/damonto/sigmo/internal/pkg/randid"
+)
+
+const (
+	// checkInterval is how often the registration of every modem is checked.
+	checkInterval = 30 * time.Second
+
+	defaultGrace      = 5 * time.Minute
+	defaultBackoff    = time.Minute
+	defaultMaxBackoff = time.Hour
+
+	// inhibitDuration is how long a device stays inhibited in the inhibit step.
+	inhibitDuration = 2 * time.Second
+)
+
+var errUnknownStep = errors.New("unknown watchdog step")
+
+var steps = metrics.NewCounter("sigmo_watchdog_steps_total", "Watchdog recovery steps by step and result.", "modem", "step", "result")
+
+// step is a recovery action, from the least to the most disruptive.
+type step struct {
+	name        string
+	description string
+	run         func(w *Watchdog, m *modem.Modem) error
+}
+
+var allSteps = []step{
+	{name: "register", description: "re-register on any network", run: func(_ *Watchdog, m *modem.Modem) error {
+		return m.ThreeGPP().RegisterNetwork("")
+	}},
+	{name: "reenable", description: "disable and enable the modem", run: func(_ *Watchdog, m *modem.Modem) error {
+		if err := m.Disable(); err != nil {
+			return fmt.Errorf("disabling modem: %w", err)
+		}
+		if err := m.Enable(); err != nil {
+			return fmt.Errorf("enabling modem: %w", err)
+		}
+		return nil
+	}},
+	{name: "restart", description: "restart the modem", run: func(w *Watchdog, m *modem.Modem) error {
+		return m.Restart(w.cfg.FindModem(m.EquipmentIdentifier).Compatible)
+	}},
+	{name: "inhibit", description: "inhibit and uninhibit the device", run: func(w *Watchdog, m *modem.Modem) error {
+		if err := w.manager.InhibitDevice(m.Device, true); err != nil {
+			return fmt.Errorf("inhibiting device: %w", err)
+		}
+		time.Sleep(inhibitDuration)
+		if err := w.manager.InhibitDevice(m.Device, false); err != nil {
+			return fmt.Errorf("uninhibiting device: %w", err)
+		}
+		return nil
+	}},
+}
+
+// Watchdog watches the registration of every modem and escalates through the
+// configured steps while a modem stays unregistered.
+type Watchdog struct {
+	cfg        *config.Config
+	manager    *modem.Manager
+	notifier   *notify.Notifier
+	incidents  *Incidents
+	steps      []step
+	grace      time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	trackers map[string]*tracker // keyed by modem
+	wg       sync.WaitGroup
+}
+
+// tracker follows a modem from the moment it is seen unregistered.
+type tracker struct {
+	since    time.Time // when the modem was first seen unregistered
+	incident *Incident // opened once the grace period has passed
+	step     int       // index of the next step
+	next     time.Time // when the next step may run
+	backoff  time.Duration
+	running  bool
+}
+
+func New(cfg *config.Config, manager *modem.Manager) (*Watchdog, error) {
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating notifier: %w", err)
+	}
+	incidents, err := LoadIncidents(cfg.DataPath("incidents.json"))
+	if err != nil {
+		return nil, err
+	}
+	w := &Watchdog{
+		cfg:        cfg,
+		manager:    manager,
+		notifier:   notifier,
+		incidents:  incidents,
+		steps:      allSteps,
+		grace:      seconds(cfg.Watchdog.GraceSeconds, defaultGrace),
+		backoff:    seconds(cfg.Watchdog.BackoffSeconds, defaultBackoff),
+		maxBackoff: seconds(cfg.Watchdog.MaxBackoffSeconds, defaultMaxBackoff),
+		trackers:   make(map[string]*tracker),
+	}
+	if len(cfg.Watchdog.Steps) > 0 {
+		w.steps = make([]step, 0, len(cfg.Watchdog.Steps))
+		for _, name := range cfg.Watchdog.Steps {
+			i := slices.IndexFunc(allSteps, func(s step) bool { return s.name == name })
+			if i < 0 {
+				return nil, fmt.Errorf("%w %q", errUnknownStep, name)
+			}
+			w.steps = append(w.steps, allSteps[i])
+		}
+	}
+	// Pick up incidents that were open when sigmo stopped, so that they are
+	// resolved or escalated instead of staying open forever.
+	for _, incident := range incidents.Open() {
+		w.trackers[incident.Modem] = &tracker{
+			since:    incident.StartedAt,
+			incident: &incident,
+			step:     len(incident.Attempts) % len(w.steps),
+			backoff:  w.backoff,
+		}
+	}
+	return w, nil
+}
+
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return fallback
+}
+
+func (w *Watchdog) Enabled() bool {
+	return w.cfg.Watchdog.Enabled
+}
+
+// Incidents returns the incidents of a modem, newest first.
+func (w *Watchdog) Incidents(modemID string) []Incident {
+	return w.incidents.List(modemID)
+}
+
+// Run checks every modem each interval until ctx is canceled, then waits for running steps.
+func (w *Watchdog) Run(ctx context.Context) error {
+	if !w.Enabled() {
+		slog.Info("registration watchdog disabled")
+		<-ctx.Done()
+		return nil
+	}
+	defer w.wg.Wait()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		w.check(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watchdog) check(now time.Time) {
+	modems, err := w.manager.Modems()
+	if err != nil {
+		slog.Warn("failed to list modems", "error", err)
+		return
+	}
+	for _, m := range modems {
+		id := m.EquipmentIdentifier
+		if !w.watches(id) {
+			continue
+		}
+		w.mu.Lock()
+		recovering := w.trackers[id] != nil && w.trackers[id].incident != nil
+		w.mu.Unlock()
+		reason, registered, ok := registration(m, recovering)
+		w.mu.Lock()
+		t := w.trackers[id]
+		switch {
+		case t != nil && t.running:
+		case lpa.Busy(id) || !ok:
+			// The modem is switching profiles, which drops its registration until the
+			// new profile registers, was disabled by the user or needs the user, none
+			// of which the steps can help with. An open incident waits for it.
+			if t != nil && t.incident == nil {
+				delete(w.trackers, id)
+			}
+		case registered:
+			delete(w.trackers, id)
+			if t != nil && t.incident != nil {
+				w.mu.Unlock()
+				w.resolve(m, t.incident, now)
+				continue
+			}
+		case t == nil:
+			w.trackers[id] = &tracker{since: now, backoff: w.backoff}
+		case now.Sub(t.since) >= w.grace && !now.Before(t.next):
+			if t.incident == nil {
+				t.incident = &Incident{ID: randid.New(), Modem: id, Reason: reason, StartedAt: t.since}
+			}
+			t.running = true
+			w.wg.Add(1)
+			go w.escalate(m, t, now)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// watches reports whether the watchdog looks after a modem.
+func (w *Watchdog) watches(id string) bool {
+	return len(w.cfg.Watchdog.Modems) == 0 || slices.Contains(w.cfg.Watchdog.Modems, id)
+}
+
+// registration reports whether a modem is registered and, if not, why, e.g.
+// "Searching" or "Failed". It returns false if the modem is not expected to
+// register, such as when the user disabled it, or needs the user, such as when
+// its SIM is locked or missing. While recovering, that is with an incident open,
+// the steps may have left the modem disabled or failed, so those states count
+// and the escalation goes on until the modem registers.
+func registration(m *modem.Modem, recovering bool) (reason string, registered, ok bool) {
+	switch {
+	case m.State == modem.ModemStateLocked:
+		return m.State.String(), false, false
+	case m.State == modem.ModemStateFailed && m.StateFailedReason == modem.ModemStateFailedReasonSimMissing:
+		return m.State.String(), false, recovering
+	case m.State == modem.ModemStateFailed:
+		return m.State.String(), false, true
+	case m.State < modem.ModemStateEnabled:
+		return m.State.String(), false, recovering
+	}
+	state, err := m.ThreeGPP().RegistrationState()
+	if err != nil {
+		slog.Warn("failed to read registration state", "modem", m.EquipmentIdentifier, "error", err)
+		return modem.ModemStateUnknown.String(), false, recovering
+	}
+	return state.String(), state.Registered(), true
+}
+
+// escalate runs the next step of t on m and schedules the one after it.
+func (w *Watchdog) escalate(m *modem.Modem, t *tracker, now time.Time) {
+	defer w.wg.Done()
+	w.mu.Lock()
+	s := w.steps[t.step]
+	incident := t.incident
+	w.mu.Unlock()
+
+	slog.Warn("modem is not registered, running recovery step", "modem", m.EquipmentIdentifier, "reason", incident.Reason, "step", s.name)
+	err := s.run(w, m)
+	steps.Inc(m.EquipmentIdentifier, s.name, metrics.Result(err))
+	attempt := Attempt{Step: s.name, At: now}
+	if err != nil {
+		slog.Error("recovery step failed", "modem", m.EquipmentIdentifier, "step", s.name, "error", err)
+		attempt.Error = err.Error()
+	}
+
+	w.mu.Lock()
+	incident.Attempts = append(incident.Attempts, attempt)
+	number := t.step + 1
+	t.next = time.Now().Add(t.backoff)
+	wait := t.backoff
+	// After the last step the escalation starts over, at the longest backoff.
+	t.step = number % len(w.steps)
+	t.backoff = min(t.backoff*2, w.maxBackoff)
+	if t.step == 0 {
+		t.backoff = w.maxBackoff
+	}
+	t.running = false
+	record := *incident
+	w.mu.Unlock()
+
+	if err := w.incidents.Put(record); err != nil {
+		slog.Error("failed to record incident", "modem", m.EquipmentIdentifier, "error", err)
+	}
+	text := fmt.Sprintf("Modem %s is not registered (%s) since %s\nStep %d/%d: %s\nResult: %s\nChecking again in %s",
+		w.name(m), incident.Reason, incident.StartedAt.Format(time.RFC3339), number, len(w.steps), s.description, result(err), wait)
+	w.send(m, text)
+}
+
+// resolve closes the incident of a modem that registered again.
+func (w *Watchdog) resolve(m *modem.Modem, incident *Incident, now time.Time) {
+	incident.ResolvedAt = &now
+	slog.Info("modem registered again", "modem", m.EquipmentIdentifier, "attempts", len(incident.Attempts))
+	if err := w.incidents.Put(*incident); err != nil {
+		slog.Error("failed to record incident", "modem", m.EquipmentIdentifier, "error", err)
+	}
+	text := fmt.Sprintf("Modem %s is registered again\nUnregistered for: %s\nRecovery steps: %d",
+		w.name(m), now.Sub(incident.StartedAt).Round(time.Second), len(incident.Attempts))
+	w.send(m, text)
+}
+
+func (w *Watchdog) send(m *modem.Modem, text string) {
+	if err := w.notifier.Send(notify.TextMessage{Text: text}, w.cfg.Watchdog.Channels...); err != nil {
+		slog.Error("failed to send watchdog notification", "modem", m.EquipmentIdentifier, "error", err)
+	}
+}
+
+func (w *Watchdog) name(m *modem.Modem) string {
+	if alias := w.cfg.FindModem(m.EquipmentIdentifier).Alias; alias != "" {
+		return alias
+	}
+	return m.EquipmentIdentifier
+}
+
+func result(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "done"
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/keymutex"
+)
+
+// FileName is the name of the audit log in the data directory.
+const FileName = "audit.log"
+
+// files serializes access to each log file, so that every subsystem can open its own Log.
+var files = keymutex.New()
+
+// Entry is a single line of the audit log.
+type Entry struct {
+	Time   time.Time       `json:"time"`
+	Action string          `json:"action"`
+	Modem  string          `json:"modem,omitempty"`
+	EID    string          `json:"eid,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Log is an append-only JSON lines file recording destructive or otherwise
+// noteworthy eUICC operations, so the state of a card can be reconstructed later.
+type Log struct {
+	path string
+}
+
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends an entry for action. data is encoded as JSON and may be nil.
+func (l *Log) Record(action string, modem string, eid string, data any) error {
+	entry := Entry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		Modem:  modem,
+		EID:    eid,
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("encoding audit data: %w", err)
+		}
+		entry.Data = raw
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %w", err)
+	}
+
+	files.Lock(l.path)
+	defer files.Unlock(l.path)
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("creating audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Entries returns every entry in the log, oldest first.
+func (l *Log) Entries() ([]Entry, error) {
+	files.Lock(l.path)
+	defer files.Unlock(l.path)
+	f, err := os.Open(l.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("decoding audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	return entries, nil
+}
@@ -4,17 +4,39 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
-// Config represents the application configuration
+// Config represents the application configuration.
+//
+// Modems, Schedules, KeepAlives and NetworkPolicies change while sigmo runs, so
+// they are only changed with Update and read with View or FindModem. The other
+// fields are only set by Load.
 type Config struct {
 	App      App                `toml:"app"`
+	LPA      LPA                `toml:"lpa,omitempty"`
 	Channels map[string]Channel `toml:"channels"`
 	Modems   map[string]Modem   `toml:"modems"`
-	Path     string             `toml:"-"`
+	Backup   Backup             `toml:"backup,omitempty"`
+	Signal   Signal             `toml:"signal,omitempty"`
+	Metrics  Metrics            `toml:"metrics,omitempty"`
+	Watchdog Watchdog           `toml:"watchdog,omitempty"`
+	// Schedules are managed through the API and written back with Update.
+	Schedules  []Schedule  `toml:"schedules,omitempty"`
+	KeepAlives []KeepAlive `toml:"keep_alives,omitempty"`
+	Alerts     []Alert     `toml:"alerts,omitempty"`
+	// NetworkPolicies are managed through the API and written back with Update.
+	NetworkPolicies []NetworkPolicy `toml:"network_policies,omitempty"`
+	Path            string          `toml:"-"`
+
+	mu sync.RWMutex
 }
 
 type App struct {
@@ -22,6 +44,12 @@ type App struct {
 	ListenAddress string   `toml:"listen_address"`
 	AuthProviders []string `toml:"auth_providers"`
 	OTPRequired   bool     `toml:"otp_required"`
+	DataDir       string   `toml:"data_dir,omitempty"`
+}
+
+type LPA struct {
+	SMDSServers []string `toml:"smds_servers"`
+	AIDs        []string `toml:"aids"`
 }
 
 type Channel struct {
@@ -52,6 +80,129 @@ type Modem struct {
 	Alias      string `toml:"alias"`
 	Compatible bool   `toml:"compatible"`
 	MSS        int    `toml:"mss"`
+	AID        string `toml:"aid,omitempty"`
+	APDUTrace  bool   `toml:"apdu_trace,omitempty"`
+}
+
+// Backup periodically saves the profiles of every eUICC to the data directory,
+// so that they can be told apart and renamed on a replacement card.
+type Backup struct {
+	// IntervalHours is how often profiles are saved. Zero disables backups.
+	IntervalHours int `toml:"interval_hours"`
+	// Keep is how many backups are kept per eUICC. Zero keeps 30.
+	Keep int `toml:"keep,omitempty"`
+}
+
+// Signal controls how detailed signal metrics are sampled and how many are remembered.
+type Signal struct {
+	// IntervalSeconds is how often measurements are taken. Zero takes one every 30 seconds.
+	IntervalSeconds int `toml:"interval_seconds,omitempty"`
+	// History is how many samples are kept per modem. Zero keeps 2880, a day at the default interval.
+	History int `toml:"history,omitempty"`
+}
+
+// Metrics exposes Prometheus metrics on /metrics.
+type Metrics struct {
+	Enabled bool `toml:"enabled"`
+	// Token is required as a bearer token to read the metrics when set.
+	// The endpoint is not behind the OTP login, as scrapers cannot log in.
+	Token string `toml:"token,omitempty"`
+}
+
+// Watchdog recovers modems that stay unregistered by running Steps in order,
+// waiting longer after each one, until the modem registers again.
+type Watchdog struct {
+	Enabled bool `toml:"enabled"`
+	// GraceSeconds is how long a modem may be unregistered before recovery starts. Zero waits 5 minutes.
+	GraceSeconds int `toml:"grace_seconds,omitempty"`
+	// Steps are "register", "reenable", "restart" and "inhibit". Empty runs all of them in that order.
+	Steps []string `toml:"steps,omitempty"`
+	// BackoffSeconds is how long to wait for the modem to register after the first step.
+	// It doubles after every step, up to MaxBackoffSeconds. Zero waits 1 minute.
+	BackoffSeconds int `toml:"backoff_seconds,omitempty"`
+	// MaxBackoffSeconds caps the wait between steps. Zero caps it at 1 hour.
+	MaxBackoffSeconds int `toml:"max_backoff_seconds,omitempty"`
+	// Modems limits the watchdog to these modems. Empty watches every modem.
+	Modems []string `toml:"modems,omitempty"`
+	// Channels are notified of every step. Empty means every configured channel.
+	Channels []string `toml:"channels,omitempty"`
+}
+
+// Schedule enables a profile on a modem at the times matching Cron.
+// After switching it can run a USSD code and send an SMS, for example to keep
+// a rarely used profile alive, and then switch back to the previous profile.
+type Schedule struct {
+	ID         string `toml:"id"`
+	Name       string `toml:"name,omitempty"`
+	Modem      string `toml:"modem"`
+	Cron       string `toml:"cron"`
+	ICCID      string `toml:"iccid"`
+	USSD       string `toml:"ussd,omitempty"`
+	SMSTo      string `toml:"sms_to,omitempty"`
+	SMSText    string `toml:"sms_text,omitempty"`
+	SwitchBack bool   `toml:"switch_back,omitempty"`
+	// HoldSeconds is how long to stay on the profile before switching back.
+	HoldSeconds int `toml:"hold_seconds,omitzero"`
+	// Channels receive failure notifications. Empty means every configured channel.
+	Channels []string `toml:"channels,omitempty"`
+	Enabled  bool     `toml:"enabled"`
+}
+
+// KeepAlive keeps a rarely used profile from expiring. When no SMS was sent or
+// received on the profile for IntervalDays, the profile is enabled during one of
+// Windows, the USSD code and SMS are run, and the previous profile is restored.
+type KeepAlive struct {
+	ICCID        string `toml:"iccid"`
+	IntervalDays int    `toml:"interval_days"`
+	USSD         string `toml:"ussd,omitempty"`
+	SMSTo        string `toml:"sms_to,omitempty"`
+	SMSText      string `toml:"sms_text,omitempty"`
+	// Windows are local time ranges such as "02:00-05:00" in which the switch may
+	// happen. A range may wrap midnight. Empty means any time.
+	Windows []string `toml:"windows,omitempty"`
+	// Channels receive the outcome of every keep-alive. Empty means every configured channel.
+	Channels []string `toml:"channels,omitempty"`
+	Enabled  bool     `toml:"enabled"`
+	// CreatedAt is when the policy was added. A profile never seen active is due
+	// IntervalDays after it.
+	CreatedAt time.Time `toml:"created_at,omitempty"`
+}
+
+// Alert notifies Channels when a modem matches Rule for ForSeconds, and again
+// when it stops matching. The rules are:
+//   - "unplugged": the modem was removed.
+//   - "unregistered": the modem is enabled but not registered on a network.
+//   - "roaming": the modem is registered on a roaming network.
+//   - "operator": the modem registered on another operator.
+//   - "signal": the signal quality is below Threshold percent.
+//   - "sim-missing": the modem has no SIM.
+//   - "profile": another SIM or eSIM profile became active.
+type Alert struct {
+	Name string `toml:"name,omitempty"`
+	Rule string `toml:"rule"`
+	// ForSeconds is how long a modem must match the rule, or keep a new operator or
+	// profile, before the alert is sent. Zero waits 60 seconds.
+	ForSeconds int `toml:"for_seconds,omitempty"`
+	// Threshold is the signal quality in percent for the "signal" rule.
+	Threshold int `toml:"threshold,omitempty"`
+	// Modems limits the alert to these modems. Empty means every modem.
+	Modems []string `toml:"modems,omitempty"`
+	// Channels receive the alert. Empty means every configured channel.
+	Channels []string `toml:"channels,omitempty"`
+}
+
+// NetworkPolicy chooses the network a modem registers on. It applies to the
+// modem with ID Modem, or to whichever modem has the SIM or eSIM profile with
+// ICCID active. A policy for a profile takes precedence over one for its modem.
+type NetworkPolicy struct {
+	Modem string `toml:"modem,omitempty"`
+	ICCID string `toml:"iccid,omitempty"`
+	// Operators are MCC and MNC codes, most preferred first. The modem is moved to
+	// a more preferred operator whenever one is available.
+	Operators []string `toml:"operators,omitempty"`
+	// AllowRoaming lets the modem select any network automatically when none of
+	// Operators is available. Otherwise it stays on its home network.
+	AllowRoaming bool `toml:"allow_roaming"`
 }
 
 // Load reads and parses the configuration from the given file path
@@ -77,16 +228,97 @@ func (c *Config) IsProduction() bool {
 }
 
 func (c *Config) FindModem(id string) Modem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if modem, ok := c.Modems[id]; ok {
 		return modem
 	}
+	return DefaultModem()
+}
+
+// DefaultModem returns the settings of a modem that is not in the config.
+func DefaultModem() Modem {
 	return Modem{
 		Compatible: false,
 		MSS:        240,
 	}
 }
 
-func (c *Config) Save() error {
+// DataPath returns the path of name inside the data directory.
+// The data directory defaults to the directory containing the config file.
+func (c *Config) DataPath(name string) string {
+	dir := c.App.DataDir
+	if dir == "" {
+		dir = filepath.Dir(c.Path)
+	}
+	return filepath.Join(dir, name)
+}
+
+// DefaultSMDSServers are the root SM-DS addresses queried when none are configured.
+var DefaultSMDSServers = []string{
+	"lpa.ds.gsma.com",
+	"lpa.live.esimdiscovery.com",
+}
+
+func (c *Config) SMDSServers() []string {
+	if len(c.LPA.SMDSServers) == 0 {
+		return DefaultSMDSServers
+	}
+	return c.LPA.SMDSServers
+}
+
+// View runs fn with the config locked for reading. fn must not call FindModem,
+// View or Update, and must not keep references to the slices or maps it reads.
+func (c *Config) View(fn func(c *Config)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fn(c)
+}
+
+// Update runs fn with the config locked for writing and saves the config to its
+// file. If fn or saving fails, the changes fn made are undone. fn must not call
+// FindModem, View or Update.
+func (c *Config) Update(fn func(c *Config) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.mutable()
+	if err := fn(c); err != nil {
+		c.restore(previous)
+		return err
+	}
+	if err := c.save(); err != nil {
+		c.restore(previous)
+		return err
+	}
+	return nil
+}
+
+// mutableFields are copies of the fields that Update may change.
+type mutableFields struct {
+	modems          map[string]Modem
+	schedules       []Schedule
+	keepAlives      []KeepAlive
+	networkPolicies []NetworkPolicy
+}
+
+func (c *Config) mutable() mutableFields {
+	return mutableFields{
+		modems:          maps.Clone(c.Modems),
+		schedules:       slices.Clone(c.Schedules),
+		keepAlives:      slices.Clone(c.KeepAlives),
+		networkPolicies: slices.Clone(c.NetworkPolicies),
+	}
+}
+
+func (c *Config) restore(previous mutableFields) {
+	c.Modems = previous.modems
+	c.Schedules = previous.schedules
+	c.KeepAlives = previous.keepAlives
+	c.NetworkPolicies = previous.networkPolicies
+}
+
+// save writes the config to its file. It must be called with c.mu held.
+func (c *Config) save() error {
 	if c.Path == "" {
 		return errors.New("config path is required")
 	}
@@ -0,0 +1,149 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"empty", ""},
+		{"too few fields", "0 0 * *"},
+		{"too many fields", "0 0 * * * *"},
+		{"unknown macro", "@weekdays"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"negative value", "-1 * * * *"},
+		{"not a number", "a * * * *"},
+		{"reversed range", "0 18-8 * * *"},
+		{"open range", "0 8- * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"negative step", "*/-5 * * * *"},
+		{"missing step", "*/ * * * *"},
+		{"empty list item", "0,,30 * * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.spec); err == nil {
+				t.Errorf("Parse(%q) = nil error, want an error", tt.spec)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	tests := []struct {
+		name string
+		spec string
+		at   string
+		want bool
+	}{
+		{"every minute", "* * * * *", "2026-03-02 13:37", true},
+		{"exact minute", "30 8 * * *", "2026-03-02 08:30", true},
+		{"other minute", "30 8 * * *", "2026-03-02 08:31", false},
+		{"range start", "0 8-18 * * *", "2026-03-02 08:00", true},
+		{"range end", "0 8-18 * * *", "2026-03-02 18:00", true},
+		{"outside range", "0 8-18 * * *", "2026-03-02 19:00", false},
+		{"step from star", "*/15 * * * *", "2026-03-02 10:45", true},
+		{"step from star miss", "*/15 * * * *", "2026-03-02 10:50", false},
+		{"step over range", "0 8-18/4 * * *", "2026-03-02 16:00", true},
+		{"step over range miss", "0 8-18/4 * * *", "2026-03-02 18:00", false},
+		{"step from value", "5/20 * * * *", "2026-03-02 10:45", true},
+		{"step from value before start", "5/20 * * * *", "2026-03-02 10:00", false},
+		{"list", "0,15,45 * * * *", "2026-03-02 10:15", true},
+		{"list miss", "0,15,45 * * * *", "2026-03-02 10:30", false},
+		{"list of ranges", "0 1-3,20-22 * * *", "2026-03-02 21:00", true},
+		{"month", "0 0 * 3 *", "2026-03-02 00:00", true},
+		{"other month", "0 0 * 4 *", "2026-03-02 00:00", false},
+		{"sunday as 0", "0 0 * * 0", "2026-03-01 00:00", true},
+		{"sunday as 7", "0 0 * * 7", "2026-03-01 00:00", true},
+		{"weekday range", "0 0 * * 1-5", "2026-03-01 00:00", false},
+		// When both day fields are restricted, either may match.
+		{"day of month or week, month day", "0 0 15 * 1", "2026-03-15 00:00", true},
+		{"day of month or week, weekday", "0 0 15 * 1", "2026-03-02 00:00", true},
+		{"day of month or week, neither", "0 0 15 * 1", "2026-03-03 00:00", false},
+		// When one of them is "*", only the other restricts the day.
+		{"day of month only", "0 0 15 * *", "2026-03-02 00:00", false},
+		{"day of week only", "0 0 * * 1", "2026-03-15 00:00", false},
+		{"stepped day of month is restricted", "0 0 */10 * 1", "2026-03-02 00:00", true},
+		{"macro", "@daily", "2026-03-02 00:00", true},
+		{"macro is case insensitive", "@HOURLY", "2026-03-02 07:00", true},
+		{"macro miss", "@monthly", "2026-03-02 00:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expression, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := expression.Match(parseTime(t, tt.at)); got != tt.want {
+				t.Errorf("Parse(%q).Match(%s) = %v, want %v", tt.spec, tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{"next minute", "* * * * *", "2026-03-02 13:37", "2026-03-02 13:38"},
+		{"later today", "30 18 * * *", "2026-03-02 13:37", "2026-03-02 18:30"},
+		{"same time tomorrow", "37 13 * * *", "2026-03-02 13:37", "2026-03-03 13:37"},
+		{"next step", "*/15 * * * *", "2026-03-02 13:37", "2026-03-02 13:45"},
+		{"next hour", "*/15 * * * *", "2026-03-02 13:50", "2026-03-02 14:00"},
+		{"next weekday", "0 9 * * 1-5", "2026-03-06 10:00", "2026-03-09 09:00"},
+		{"day rollover", "0 0 * * *", "2026-03-02 23:59", "2026-03-03 00:00"},
+		{"month rollover", "0 0 1 * *", "2026-03-02 00:00", "2026-04-01 00:00"},
+		{"short month", "0 0 31 * *", "2026-03-31 00:00", "2026-05-31 00:00"},
+		{"year rollover", "0 0 1 1 *", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"leap day", "0 0 29 2 *", "2026-03-02 00:00", "2028-02-29 00:00"},
+		{"day of month or week", "0 0 15 * 5", "2026-03-02 00:00", "2026-03-06 00:00"},
+		{"never", "0 0 30 2 *", "2026-03-02 00:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expression, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			var want time.Time
+			if tt.want != "" {
+				want = parseTime(t, tt.want)
+			}
+			if got := expression.Next(parseTime(t, tt.from)); !got.Equal(want) {
+				t.Errorf("Parse(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, want)
+			}
+		})
+	}
+}
+
+func TestNextSkipsSeconds(t *testing.T) {
+	expression, err := Parse("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := parseTime(t, "2026-03-02 13:37").Add(42 * time.Second)
+	if got, want := expression.Next(from), parseTime(t, "2026-03-02 13:38"); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
+
+func parseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := time.ParseInLocation("2006-01-02 15:04", value, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/big"
 	"slices"
 	"strings"
 )
@@ -76,3 +77,39 @@ func LookupSASUP(eid, sasAccreditationNumber string) string {
 	}
 	return sasAccreditationNumber
 }
+
+// EIDInfo is the decoded structure of an EID as defined in GSMA SGP.29.
+type EIDInfo struct {
+	CountryCode      string
+	IssuerID         string
+	IssuerSpecific   string
+	CheckDigitsValid bool
+	EUM              string
+	EUMRegion        string
+}
+
+// DecodeEID splits eid into its SGP.29 fields and looks up the eUICC manufacturer
+// from the accredited EUM list.
+func DecodeEID(eid string) (*EIDInfo, error) {
+	if len(eid) != 32 || !strings.HasPrefix(eid, "89") {
+		return nil, fmt.Errorf("invalid EID %q", eid)
+	}
+	digits, ok := new(big.Int).SetString(eid, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid EID %q", eid)
+	}
+	info := EIDInfo{
+		CountryCode:      eid[2:5],
+		IssuerID:         eid[5:8],
+		IssuerSpecific:   eid[8:30],
+		CheckDigitsValid: new(big.Int).Mod(digits, big.NewInt(97)).Int64() == 1,
+	}
+	for _, supplier := range sites.Suppliers {
+		if slices.Contains(supplier.EUM, eid[:8]) {
+			info.EUM = supplier.Name
+			info.EUMRegion = supplier.Region
+			break
+		}
+	}
+	return &info, nil
+}
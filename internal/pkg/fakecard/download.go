@@ -0,0 +1,255 @@
+package fakecard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"slices"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// The fake card trusts any SM-DP+: signatures and certificates are not verified,
+// and the bound profile package is expected in clear text. Segment '88' values
+// concatenate to the StoreMetadataRequest and segment '86' values are ignored.
+
+// smdpOID is the SM-DP+ OID reported back in signed responses.
+var smdpOID = []byte{0x2B, 0x06, 0x01, 0x04, 0x01, 0x82, 0xF4, 0x37, 0x01}
+
+// BPP command IDs and error reasons of ProfileInstallationResult.
+const (
+	bppCommandInitialiseSecureChannel = 0
+	bppCommandStoreMetadata           = 2
+
+	bppErrorInvalidTransactionID = 3
+	bppErrorIccidAlreadyExists   = 9
+)
+
+// installation tracks the segments of a bound profile package being loaded.
+type installation struct {
+	metadata  bytes.Buffer
+	profile   Profile
+	remaining int // bytes of sequenceOf86 not loaded yet
+}
+
+// authenticateServer answers ES10b.AuthenticateServer and starts a download session.
+func (c *Card) authenticateServer(request *bertlv.TLV) (*bertlv.TLV, error) {
+	signed := request.First(bertlv.Universal.Constructed(16))
+	if signed == nil {
+		return nil, errors.New("missing serverSigned1")
+	}
+	transactionID := signed.First(bertlv.ContextSpecific.Primitive(0))
+	address := signed.First(bertlv.ContextSpecific.Primitive(3))
+	challenge := signed.First(bertlv.ContextSpecific.Primitive(4))
+	context := request.First(bertlv.ContextSpecific.Constructed(0))
+	if transactionID == nil || address == nil || challenge == nil || context == nil {
+		return nil, errors.New("malformed AuthenticateServerRequest")
+	}
+	c.transactionID = slices.Clone(transactionID.Value)
+	c.serverAddress = string(address.Value)
+	c.install = nil
+	euiccSigned1 := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), c.transactionID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), address.Value),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), challenge.Value),
+		c.euiccInfo2(),
+		context,
+	)
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(56),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0),
+			euiccSigned1,
+			signature(),
+			bertlv.NewChildren(bertlv.Universal.Constructed(16)), // euiccCertificate
+			bertlv.NewChildren(bertlv.Universal.Constructed(16)), // eumCertificate
+		),
+	), nil
+}
+
+// prepareDownload answers ES10b.PrepareDownload with a one-time public key
+// and echoes the hashed confirmation code for the SM-DP+ to check.
+func (c *Card) prepareDownload(request *bertlv.TLV) (*bertlv.TLV, error) {
+	signed := request.First(bertlv.Universal.Constructed(16))
+	if signed == nil {
+		return nil, errors.New("missing smdpSigned2")
+	}
+	transactionID := signed.First(bertlv.ContextSpecific.Primitive(0))
+	if transactionID == nil {
+		return nil, errors.New("missing transactionId")
+	}
+	if !bytes.Equal(transactionID.Value, c.transactionID) {
+		// downloadResponseError: invalidTransactionId
+		return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(33),
+			bertlv.NewChildren(bertlv.ContextSpecific.Constructed(1),
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID.Value),
+				bertlv.NewValue(bertlv.Universal.Primitive(2), []byte{5}),
+			),
+		), nil
+	}
+	otpk := make([]byte, 65)
+	otpk[0] = 0x04
+	_, _ = rand.Read(otpk[1:])
+	euiccSigned2 := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), c.transactionID),
+		bertlv.NewValue(bertlv.Application.Primitive(73), otpk),
+	)
+	if hashed := request.First(bertlv.Universal.Primitive(4)); hashed != nil {
+		euiccSigned2.Children = append(euiccSigned2.Children, hashed)
+	}
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(33),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), euiccSigned2, signature()),
+	), nil
+}
+
+// cancelSession answers ES10b.CancelSession and ends the download session.
+func (c *Card) cancelSession(request *bertlv.TLV) (*bertlv.TLV, error) {
+	transactionID := request.First(bertlv.ContextSpecific.Primitive(0))
+	reason := request.First(bertlv.ContextSpecific.Primitive(1))
+	if transactionID == nil || reason == nil {
+		return nil, errors.New("malformed CancelSessionRequest")
+	}
+	if !bytes.Equal(transactionID.Value, c.transactionID) {
+		// cancelSessionResponseError: invalidTransactionId
+		return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(65),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{5}),
+		), nil
+	}
+	c.transactionID = nil
+	c.install = nil
+	signed := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID.Value),
+		bertlv.NewValue(bertlv.Universal.Primitive(6), smdpOID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), reason.Value),
+	)
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(65),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signed, signature()),
+	), nil
+}
+
+// loadSegment handles one segment of ES10b.LoadBoundProfilePackage.
+// It returns nil until the last segment, then the ProfileInstallationResult.
+func (c *Card) loadSegment(data []byte) ([]byte, error) {
+	header, length, err := splitHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case data[0] == 0xBF && data[1] == 0x36:
+		var secureChannel bertlv.TLV
+		if err := secureChannel.UnmarshalBinary(data[header:]); err != nil {
+			return nil, err
+		}
+		transactionID := secureChannel.First(bertlv.ContextSpecific.Primitive(0))
+		if c.transactionID == nil || transactionID == nil || !bytes.Equal(transactionID.Value, c.transactionID) {
+			return c.installationResult(Profile{}, bppCommandInitialiseSecureChannel, bppErrorInvalidTransactionID), nil
+		}
+		c.install = new(installation)
+		return nil, nil
+	case c.install == nil:
+		return nil, errors.New("no bound profile package is being loaded")
+	case data[0] == 0x88:
+		c.install.metadata.Write(data[header:])
+	case data[0] == 0xA3:
+		var metadata bertlv.TLV
+		if err := metadata.UnmarshalBinary(c.install.metadata.Bytes()); err != nil {
+			return nil, err
+		}
+		var info sgp22.ProfileInfo
+		if err := info.UnmarshalBERTLV(&metadata); err != nil {
+			return nil, err
+		}
+		c.install.profile = Profile{
+			ICCID:               info.ICCID.String(),
+			ServiceProviderName: info.ServiceProviderName,
+			ProfileName:         info.ProfileName,
+			Class:               info.ProfileClass,
+			NotificationAddress: c.serverAddress,
+		}
+		for _, config := range info.NotificationConfigurationInfo {
+			if config.ProfileManagementOperation == sgp22.NotificationEventInstall {
+				c.install.profile.NotificationAddress = config.Address
+			}
+		}
+		if slices.ContainsFunc(c.profiles, func(p Profile) bool { return p.ICCID == c.install.profile.ICCID }) {
+			return c.installationResult(c.install.profile, bppCommandStoreMetadata, bppErrorIccidAlreadyExists), nil
+		}
+		c.install.remaining = length
+	case data[0] == 0x86:
+		c.install.remaining -= len(data)
+		if c.install.remaining <= 0 {
+			return c.installationResult(c.install.profile, 0, 0), nil
+		}
+	}
+	return nil, nil
+}
+
+// installationResult installs profile, or reports the error when reason is not zero,
+// and returns the ProfileInstallationResult with its install notification.
+func (c *Card) installationResult(profile Profile, command, reason byte) []byte {
+	transactionID := c.transactionID
+	c.install = nil
+
+	finalResult := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(2))
+	if reason == 0 {
+		c.profiles = append(c.profiles, profile)
+		finalResult.Children = append(finalResult.Children, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0),
+			bertlv.NewValue(bertlv.Application.Primitive(15), isdpAID(c.profiles, profile.ICCID)),
+			bertlv.NewValue(bertlv.Universal.Primitive(4), []byte{0x30, 0x00}),
+		))
+	} else {
+		code, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), primitive.MarshalInt(int8(command)))
+		errorReason, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(1), primitive.MarshalInt(int8(reason)))
+		finalResult.Children = append(finalResult.Children, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(1), code, errorReason))
+	}
+	// The install notification is generated on failure too, addressed to the SM-DP+ in use.
+	if profile.NotificationAddress == "" {
+		profile.NotificationAddress = c.serverAddress
+	}
+	c.sequence++
+	notification := Notification{
+		SequenceNumber: c.sequence,
+		Event:          sgp22.NotificationEventInstall,
+		Address:        profile.NotificationAddress,
+		ICCID:          profile.ICCID,
+	}
+	c.notifications = append(c.notifications, notification)
+	data := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(39),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+		notificationMetadata(notification),
+		bertlv.NewValue(bertlv.Universal.Primitive(6), smdpOID),
+		finalResult,
+	)
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(55), data, signature()).Bytes()
+}
+
+// splitHeader returns the size of the tag and length fields of a TLV and its value length.
+func splitHeader(data []byte) (int, int, error) {
+	n := 1
+	if len(data) > 0 && data[0]&0x1F == 0x1F {
+		for n < len(data) && data[n]&0x80 != 0 {
+			n++
+		}
+		n++
+	}
+	if n >= len(data) {
+		return 0, 0, errors.New("truncated TLV header")
+	}
+	if data[n] < 0x80 {
+		return n + 1, int(data[n]), nil
+	}
+	size := int(data[n] & 0x7F)
+	if size == 0 || size > 3 || n+1+size > len(data) {
+		return 0, 0, errors.New("invalid TLV length")
+	}
+	length := 0
+	for _, b := range data[n+1 : n+1+size] {
+		length = length<<8 | int(b)
+	}
+	return n + 1 + size, length, nil
+}
+
+// signature returns a dummy ECDSA signature; nothing verifies it.
+func signature() *bertlv.TLV {
+	return bertlv.NewValue(bertlv.Application.Primitive(55), make([]byte, 64))
+}
@@ -0,0 +1,565 @@
+// Package fakecard emulates an eUICC behind an apdu.SmartCardChannel.
+//
+// It implements enough of ES10 to list, enable, disable, rename and delete
+// profiles, to manage notifications and configured addresses, to answer
+// EUICCInfo1/EUICCInfo2 and to download profiles from fakesmdp, so LPA code
+// can be exercised without a modem or card.
+// Pass it to lpa.WithChannel to use it for a modem.
+package fakecard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	"github.com/damonto/euicc-go/lpa"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+var (
+	swOK            = []byte{0x90, 0x00}
+	swWrongData     = []byte{0x6A, 0x80}
+	swInsNotSupport = []byte{0x6D, 0x00}
+)
+
+// Profile is a profile installed on the fake card.
+type Profile struct {
+	ICCID               string
+	Enabled             bool
+	Nickname            string
+	ServiceProviderName string
+	ProfileName         string
+	Class               sgp22.ProfileClass
+	// NotificationAddress is the SM-DP+ that receives notifications for this profile.
+	// No notifications are generated when it is empty.
+	NotificationAddress string
+}
+
+// Notification is a pending notification on the fake card.
+type Notification struct {
+	SequenceNumber sgp22.SequenceNumber
+	Event          sgp22.NotificationEvent
+	Address        string
+	ICCID          string
+}
+
+// Card is a fake eUICC. It is safe for concurrent use.
+type Card struct {
+	mu                 sync.Mutex
+	eid                []byte
+	aid                []byte
+	profiles           []Profile
+	notifications      []Notification
+	sequence           sgp22.SequenceNumber
+	defaultSMDPAddress string
+	rootSMDSAddress    string
+	connected          bool
+	channel            byte
+	command            bytes.Buffer
+	pending            []byte
+	// transactionID and serverAddress belong to the download session started by AuthenticateServer.
+	transactionID []byte
+	serverAddress string
+	install       *installation
+}
+
+// New returns a card with the given EID (32 hex digits) and profiles.
+// The card answers on the GSMA ISD-R AID.
+func New(eid string, profiles ...Profile) (*Card, error) {
+	decoded, err := hex.DecodeString(eid)
+	if err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("invalid EID %q", eid)
+	}
+	for _, profile := range profiles {
+		if _, err := sgp22.NewICCID(profile.ICCID); err != nil {
+			return nil, fmt.Errorf("invalid ICCID %q: %w", profile.ICCID, err)
+		}
+	}
+	return &Card{
+		eid:             decoded,
+		aid:             lpa.GSMAISDRApplicationAID,
+		profiles:        slices.Clone(profiles),
+		rootSMDSAddress: "lpa.ds.gsma.com",
+	}, nil
+}
+
+// SetAID changes the ISD-R AID the card answers on.
+func (c *Card) SetAID(aid []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aid = slices.Clone(aid)
+}
+
+// Profiles returns a copy of the installed profiles.
+func (c *Card) Profiles() []Profile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.profiles)
+}
+
+// Notifications returns a copy of the pending notifications.
+func (c *Card) Notifications() []Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.notifications)
+}
+
+// DefaultSMDPAddress returns the configured default SM-DP+ address.
+func (c *Card) DefaultSMDPAddress() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.defaultSMDPAddress
+}
+
+func (c *Card) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = true
+	return nil
+}
+
+func (c *Card) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	c.channel = 0
+	return nil
+}
+
+func (c *Card) OpenLogicalChannel(aid []byte) (byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected {
+		return 0, errors.New("card is not connected")
+	}
+	if !bytes.Equal(aid, c.aid) {
+		return 0, fmt.Errorf("select %X: file not found", aid)
+	}
+	c.channel = 1
+	return c.channel, nil
+}
+
+func (c *Card) CloseLogicalChannel(channel byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if channel != c.channel {
+		return fmt.Errorf("logical channel %d is not open", channel)
+	}
+	c.channel = 0
+	return nil
+}
+
+// Transmit handles STORE DATA and GET RESPONSE on the open logical channel.
+func (c *Card) Transmit(command []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channel == 0 {
+		return nil, errors.New("no logical channel is open")
+	}
+	if len(command) < 4 {
+		return swWrongData, nil
+	}
+	switch command[1] {
+	case 0xE2:
+		var data []byte
+		if len(command) > 5 {
+			data = command[5 : 5+int(command[4])]
+		}
+		if command[3] == 0 {
+			c.command.Reset()
+		}
+		c.command.Write(data)
+		if command[2]&0x80 == 0 {
+			return swOK, nil
+		}
+		response, err := c.handle(c.command.Bytes())
+		if err != nil {
+			return swWrongData, nil
+		}
+		c.pending = response
+		if len(response) > 256 {
+			// Like a T=0 card, announce a long response and let GET RESPONSE fetch it.
+			return []byte{0x61, 0x00}, nil
+		}
+		return c.nextChunk(), nil
+	case 0xC0:
+		return c.nextChunk(), nil
+	}
+	return swInsNotSupport, nil
+}
+
+// nextChunk returns up to 256 bytes of the pending response,
+// with 61xx when more data is waiting for GET RESPONSE.
+func (c *Card) nextChunk() []byte {
+	n := min(len(c.pending), 256)
+	chunk := append([]byte(nil), c.pending[:n]...)
+	c.pending = c.pending[n:]
+	if remaining := len(c.pending); remaining > 0 {
+		return append(chunk, 0x61, byte(min(remaining, 256)))
+	}
+	return append(chunk, swOK...)
+}
+
+func (c *Card) handle(data []byte) ([]byte, error) {
+	// Bound profile package segments are not complete TLVs, so they bypass the parser.
+	if len(data) > 1 && (data[0] == 0xBF && data[1] == 0x36 || c.install != nil && data[0] != 0xBF) {
+		return c.loadSegment(data)
+	}
+	c.install = nil
+	var request bertlv.TLV
+	if err := request.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if !request.Tag.ContextSpecific() || !request.Tag.Constructed() {
+		return nil, errors.New("unexpected request tag")
+	}
+	var response *bertlv.TLV
+	var err error
+	switch request.Tag.Value() {
+	case 32:
+		response = c.euiccInfo1()
+	case 33:
+		response, err = c.prepareDownload(&request)
+	case 34:
+		response = c.euiccInfo2()
+	case 40:
+		response, err = c.listNotification(&request)
+	case 41:
+		response, err = c.setNickname(&request)
+	case 43:
+		response, err = c.retrieveNotifications(&request)
+	case 45:
+		response, err = c.listProfiles(&request)
+	case 46:
+		response = c.euiccChallenge()
+	case 48:
+		response, err = c.removeNotification(&request)
+	case 49, 50, 51:
+		response, err = c.profileOperation(&request)
+	case 52:
+		response, err = c.memoryReset(&request)
+	case 56:
+		response, err = c.authenticateServer(&request)
+	case 60:
+		response = c.configuredAddresses()
+	case 62:
+		response = bertlv.NewChildren(bertlv.ContextSpecific.Constructed(62), bertlv.NewValue(bertlv.Application.Primitive(26), c.eid))
+	case 63:
+		response = c.setDefaultDPAddress(&request)
+	case 65:
+		response, err = c.cancelSession(&request)
+	default:
+		return nil, fmt.Errorf("unsupported request %s", request.Tag.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.Bytes(), nil
+}
+
+func result(tag uint64, code int8) *bertlv.TLV {
+	value, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), primitive.MarshalInt(code))
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(tag), value)
+}
+
+func (c *Card) find(identifier *bertlv.TLV) int {
+	if identifier == nil {
+		return -1
+	}
+	return slices.IndexFunc(c.profiles, func(p Profile) bool {
+		if identifier.Tag.If(bertlv.Application, bertlv.Primitive, 26) {
+			return sgp22.ICCID(identifier.Value).String() == p.ICCID
+		}
+		return bytes.Equal(identifier.Value, isdpAID(c.profiles, p.ICCID))
+	})
+}
+
+// isdpAID derives a stable ISD-P AID from the position of the profile.
+func isdpAID(profiles []Profile, iccid string) []byte {
+	index := slices.IndexFunc(profiles, func(p Profile) bool { return p.ICCID == iccid })
+	return []byte{0xA0, 0x00, 0x00, 0x05, 0x59, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x89, 0x00, 0x00, byte(0x10 + index), 0x00}
+}
+
+func (c *Card) profileInfo(p Profile) *bertlv.TLV {
+	iccid, _ := sgp22.NewICCID(p.ICCID)
+	var state sgp22.ProfileState
+	if p.Enabled {
+		state = sgp22.ProfileEnabled
+	}
+	stateTLV, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(112), primitive.MarshalInt(state))
+	classTLV, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(21), p.Class)
+	info := bertlv.NewChildren(bertlv.Private.Constructed(3),
+		bertlv.NewValue(bertlv.Application.Primitive(26), iccid),
+		bertlv.NewValue(bertlv.Application.Primitive(15), isdpAID(c.profiles, p.ICCID)),
+		stateTLV,
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(17), []byte(p.ServiceProviderName)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(18), []byte(p.ProfileName)),
+		classTLV,
+	)
+	if p.Nickname != "" {
+		info.Children = append(info.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(16), []byte(p.Nickname)))
+	}
+	return info
+}
+
+func (c *Card) listProfiles(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var criteria *bertlv.TLV
+	if search := request.First(bertlv.ContextSpecific.Constructed(0)); search != nil && len(search.Children) > 0 {
+		criteria = search.Children[0]
+	}
+	list := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0))
+	for _, p := range c.profiles {
+		switch {
+		case criteria == nil:
+		case criteria.Tag.If(bertlv.ContextSpecific, bertlv.Primitive, 21):
+			if len(criteria.Value) == 0 || sgp22.ProfileClass(criteria.Value[0]) != p.Class {
+				continue
+			}
+		case c.find(criteria) == -1 || c.profiles[c.find(criteria)].ICCID != p.ICCID:
+			continue
+		}
+		list.Children = append(list.Children, c.profileInfo(p))
+	}
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(45), list), nil
+}
+
+func (c *Card) profileOperation(request *bertlv.TLV) (*bertlv.TLV, error) {
+	operation := sgp22.ProfileOperation(request.Tag.Value())
+	identifier := request.First(bertlv.Application.Primitive(26))
+	if identifier == nil {
+		identifier = request.First(bertlv.Application.Primitive(15))
+	}
+	if wrapped := request.First(bertlv.ContextSpecific.Constructed(0)); wrapped != nil && len(wrapped.Children) > 0 {
+		identifier = wrapped.Children[0]
+	}
+	index := c.find(identifier)
+	if index == -1 {
+		return result(uint64(operation), 1), nil
+	}
+	target := &c.profiles[index]
+	switch operation {
+	case sgp22.EnableProfile:
+		if target.Enabled {
+			return result(uint64(operation), 2), nil
+		}
+		for i := range c.profiles {
+			if c.profiles[i].Enabled {
+				c.profiles[i].Enabled = false
+				c.notify(sgp22.NotificationEventDisable, c.profiles[i])
+			}
+		}
+		target.Enabled = true
+		c.notify(sgp22.NotificationEventEnable, *target)
+	case sgp22.DisableProfile:
+		if !target.Enabled {
+			return result(uint64(operation), 2), nil
+		}
+		target.Enabled = false
+		c.notify(sgp22.NotificationEventDisable, *target)
+	case sgp22.DeleteProfile:
+		if target.Enabled {
+			return result(uint64(operation), 2), nil
+		}
+		c.notify(sgp22.NotificationEventDelete, *target)
+		c.profiles = slices.Delete(c.profiles, index, index+1)
+	}
+	return result(uint64(operation), 0), nil
+}
+
+func (c *Card) notify(event sgp22.NotificationEvent, p Profile) {
+	if p.NotificationAddress == "" {
+		return
+	}
+	c.sequence++
+	c.notifications = append(c.notifications, Notification{
+		SequenceNumber: c.sequence,
+		Event:          event,
+		Address:        p.NotificationAddress,
+		ICCID:          p.ICCID,
+	})
+}
+
+func (c *Card) setNickname(request *bertlv.TLV) (*bertlv.TLV, error) {
+	index := c.find(request.First(bertlv.Application.Primitive(26)))
+	if index == -1 {
+		return result(41, 1), nil
+	}
+	var nickname string
+	if value := request.First(bertlv.ContextSpecific.Primitive(16)); value != nil {
+		nickname = string(value.Value)
+	}
+	c.profiles[index].Nickname = nickname
+	return result(41, 0), nil
+}
+
+func notificationMetadata(n Notification) *bertlv.TLV {
+	sequence, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), n.SequenceNumber)
+	operation, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(1), &n.Event)
+	iccid, _ := sgp22.NewICCID(n.ICCID)
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(47),
+		sequence,
+		operation,
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte(n.Address)),
+		bertlv.NewValue(bertlv.Application.Primitive(26), iccid),
+	)
+}
+
+func (c *Card) listNotification(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var filter []bool
+	if value := request.First(bertlv.ContextSpecific.Primitive(1)); value != nil {
+		if err := value.UnmarshalValue(primitive.UnmarshalBitString(&filter)); err != nil {
+			return nil, err
+		}
+	}
+	list := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0))
+	for _, n := range c.notifications {
+		if filter != nil && (int(n.Event) >= len(filter) || !filter[n.Event]) {
+			continue
+		}
+		list.Children = append(list.Children, notificationMetadata(n))
+	}
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(40), list), nil
+}
+
+func (c *Card) retrieveNotifications(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var match func(Notification) bool
+	if search := request.First(bertlv.ContextSpecific.Constructed(0)); search != nil {
+		if value := search.First(bertlv.ContextSpecific.Primitive(0)); value != nil {
+			var sequence sgp22.SequenceNumber
+			if err := value.UnmarshalValue(primitive.UnmarshalInt(&sequence)); err != nil {
+				return nil, err
+			}
+			match = func(n Notification) bool { return n.SequenceNumber == sequence }
+		}
+		if value := search.First(bertlv.ContextSpecific.Primitive(1)); value != nil {
+			var event sgp22.NotificationEvent
+			if err := value.UnmarshalValue(&event); err != nil {
+				return nil, err
+			}
+			match = func(n Notification) bool { return n.Event == event }
+		}
+	}
+	response := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(43))
+	for _, n := range c.notifications {
+		if match != nil && !match(n) {
+			continue
+		}
+		// OtherSignedNotification with a dummy signature; certificates are omitted.
+		signed := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+			notificationMetadata(n),
+			bertlv.NewValue(bertlv.Application.Primitive(55), make([]byte, 64)),
+		)
+		response.Children = append(response.Children, bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), signed))
+	}
+	if len(response.Children) == 0 {
+		// notificationsListResultError: noResultAvailable
+		response.Children = append(response.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{1}))
+	}
+	return response, nil
+}
+
+func (c *Card) removeNotification(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var sequence sgp22.SequenceNumber
+	value := request.First(bertlv.ContextSpecific.Primitive(0))
+	if value == nil {
+		return nil, errors.New("missing sequence number")
+	}
+	if err := value.UnmarshalValue(primitive.UnmarshalInt(&sequence)); err != nil {
+		return nil, err
+	}
+	index := slices.IndexFunc(c.notifications, func(n Notification) bool { return n.SequenceNumber == sequence })
+	if index == -1 {
+		return result(48, 1), nil
+	}
+	c.notifications = slices.Delete(c.notifications, index, index+1)
+	return result(48, 0), nil
+}
+
+func (c *Card) memoryReset(request *bertlv.TLV) (*bertlv.TLV, error) {
+	var options []bool
+	value := request.First(bertlv.Application.Primitive(2))
+	if value == nil {
+		return nil, errors.New("missing reset options")
+	}
+	if err := value.UnmarshalValue(primitive.UnmarshalBitString(&options)); err != nil {
+		return nil, err
+	}
+	options = append(options, make([]bool, 3)...)
+	deleted := false
+	c.profiles = slices.DeleteFunc(c.profiles, func(p Profile) bool {
+		remove := (options[0] && p.Class == sgp22.ProfileClassOperational) || (options[1] && p.Class == sgp22.ProfileClassTest)
+		deleted = deleted || remove
+		return remove
+	})
+	if options[2] && c.defaultSMDPAddress != "" {
+		c.defaultSMDPAddress = ""
+		deleted = true
+	}
+	if !deleted {
+		return result(52, 1), nil
+	}
+	return result(52, 0), nil
+}
+
+func (c *Card) configuredAddresses() *bertlv.TLV {
+	response := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(60))
+	if c.defaultSMDPAddress != "" {
+		response.Children = append(response.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte(c.defaultSMDPAddress)))
+	}
+	response.Children = append(response.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte(c.rootSMDSAddress)))
+	return response
+}
+
+func (c *Card) setDefaultDPAddress(request *bertlv.TLV) *bertlv.TLV {
+	c.defaultSMDPAddress = ""
+	if value := request.First(bertlv.ContextSpecific.Primitive(0)); value != nil {
+		c.defaultSMDPAddress = string(value.Value)
+	}
+	return result(63, 0)
+}
+
+func (c *Card) euiccChallenge() *bertlv.TLV {
+	challenge := make([]byte, 16)
+	_, _ = rand.Read(challenge)
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(46), bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), challenge))
+}
+
+// ciPKID is the subject key identifier of the GSMA test CI.
+var ciPKID = []byte{0xF5, 0x41, 0x72, 0xBD, 0xF9, 0x8A, 0x95, 0xD6, 0x5C, 0xBE, 0xB8, 0x8A, 0x38, 0xA1, 0xC1, 0x1D, 0x80, 0x0A, 0x85, 0xC3}
+
+func (c *Card) euiccInfo1() *bertlv.TLV {
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(32),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{2, 2, 2}),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(9), bertlv.NewValue(bertlv.Universal.Primitive(4), ciPKID)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(10), bertlv.NewValue(bertlv.Universal.Primitive(4), ciPKID)),
+	)
+}
+
+func (c *Card) euiccInfo2() *bertlv.TLV {
+	// extCardResource: one installed application, 256 KiB non-volatile and 32 KiB volatile memory free.
+	resource := []byte{0x81, 0x01, 0x01, 0x82, 0x03, 0x04, 0x00, 0x00, 0x83, 0x02, 0x7F, 0xFF}
+	uicc, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(5), primitive.MarshalBitString([]bool{false, true, true}))
+	rsp, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(8), primitive.MarshalBitString([]bool{true, false, false, true}))
+	category, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(11), primitive.MarshalInt(int8(2)))
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(34),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{2, 3, 1}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{2, 2, 2}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte{1, 0, 0}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), resource),
+		uicc,
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), []byte{9, 2, 0}),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), []byte{2, 3, 0}),
+		rsp,
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(9), bertlv.NewValue(bertlv.Universal.Primitive(4), ciPKID)),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(10), bertlv.NewValue(bertlv.Universal.Primitive(4), ciPKID)),
+		category,
+		bertlv.NewValue(bertlv.Universal.Primitive(4), []byte{0, 0, 1}),
+		bertlv.NewValue(bertlv.Universal.Primitive(12), []byte("FAKE-SAS-0001")),
+	)
+}
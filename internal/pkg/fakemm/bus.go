@@ -0,0 +1,96 @@
+package fakemm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+// Bus is a Server shared by the tests of a package, with a modem.Manager talking
+// to it. Start it from TestMain and close it once the tests have run:
+//
+//	func TestMain(m *testing.M) {
+//		bus = fakemm.NewBus()
+//		code := m.Run()
+//		bus.Close()
+//		os.Exit(code)
+//	}
+type Bus struct {
+	Server  *Server
+	Manager *modem.Manager
+	err     error
+
+	mu     sync.Mutex
+	modems int // modems added so far, to give each test its own
+}
+
+// NewBus starts a Server and a manager connected to it. If either fails to start,
+// the tests that add a modem are skipped.
+func NewBus() *Bus {
+	b := &Bus{}
+	b.Server, b.err = Start()
+	if b.err == nil {
+		b.Manager, b.err = modem.NewManager()
+	}
+	return b
+}
+
+// Close stops the server.
+func (b *Bus) Close() {
+	if b.Server == nil {
+		return
+	}
+	if err := b.Server.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "stopping fake ModemManager:", err)
+	}
+}
+
+// AddModem adds a modem for the test and waits for the manager to learn about it.
+// Without an equipment identifier the modem gets a new one, and without SIMs it
+// gets one SIM. The modem is removed when the test ends.
+func (b *Bus) AddModem(t *testing.T, config ModemConfig) (*Modem, *modem.Modem) {
+	t.Helper()
+	if b.err != nil {
+		t.Skipf("fake ModemManager is unavailable: %v", b.err)
+	}
+	if config.EquipmentIdentifier == "" {
+		b.mu.Lock()
+		b.modems++
+		config.EquipmentIdentifier = fmt.Sprintf("86000000000%04d", b.modems)
+		b.mu.Unlock()
+	}
+	if len(config.SIMs) == 0 {
+		config.SIMs = []SIMConfig{{Identifier: "89860000000000000011"}}
+	}
+	fake, err := b.Server.AddModem(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Server.RemoveModem(config.EquipmentIdentifier) })
+	return fake, b.WaitModem(t, config.EquipmentIdentifier)
+}
+
+// WaitModem waits for the manager to know a modem, e.g. after it was restarted.
+func (b *Bus) WaitModem(t *testing.T, equipmentIdentifier string) *modem.Modem {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		modems, err := b.Manager.Modems()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range modems {
+			if m.EquipmentIdentifier == equipmentIdentifier {
+				return m
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("modem %s did not appear", equipmentIdentifier)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
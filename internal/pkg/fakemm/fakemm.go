@@ -0,0 +1,187 @@
+// Package fakemm runs a stand-in ModemManager on a private D-Bus daemon.
+//
+// Start launches dbus-daemon, claims the ModemManager bus name on it and points
+// DBUS_SYSTEM_BUS_ADDRESS at it, so modem.NewManager and everything built on it
+// talk to the fake. The system bus connection is shared by the whole process,
+// so start a single Server per test binary, typically from TestMain.
+package fakemm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow user="*"/>
+    <allow own="*"/>
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+  </policy>
+</busconfig>
+`
+
+const objectManagerInterface = "org.freedesktop.DBus.ObjectManager"
+
+// Server is a fake ModemManager. Modems are added with AddModem.
+type Server struct {
+	cmd  *exec.Cmd
+	dir  string
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	modems  map[dbus.ObjectPath]*Modem
+	nextID  map[string]int
+	Inhibit []string // device UIDs passed to InhibitDevice, in order
+}
+
+// Start launches a private bus and registers the fake ModemManager on it.
+func Start() (*Server, error) {
+	dir, err := os.MkdirTemp("", "fakemm")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		dir:    dir,
+		modems: make(map[dbus.ObjectPath]*Modem),
+		nextID: make(map[string]int),
+	}
+	if err := s.start(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) start() error {
+	config := filepath.Join(s.dir, "bus.conf")
+	if err := os.WriteFile(config, fmt.Appendf(nil, busConfig, filepath.Join(s.dir, "bus")), 0o600); err != nil {
+		return err
+	}
+	s.cmd = exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := s.cmd.Start(); err != nil {
+		return fmt.Errorf("starting dbus-daemon: %w", err)
+	}
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading bus address: %w", err)
+	}
+	address = strings.TrimSpace(address)
+	if err := os.Setenv("DBUS_SYSTEM_BUS_ADDRESS", address); err != nil {
+		return err
+	}
+
+	if s.conn, err = dbus.Connect(address); err != nil {
+		return err
+	}
+	reply, err := s.conn.RequestName(modem.ModemManagerInterface, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return errors.New("bus name already taken")
+	}
+	if err := s.conn.Export(&managerObject{s}, modem.ModemManagerObjectPath, modem.ModemManagerInterface); err != nil {
+		return err
+	}
+	return s.conn.Export(&objectManager{s}, modem.ModemManagerObjectPath, objectManagerInterface)
+}
+
+// Close stops the bus and removes its socket.
+func (s *Server) Close() error {
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+	}
+	if s.cmd != nil && s.cmd.Process != nil {
+		err = errors.Join(err, s.cmd.Process.Kill())
+		_ = s.cmd.Wait()
+	}
+	return errors.Join(err, os.RemoveAll(s.dir))
+}
+
+func (s *Server) nextPath(kind string) dbus.ObjectPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID[kind]
+	s.nextID[kind]++
+	return dbus.ObjectPath(fmt.Sprintf("%s/%s/%d", modem.ModemManagerObjectPath, kind, id))
+}
+
+// Modem returns the fake modem with the given equipment identifier.
+func (s *Server) Modem(equipmentIdentifier string) (*Modem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.modems {
+		if m.config.EquipmentIdentifier == equipmentIdentifier {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// RemoveModem unexports a modem and announces it like an unplug.
+func (s *Server) RemoveModem(equipmentIdentifier string) error {
+	m, ok := s.Modem(equipmentIdentifier)
+	if !ok {
+		return fmt.Errorf("modem %s not found", equipmentIdentifier)
+	}
+	s.mu.Lock()
+	delete(s.modems, m.path)
+	s.mu.Unlock()
+	return s.conn.Emit(modem.ModemManagerObjectPath, modem.ModemManagerInterfacesRemoved, m.path, m.interfaces())
+}
+
+func (s *Server) announce(m *Modem) error {
+	return s.conn.Emit(modem.ModemManagerObjectPath, modem.ModemManagerInterfacesAdded, m.path, m.properties())
+}
+
+type managerObject struct{ s *Server }
+
+func (o *managerObject) ScanDevices() *dbus.Error {
+	return nil
+}
+
+func (o *managerObject) InhibitDevice(uid string, inhibit bool) *dbus.Error {
+	o.s.mu.Lock()
+	defer o.s.mu.Unlock()
+	if inhibit {
+		o.s.Inhibit = append(o.s.Inhibit, uid)
+	}
+	return nil
+}
+
+type objectManager struct{ s *Server }
+
+func (o *objectManager) GetManagedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+	o.s.mu.Lock()
+	modems := make([]*Modem, 0, len(o.s.modems))
+	for _, m := range o.s.modems {
+		modems = append(modems, m)
+	}
+	o.s.mu.Unlock()
+	objects := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant, len(modems))
+	for _, m := range modems {
+		objects[m.path] = m.properties()
+	}
+	return objects, nil
+}
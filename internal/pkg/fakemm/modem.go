@@ -0,0 +1,625 @@
+package fakemm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+const propertiesInterface = "org.freedesktop.DBus.Properties"
+
+var (
+	errUnknownInterface = dbus.NewError(propertiesInterface+".Error.InterfaceNotFound", nil)
+	errUnknownProperty  = dbus.NewError(propertiesInterface+".Error.PropertyNotFound", nil)
+	errReadOnly         = dbus.NewError(propertiesInterface+".Error.ReadOnly", nil)
+)
+
+// ModemConfig describes a fake modem.
+type ModemConfig struct {
+	EquipmentIdentifier string
+	Manufacturer        string
+	Model               string
+	Revision            string
+	Number              string
+	// PrimaryPort is the AT port device name without "/dev/", e.g. "ttyUSB2".
+	PrimaryPort string
+	// SIMs has one entry per slot; the first one is active.
+	SIMs               []SIMConfig
+	SignalQuality      uint32
+	AccessTechnologies modem.ModemAccessTechnology
+	OperatorCode       string
+	OperatorName       string
+	Networks           []Network
+	// Signal is reported through the Signal interface once it has been set up.
+	Signal modem.SignalMetrics
+	// USSD answers USSD requests and responses. Without it USSD calls fail.
+	USSD func(request string) (string, error)
+}
+
+// SIMConfig describes the SIM in a slot.
+type SIMConfig struct {
+	Identifier         string
+	EID                string
+	IMSI               string
+	OperatorIdentifier string
+	OperatorName       string
+}
+
+// Network is a network returned by a 3GPP scan.
+type Network struct {
+	OperatorCode      string
+	OperatorName      string
+	OperatorShortName string
+	Status            modem.Modem3gppNetworkAvailability
+	AccessTechnology  modem.ModemAccessTechnology
+}
+
+// Message is an SMS stored on a fake modem.
+type Message struct {
+	Path      dbus.ObjectPath
+	State     modem.SMSState
+	Number    string
+	Text      string
+	Timestamp time.Time
+}
+
+// Modem is a modem exported by the fake ModemManager.
+type Modem struct {
+	server *Server
+	path   dbus.ObjectPath
+
+	mu           sync.Mutex
+	config       ModemConfig
+	state        modem.ModemState
+	primarySlot  uint32
+	simPaths     []dbus.ObjectPath
+	registration modem.Modem3gppRegistrationState
+	ussdState    modem.Modem3gppUssdSessionState
+	messages     []*Message
+	reprobe      bool
+	signalRate   uint32
+}
+
+// ReprobeDelay is how long a modem takes to reappear after a restart or SIM slot switch.
+// ModemManager needs a few seconds on real hardware; callers subscribe in the meantime.
+var ReprobeDelay = 200 * time.Millisecond
+
+// AddModem exports a modem and announces it like a plug-in.
+func (s *Server) AddModem(config ModemConfig) (*Modem, error) {
+	if len(config.SIMs) == 0 {
+		return nil, errors.New("at least one SIM is required")
+	}
+	if config.PrimaryPort == "" {
+		config.PrimaryPort = "ttyUSB2"
+	}
+	m := &Modem{
+		server:      s,
+		path:        s.nextPath("Modem"),
+		config:      config,
+		state:       modem.ModemStateEnabled,
+		primarySlot: 1,
+		ussdState:   modem.Modem3gppUssdSessionStateIdle,
+	}
+	if config.OperatorCode != "" {
+		m.state = modem.ModemStateRegistered
+		m.registration = modem.Modem3gppRegistrationStateHome
+	}
+	for index := range config.SIMs {
+		path := s.nextPath("SIM")
+		m.simPaths = append(m.simPaths, path)
+		if err := s.conn.Export(&properties{func() map[string]map[string]dbus.Variant {
+			return m.simProperties(index)
+		}}, path, propertiesInterface); err != nil {
+			return nil, err
+		}
+	}
+	exports := map[string]any{
+		modem.ModemInterface:               &modemObject{m},
+		modem.ModemInterface + ".Simple":   &simpleObject{m},
+		modem.Modem3GPPInterface:           &threeGPPObject{m},
+		modem.Modem3GPPInterface + ".Ussd": &ussdObject{m},
+		modem.ModemMessagingInterface:      &messagingObject{m},
+		modem.ModemSignalInterface:         &signalObject{m},
+		propertiesInterface:                &properties{m.properties},
+	}
+	for iface, object := range exports {
+		if err := s.conn.Export(object, m.path, iface); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	s.modems[m.path] = m
+	s.mu.Unlock()
+	return m, s.announce(m)
+}
+
+// Path returns the D-Bus object path of the modem.
+func (m *Modem) Path() dbus.ObjectPath {
+	return m.path
+}
+
+// UpdateSIM replaces the SIM in a slot (zero-based), e.g. after a profile switch,
+// and emits PropertiesChanged for it.
+func (m *Modem) UpdateSIM(index int, config SIMConfig) error {
+	m.mu.Lock()
+	m.config.SIMs[index] = config
+	path := m.simPaths[index]
+	m.mu.Unlock()
+	return m.emitChanged(path, modem.ModemSimInterface, m.simProperties(index)[modem.ModemSimInterface])
+}
+
+// SetSignalQuality changes the signal quality in percent and emits PropertiesChanged for it.
+func (m *Modem) SetSignalQuality(percent uint32) error {
+	m.mu.Lock()
+	m.config.SignalQuality = percent
+	m.mu.Unlock()
+	return m.emitChanged(m.path, modem.ModemInterface, map[string]dbus.Variant{
+		"SignalQuality": dbus.MakeVariant(signalQuality{Percent: percent, Recent: true}),
+	})
+}
+
+// SetAccessTechnologies changes the access technologies and emits PropertiesChanged for them.
+func (m *Modem) SetAccessTechnologies(access modem.ModemAccessTechnology) error {
+	m.mu.Lock()
+	m.config.AccessTechnologies = access
+	m.mu.Unlock()
+	return m.emitChanged(m.path, modem.ModemInterface, map[string]dbus.Variant{
+		"AccessTechnologies": dbus.MakeVariant(uint32(access)),
+	})
+}
+
+// SetRegistration changes the 3GPP registration and emits PropertiesChanged for it.
+// An empty operator code leaves the modem unregistered.
+func (m *Modem) SetRegistration(state modem.Modem3gppRegistrationState, operatorCode, operatorName string) error {
+	m.mu.Lock()
+	m.registration = state
+	m.config.OperatorCode = operatorCode
+	m.config.OperatorName = operatorName
+	m.mu.Unlock()
+	return m.emitChanged(m.path, modem.Modem3GPPInterface, map[string]dbus.Variant{
+		"RegistrationState": dbus.MakeVariant(uint32(state)),
+		"OperatorCode":      dbus.MakeVariant(operatorCode),
+		"OperatorName":      dbus.MakeVariant(operatorName),
+	})
+}
+
+// SetSignal changes the detailed signal measurements.
+func (m *Modem) SetSignal(metrics modem.SignalMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.Signal = metrics
+}
+
+// emitChanged emits org.freedesktop.DBus.Properties.PropertiesChanged for object path.
+func (m *Modem) emitChanged(path dbus.ObjectPath, iface string, changed map[string]dbus.Variant) error {
+	return m.server.conn.Emit(path, propertiesInterface+".PropertiesChanged", iface, changed, []string{})
+}
+
+// Messages returns a copy of the messages stored on the modem.
+func (m *Modem) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := make([]Message, 0, len(m.messages))
+	for _, message := range m.messages {
+		messages = append(messages, *message)
+	}
+	return messages
+}
+
+// Receive stores an incoming SMS and emits Messaging.Added for it.
+func (m *Modem) Receive(number, text string) (dbus.ObjectPath, error) {
+	path, err := m.addMessage(modem.SMSStateReceived, number, text)
+	if err != nil {
+		return "", err
+	}
+	return path, m.server.conn.Emit(m.path, modem.ModemMessagingInterface+".Added", path, true)
+}
+
+func (m *Modem) addMessage(state modem.SMSState, number, text string) (dbus.ObjectPath, error) {
+	message := &Message{
+		Path:      m.server.nextPath("SMS"),
+		State:     state,
+		Number:    number,
+		Text:      text,
+		Timestamp: time.Now().Truncate(time.Second),
+	}
+	if err := m.server.conn.Export(&smsObject{m, message}, message.Path, modem.ModemSMSInterface); err != nil {
+		return "", err
+	}
+	if err := m.server.conn.Export(&properties{func() map[string]map[string]dbus.Variant {
+		return m.smsProperties(message)
+	}}, message.Path, propertiesInterface); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	m.messages = append(m.messages, message)
+	m.mu.Unlock()
+	return message.Path, nil
+}
+
+func (m *Modem) interfaces() []string {
+	return []string{
+		modem.ModemInterface,
+		modem.ModemInterface + ".Simple",
+		modem.Modem3GPPInterface,
+		modem.Modem3GPPInterface + ".Ussd",
+		modem.ModemMessagingInterface,
+		modem.ModemSignalInterface,
+	}
+}
+
+type port struct {
+	Name string
+	Type uint32
+}
+
+type signalQuality struct {
+	Percent uint32
+	Recent  bool
+}
+
+func (m *Modem) properties() map[string]map[string]dbus.Variant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var numbers []string
+	if m.config.Number != "" {
+		numbers = []string{m.config.Number}
+	}
+	return map[string]map[string]dbus.Variant{
+		modem.ModemInterface: {
+			"Device":              dbus.MakeVariant("/sys/devices/fake/" + m.config.EquipmentIdentifier),
+			"Manufacturer":        dbus.MakeVariant(m.config.Manufacturer),
+			"EquipmentIdentifier": dbus.MakeVariant(m.config.EquipmentIdentifier),
+			"Drivers":             dbus.MakeVariant([]string{"option"}),
+			"Model":               dbus.MakeVariant(m.config.Model),
+			"Revision":            dbus.MakeVariant(m.config.Revision),
+			"HardwareRevision":    dbus.MakeVariant("1.0"),
+			"State":               dbus.MakeVariant(int32(m.state)),
+			"PrimaryPort":         dbus.MakeVariant(m.config.PrimaryPort),
+			"Ports":               dbus.MakeVariant([]port{{Name: m.config.PrimaryPort, Type: uint32(modem.ModemPortTypeAt)}}),
+			"PrimarySimSlot":      dbus.MakeVariant(m.primarySlot),
+			"Sim":                 dbus.MakeVariant(m.simPaths[m.primarySlot-1]),
+			"SimSlots":            dbus.MakeVariant(slices.Clone(m.simPaths)),
+			"OwnNumbers":          dbus.MakeVariant(numbers),
+			"SignalQuality":       dbus.MakeVariant(signalQuality{Percent: m.config.SignalQuality, Recent: true}),
+			"AccessTechnologies":  dbus.MakeVariant(uint32(m.config.AccessTechnologies)),
+		},
+		modem.ModemInterface + ".Simple": {},
+		modem.Modem3GPPInterface: {
+			"Imei":              dbus.MakeVariant(m.config.EquipmentIdentifier),
+			"RegistrationState": dbus.MakeVariant(uint32(m.registration)),
+			"OperatorCode":      dbus.MakeVariant(m.config.OperatorCode),
+			"OperatorName":      dbus.MakeVariant(m.config.OperatorName),
+		},
+		modem.Modem3GPPInterface + ".Ussd": {
+			"State":               dbus.MakeVariant(uint32(m.ussdState)),
+			"NetworkRequest":      dbus.MakeVariant(""),
+			"NetworkNotification": dbus.MakeVariant(""),
+		},
+		modem.ModemMessagingInterface: {},
+		modem.ModemSignalInterface:    m.signalProperties(),
+	}
+}
+
+// signalProperties returns the Signal interface properties. Like ModemManager,
+// measurements are only reported while the refresh rate is set.
+func (m *Modem) signalProperties() map[string]dbus.Variant {
+	properties := map[string]dbus.Variant{"Rate": dbus.MakeVariant(m.signalRate)}
+	for name, measurement := range map[string]*modem.SignalMeasurement{
+		"Gsm":  m.config.Signal.GSM,
+		"Umts": m.config.Signal.UMTS,
+		"Lte":  m.config.Signal.LTE,
+		"Nr5g": m.config.Signal.NR5G,
+	} {
+		values := map[string]dbus.Variant{}
+		if measurement != nil && m.signalRate > 0 {
+			for key, value := range map[string]*float64{
+				"rssi":       measurement.RSSI,
+				"rsrp":       measurement.RSRP,
+				"rsrq":       measurement.RSRQ,
+				"snr":        measurement.SINR,
+				"rscp":       measurement.RSCP,
+				"ecio":       measurement.ECIO,
+				"error-rate": measurement.ErrorRate,
+			} {
+				if value != nil {
+					values[key] = dbus.MakeVariant(*value)
+				}
+			}
+		}
+		properties[name] = dbus.MakeVariant(values)
+	}
+	return properties
+}
+
+func (m *Modem) simProperties(index int) map[string]map[string]dbus.Variant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sim := m.config.SIMs[index]
+	return map[string]map[string]dbus.Variant{
+		modem.ModemSimInterface: {
+			"Active":             dbus.MakeVariant(uint32(index+1) == m.primarySlot),
+			"SimIdentifier":      dbus.MakeVariant(sim.Identifier),
+			"Eid":                dbus.MakeVariant(sim.EID),
+			"Imsi":               dbus.MakeVariant(sim.IMSI),
+			"OperatorIdentifier": dbus.MakeVariant(sim.OperatorIdentifier),
+			"OperatorName":       dbus.MakeVariant(sim.OperatorName),
+		},
+	}
+}
+
+func (m *Modem) smsProperties(message *Message) map[string]map[string]dbus.Variant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return map[string]map[string]dbus.Variant{
+		modem.ModemSMSInterface: {
+			"State":     dbus.MakeVariant(uint32(message.State)),
+			"Number":    dbus.MakeVariant(message.Number),
+			"Text":      dbus.MakeVariant(message.Text),
+			"Timestamp": dbus.MakeVariant(message.Timestamp.Format("2006-01-02T15:04:05-07")),
+		},
+	}
+}
+
+// scheduleReprobe makes the modem disappear and come back, as ModemManager
+// does after the SIM was refreshed or switched.
+func (m *Modem) scheduleReprobe() {
+	time.AfterFunc(ReprobeDelay, func() {
+		s := m.server
+		s.mu.Lock()
+		_, exported := s.modems[m.path]
+		s.mu.Unlock()
+		if !exported {
+			return
+		}
+		_ = s.conn.Emit(modem.ModemManagerObjectPath, modem.ModemManagerInterfacesRemoved, m.path, m.interfaces())
+		_ = s.announce(m)
+	})
+}
+
+type properties struct {
+	all func() map[string]map[string]dbus.Variant
+}
+
+func (p *properties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	values, ok := p.all()[iface]
+	if !ok {
+		return dbus.Variant{}, errUnknownInterface
+	}
+	value, ok := values[name]
+	if !ok {
+		return dbus.Variant{}, errUnknownProperty
+	}
+	return value, nil
+}
+
+func (p *properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	values, ok := p.all()[iface]
+	if !ok {
+		return nil, errUnknownInterface
+	}
+	return values, nil
+}
+
+func (p *properties) Set(string, string, dbus.Variant) *dbus.Error {
+	return errReadOnly
+}
+
+type modemObject struct{ m *Modem }
+
+func (o *modemObject) Enable(enable bool) *dbus.Error {
+	o.m.mu.Lock()
+	if !enable {
+		o.m.state = modem.ModemStateDisabled
+		o.m.reprobe = true
+	} else {
+		o.m.state = modem.ModemStateEnabled
+		if o.m.registration == modem.Modem3gppRegistrationStateHome || o.m.registration == modem.Modem3gppRegistrationStateRoaming {
+			o.m.state = modem.ModemStateRegistered
+		}
+		if o.m.reprobe {
+			o.m.reprobe = false
+			o.m.scheduleReprobe()
+		}
+	}
+	state := o.m.state
+	o.m.mu.Unlock()
+	_ = o.m.emitChanged(o.m.path, modem.ModemInterface, map[string]dbus.Variant{"State": dbus.MakeVariant(int32(state))})
+	return nil
+}
+
+func (o *modemObject) SetPrimarySimSlot(slot uint32) *dbus.Error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	if slot == 0 || int(slot) > len(o.m.simPaths) {
+		return dbus.MakeFailedError(fmt.Errorf("invalid SIM slot %d", slot))
+	}
+	o.m.primarySlot = slot
+	o.m.scheduleReprobe()
+	return nil
+}
+
+type simpleObject struct{ m *Modem }
+
+func (o *simpleObject) GetStatus() (map[string]dbus.Variant, *dbus.Error) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	return map[string]dbus.Variant{
+		"state":          dbus.MakeVariant(int32(o.m.state)),
+		"signal-quality": dbus.MakeVariant(signalQuality{Percent: o.m.config.SignalQuality, Recent: true}),
+	}, nil
+}
+
+type threeGPPObject struct{ m *Modem }
+
+func (o *threeGPPObject) Scan() ([]map[string]dbus.Variant, *dbus.Error) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	results := make([]map[string]dbus.Variant, 0, len(o.m.config.Networks))
+	for _, network := range o.m.config.Networks {
+		result := map[string]dbus.Variant{
+			"status":            dbus.MakeVariant(uint32(network.Status)),
+			"operator-code":     dbus.MakeVariant(network.OperatorCode),
+			"access-technology": dbus.MakeVariant(uint32(network.AccessTechnology)),
+		}
+		if network.OperatorName != "" {
+			result["operator-long"] = dbus.MakeVariant(network.OperatorName)
+		}
+		if network.OperatorShortName != "" {
+			result["operator-short"] = dbus.MakeVariant(network.OperatorShortName)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (o *threeGPPObject) Register(operatorCode string) *dbus.Error {
+	if err := o.register(operatorCode); err != nil {
+		return err
+	}
+	o.m.mu.Lock()
+	changed := map[string]dbus.Variant{
+		"RegistrationState": dbus.MakeVariant(uint32(o.m.registration)),
+		"OperatorCode":      dbus.MakeVariant(o.m.config.OperatorCode),
+		"OperatorName":      dbus.MakeVariant(o.m.config.OperatorName),
+	}
+	o.m.mu.Unlock()
+	_ = o.m.emitChanged(o.m.path, modem.Modem3GPPInterface, changed)
+	return nil
+}
+
+func (o *threeGPPObject) register(operatorCode string) *dbus.Error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	if operatorCode == "" {
+		o.m.registration = modem.Modem3gppRegistrationStateHome
+		return nil
+	}
+	index := slices.IndexFunc(o.m.config.Networks, func(n Network) bool { return n.OperatorCode == operatorCode })
+	if index == -1 || o.m.config.Networks[index].Status == modem.Modem3gppNetworkAvailabilityForbidden {
+		return dbus.MakeFailedError(fmt.Errorf("network %s not available", operatorCode))
+	}
+	o.m.config.OperatorCode = operatorCode
+	o.m.config.OperatorName = o.m.config.Networks[index].OperatorName
+	o.m.registration = modem.Modem3gppRegistrationStateRoaming
+	if len(o.m.config.SIMs) > 0 && len(operatorCode) >= 5 && len(o.m.config.SIMs[o.m.primarySlot-1].IMSI) >= len(operatorCode) &&
+		o.m.config.SIMs[o.m.primarySlot-1].IMSI[:len(operatorCode)] == operatorCode {
+		o.m.registration = modem.Modem3gppRegistrationStateHome
+	}
+	for i := range o.m.config.Networks {
+		if o.m.config.Networks[i].Status == modem.Modem3gppNetworkAvailabilityCurrent {
+			o.m.config.Networks[i].Status = modem.Modem3gppNetworkAvailabilityAvailable
+		}
+	}
+	o.m.config.Networks[index].Status = modem.Modem3gppNetworkAvailabilityCurrent
+	return nil
+}
+
+type signalObject struct{ m *Modem }
+
+func (o *signalObject) Setup(rate uint32) *dbus.Error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	o.m.signalRate = rate
+	return nil
+}
+
+type ussdObject struct{ m *Modem }
+
+func (o *ussdObject) reply(request string) (string, *dbus.Error) {
+	o.m.mu.Lock()
+	handler := o.m.config.USSD
+	o.m.mu.Unlock()
+	if handler == nil {
+		return "", dbus.MakeFailedError(errors.New("USSD is not supported"))
+	}
+	reply, err := handler(request)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	o.m.mu.Lock()
+	o.m.ussdState = modem.Modem3gppUssdSessionStateUserResponse
+	o.m.mu.Unlock()
+	return reply, nil
+}
+
+func (o *ussdObject) Initiate(command string) (string, *dbus.Error) {
+	return o.reply(command)
+}
+
+func (o *ussdObject) Respond(response string) (string, *dbus.Error) {
+	o.m.mu.Lock()
+	active := o.m.ussdState != modem.Modem3gppUssdSessionStateIdle
+	o.m.mu.Unlock()
+	if !active {
+		return "", dbus.MakeFailedError(errors.New("no active USSD session"))
+	}
+	return o.reply(response)
+}
+
+func (o *ussdObject) Cancel() *dbus.Error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	o.m.ussdState = modem.Modem3gppUssdSessionStateIdle
+	return nil
+}
+
+type messagingObject struct{ m *Modem }
+
+func (o *messagingObject) List() ([]dbus.ObjectPath, *dbus.Error) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	paths := make([]dbus.ObjectPath, 0, len(o.m.messages))
+	for _, message := range o.m.messages {
+		paths = append(paths, message.Path)
+	}
+	return paths, nil
+}
+
+func (o *messagingObject) Create(properties map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	number, _ := properties["number"].Value().(string)
+	text, _ := properties["text"].Value().(string)
+	if number == "" {
+		return "", dbus.MakeFailedError(errors.New("number is required"))
+	}
+	path, err := o.m.addMessage(modem.SMSStateStored, number, text)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return path, nil
+}
+
+func (o *messagingObject) Delete(path dbus.ObjectPath) *dbus.Error {
+	o.m.mu.Lock()
+	index := slices.IndexFunc(o.m.messages, func(message *Message) bool { return message.Path == path })
+	if index == -1 {
+		o.m.mu.Unlock()
+		return dbus.MakeFailedError(fmt.Errorf("message %s not found", path))
+	}
+	o.m.messages = slices.Delete(o.m.messages, index, index+1)
+	o.m.mu.Unlock()
+	_ = o.m.server.conn.Export(nil, path, modem.ModemSMSInterface)
+	_ = o.m.server.conn.Export(nil, path, propertiesInterface)
+	return nil
+}
+
+type smsObject struct {
+	m       *Modem
+	message *Message
+}
+
+func (o *smsObject) Send() *dbus.Error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	if o.message.State != modem.SMSStateStored {
+		return dbus.MakeFailedError(errors.New("message cannot be sent"))
+	}
+	o.message.State = modem.SMSStateSent
+	return nil
+}
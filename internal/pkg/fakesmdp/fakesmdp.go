@@ -0,0 +1,498 @@
+// Package fakesmdp runs a stand-in SM-DP+ that serves the ES9+ download
+// functions over HTTPS.
+//
+// The server generates its own test CI on Start, which issues its TLS and
+// DPauth/DPpb certificates. Pass RootCAs to lpa.WithRootCAs so LPA clients trust
+// it. Bound profile packages are not encrypted; they are meant for a card from
+// package fakecard, which loads them in clear text.
+package fakesmdp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+	sgp22 "github.com/damonto/euicc-go/v2"
+)
+
+// Profile is a download order, released to the eUICC that presents its matching ID.
+type Profile struct {
+	MatchingID          string
+	ICCID               string
+	ServiceProviderName string
+	ProfileName         string
+	Class               sgp22.ProfileClass
+	// ConfirmationCode, when set, must be entered by the user before the package is released.
+	ConfirmationCode string
+	// Size is the size of the profile elements in bytes. It defaults to 2048.
+	Size int
+}
+
+// Cancellation is a download session cancelled by the LPA.
+type Cancellation struct {
+	MatchingID string
+	Reason     sgp22.CancelSessionReason
+}
+
+// Server is a fake SM-DP+. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+	ci     *x509.Certificate
+	key    *ecdsa.PrivateKey
+	cert   []byte // DER DPauth/DPpb certificate
+
+	mu            sync.Mutex
+	profiles      map[string]Profile
+	transactions  map[string]*transaction
+	cancellations []Cancellation
+	notifications []sgp22.NotificationMetadata
+	downloads     []string
+}
+
+type transaction struct {
+	serverChallenge []byte
+	profile         *Profile
+}
+
+// Start issues a test CI and certificates and starts serving the given orders.
+func Start(profiles ...Profile) (*Server, error) {
+	s := &Server{
+		profiles:     make(map[string]Profile, len(profiles)),
+		transactions: make(map[string]*transaction),
+	}
+	for _, profile := range profiles {
+		if _, err := sgp22.NewICCID(profile.ICCID); err != nil {
+			return nil, fmt.Errorf("invalid ICCID %q: %w", profile.ICCID, err)
+		}
+		s.profiles[profile.MatchingID] = profile
+	}
+	tlsCert, err := s.issueCertificates()
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /gsma/rsp2/es9plus/initiateAuthentication", s.initiateAuthentication)
+	mux.HandleFunc("POST /gsma/rsp2/es9plus/authenticateClient", s.authenticateClient)
+	mux.HandleFunc("POST /gsma/rsp2/es9plus/getBoundProfilePackage", s.getBoundProfilePackage)
+	mux.HandleFunc("POST /gsma/rsp2/es9plus/cancelSession", s.cancelSession)
+	mux.HandleFunc("POST /gsma/rsp2/es9plus/handleNotification", s.handleNotification)
+	s.server = httptest.NewUnstartedServer(mux)
+	s.server.TLS = &tls.Config{Certificates: []tls.Certificate{tlsCert}}
+	s.server.StartTLS()
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Address returns the SM-DP+ address, host and port, as used in activation codes.
+func (s *Server) Address() string {
+	return s.server.Listener.Addr().String()
+}
+
+// ActivationCode returns the activation code of the order with matchingID.
+func (s *Server) ActivationCode(matchingID string) string {
+	return "LPA:1$" + s.Address() + "$" + matchingID
+}
+
+// RootCAs returns a pool holding the test CI.
+func (s *Server) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ci)
+	return pool
+}
+
+// Cancellations returns the sessions cancelled so far.
+func (s *Server) Cancellations() []Cancellation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.cancellations)
+}
+
+// Notifications returns the notifications received so far.
+func (s *Server) Notifications() []sgp22.NotificationMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.notifications)
+}
+
+// Downloads returns the ICCIDs of the bound profile packages released so far.
+func (s *Server) Downloads() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.downloads)
+}
+
+func (s *Server) issueCertificates() (tls.Certificate, error) {
+	var err error
+	if s.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return tls.Certificate{}, err
+	}
+	ciKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"sigmo"}, CommonName: "Test CI"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &ciKey.PublicKey, ciKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if s.ci, err = x509.ParseCertificate(der); err != nil {
+		return tls.Certificate{}, err
+	}
+	issue := func(serial int64, name string, key *ecdsa.PrivateKey, usage x509.KeyUsage, extUsage []x509.ExtKeyUsage) ([]byte, error) {
+		return x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{Organization: []string{"sigmo"}, CommonName: name},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(24 * time.Hour),
+			KeyUsage:     usage,
+			ExtKeyUsage:  extUsage,
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		}, s.ci, &key.PublicKey, ciKey)
+	}
+	if s.cert, err = issue(2, "Test SM-DP+", s.key, x509.KeyUsageDigitalSignature, nil); err != nil {
+		return tls.Certificate{}, err
+	}
+	tlsKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tlsDER, err := issue(3, "Test SM-DP+ TLS", tlsKey, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{tlsDER, der}, PrivateKey: tlsKey}, nil
+}
+
+// sign returns the ES9+ signature of data: r and s concatenated, tagged '5F37'.
+func (s *Server) sign(data *bertlv.TLV) (*bertlv.TLV, error) {
+	digest := sha256.Sum256(data.Bytes())
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	ss.FillBytes(signature[32:])
+	return bertlv.NewValue(bertlv.Application.Primitive(55), signature), nil
+}
+
+func (s *Server) certificate() *bertlv.TLV {
+	var tlv bertlv.TLV
+	_ = tlv.UnmarshalBinary(s.cert)
+	return &tlv
+}
+
+func success() *sgp22.Header {
+	return &sgp22.Header{ExecutionStatus: &sgp22.ExecutionStatus{Status: "Executed-Success"}}
+}
+
+func failed(subject, reason string) *sgp22.Header {
+	return &sgp22.Header{ExecutionStatus: &sgp22.ExecutionStatus{
+		Status:         "Failed",
+		StatusCodeData: &sgp22.StatusCodeData{SubjectCode: subject, ReasonCode: reason},
+	}}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, request any) bool {
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func respond(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Admin-Protocol", "gsma/rsp/v2.2.0")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) initiateAuthentication(w http.ResponseWriter, r *http.Request) {
+	var request sgp22.ES9InitiateAuthenticationRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	transactionID := make([]byte, 16)
+	challenge := make([]byte, 16)
+	_, _ = rand.Read(transactionID)
+	_, _ = rand.Read(challenge)
+	signed := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), request.Challenge),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(3), []byte(request.Address)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), challenge),
+	)
+	signature, err := s.sign(signed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.transactions[hex.EncodeToString(transactionID)] = &transaction{serverChallenge: challenge}
+	s.mu.Unlock()
+	respond(w, &sgp22.ES9InitiateAuthenticationResponse{
+		Header:        success(),
+		TransactionID: transactionID,
+		Signed1:       signed,
+		Signature1:    signature,
+		UsedIssuer:    bertlv.NewValue(bertlv.Universal.Primitive(4), s.ci.SubjectKeyId),
+		Certificate:   s.certificate(),
+	})
+}
+
+func (s *Server) authenticateClient(w http.ResponseWriter, r *http.Request) {
+	var request sgp22.ES9AuthenticateClientRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	s.mu.Lock()
+	tx, ok := s.transactions[hex.EncodeToString(request.TransactionID)]
+	s.mu.Unlock()
+	if !ok || request.Response == nil {
+		// TransactionId is unknown
+		respond(w, &sgp22.ES9AuthenticateClientResponse{Header: failed("8.10.1", "3.9")})
+		return
+	}
+	signed := request.Response.Select(bertlv.ContextSpecific.Constructed(0), bertlv.Universal.Constructed(16))
+	challenge := signed.Select(bertlv.ContextSpecific.Primitive(4))
+	if signed == nil || challenge == nil || !bytes.Equal(challenge.Value, tx.serverChallenge) {
+		// eUICC signature is invalid or serverChallenge is invalid
+		respond(w, &sgp22.ES9AuthenticateClientResponse{Header: failed("8.1", "6.1")})
+		return
+	}
+	var matchingID string
+	if id := signed.Select(bertlv.ContextSpecific.Constructed(0), bertlv.ContextSpecific.Primitive(0)); id != nil {
+		matchingID = string(id.Value)
+	}
+	s.mu.Lock()
+	profile, ok := s.profiles[matchingID]
+	if ok {
+		tx.profile = &profile
+	}
+	s.mu.Unlock()
+	if !ok {
+		respond(w, &sgp22.ES9AuthenticateClientResponse{Header: failed("8.2.6", "3.8")})
+		return
+	}
+
+	ccRequired, _ := bertlv.MarshalValue(bertlv.Universal.Primitive(1), primitive.MarshalBool(profile.ConfirmationCode != ""))
+	signed2 := bertlv.NewChildren(bertlv.Universal.Constructed(16),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), request.TransactionID),
+		ccRequired,
+	)
+	signature, err := s.sign(signed2)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, &sgp22.ES9AuthenticateClientResponse{
+		Header:          success(),
+		TransactionID:   request.TransactionID,
+		ProfileMetadata: s.metadata(profile),
+		Signed2:         signed2,
+		Signature2:      signature,
+		Certificate:     s.certificate(),
+	})
+}
+
+// metadata returns the StoreMetadataRequest of profile, notifying this server of every event.
+func (s *Server) metadata(profile Profile) *bertlv.TLV {
+	iccid, _ := sgp22.NewICCID(profile.ICCID)
+	class, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(21), profile.Class)
+	events, _ := bertlv.MarshalValue(bertlv.ContextSpecific.Primitive(0), primitive.MarshalBitString([]bool{true, true, true, true}))
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(37),
+		bertlv.NewValue(bertlv.Application.Primitive(26), iccid),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(17), []byte(profile.ServiceProviderName)),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(18), []byte(profile.ProfileName)),
+		class,
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(22),
+			bertlv.NewChildren(bertlv.Universal.Constructed(16),
+				events,
+				bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte(s.Address())),
+			),
+		),
+	)
+}
+
+func (s *Server) getBoundProfilePackage(w http.ResponseWriter, r *http.Request) {
+	var request sgp22.ES9BoundProfilePackageRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	key := hex.EncodeToString(request.TransactionID)
+	s.mu.Lock()
+	tx, ok := s.transactions[key]
+	s.mu.Unlock()
+	if !ok || tx.profile == nil || request.Response == nil {
+		respond(w, &sgp22.ES9BoundProfilePackageResponse{Header: failed("8.10.1", "3.9")})
+		return
+	}
+	signed := request.Response.Select(bertlv.ContextSpecific.Constructed(0), bertlv.Universal.Constructed(16))
+	if signed == nil {
+		// The eUICC returned downloadResponseError.
+		respond(w, &sgp22.ES9BoundProfilePackageResponse{Header: failed("8.1", "6.1")})
+		return
+	}
+	if code := tx.profile.ConfirmationCode; code != "" {
+		hashed := signed.First(bertlv.Universal.Primitive(4))
+		if hashed == nil {
+			respond(w, &sgp22.ES9BoundProfilePackageResponse{Header: failed("8.2.7", "2.2")})
+			return
+		}
+		// hashCc = SHA256(SHA256(confirmationCode) | transactionId)
+		digest := sha256.Sum256([]byte(code))
+		expected := sha256.Sum256(append(digest[:], request.TransactionID...))
+		if !bytes.Equal(hashed.Value, expected[:]) {
+			respond(w, &sgp22.ES9BoundProfilePackageResponse{Header: failed("8.2.7", "3.8")})
+			return
+		}
+	}
+	bpp, err := s.boundProfilePackage(request.TransactionID, *tx.profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The session stays open: the LPA may still cancel it if the eUICC fails to install the package.
+	s.mu.Lock()
+	s.downloads = append(s.downloads, tx.profile.ICCID)
+	s.mu.Unlock()
+	respond(w, &sgp22.ES9BoundProfilePackageResponse{
+		Header:              success(),
+		TransactionID:       request.TransactionID,
+		BoundProfilePackage: bpp,
+	})
+}
+
+// boundProfilePackage builds a clear-text bound profile package for profile.
+func (s *Server) boundProfilePackage(transactionID []byte, profile Profile) (*bertlv.TLV, error) {
+	otpk := make([]byte, 65)
+	otpk[0] = 0x04
+	_, _ = rand.Read(otpk[1:])
+	secureChannel := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(35),
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(2), []byte{1}), // installBoundProfilePackage
+		bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), transactionID),
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(6),
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(0), []byte{0x88}),       // keyType: AES
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(1), []byte{0x10}),       // keyLen: 16
+			bertlv.NewValue(bertlv.ContextSpecific.Primitive(4), []byte{0x01, 0x00}), // hostId
+		),
+		bertlv.NewValue(bertlv.Application.Primitive(73), otpk),
+	)
+	signature, err := s.sign(secureChannel)
+	if err != nil {
+		return nil, err
+	}
+	secureChannel.Children = append(secureChannel.Children, signature)
+
+	metadata := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(1))
+	for chunk := range slices.Chunk(s.metadata(profile).Bytes(), 128) {
+		metadata.Children = append(metadata.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(8), chunk))
+	}
+	size := profile.Size
+	if size <= 0 {
+		size = 2048
+	}
+	elements := make([]byte, size)
+	_, _ = rand.Read(elements)
+	profileElements := bertlv.NewChildren(bertlv.ContextSpecific.Constructed(3))
+	for chunk := range slices.Chunk(elements, 1020) {
+		profileElements.Children = append(profileElements.Children, bertlv.NewValue(bertlv.ContextSpecific.Primitive(6), chunk))
+	}
+	return bertlv.NewChildren(bertlv.ContextSpecific.Constructed(54),
+		secureChannel,
+		bertlv.NewChildren(bertlv.ContextSpecific.Constructed(0), bertlv.NewValue(bertlv.ContextSpecific.Primitive(7), make([]byte, 32))),
+		metadata,
+		profileElements,
+	), nil
+}
+
+func (s *Server) cancelSession(w http.ResponseWriter, r *http.Request) {
+	var request sgp22.ES9CancelSessionRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	key := hex.EncodeToString(request.TransactionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[key]
+	if !ok {
+		respond(w, &sgp22.ES9CancelSessionResponse{Header: failed("8.10.1", "3.9")})
+		return
+	}
+	delete(s.transactions, key)
+	cancellation := Cancellation{Reason: sgp22.CancelSessionReasonUndefined}
+	if tx.profile != nil {
+		cancellation.MatchingID = tx.profile.MatchingID
+	}
+	if request.Response != nil {
+		if reason := request.Response.Select(
+			bertlv.ContextSpecific.Constructed(0),
+			bertlv.Universal.Constructed(16),
+			bertlv.ContextSpecific.Primitive(1),
+		); reason != nil && len(reason.Value) > 0 {
+			cancellation.Reason = sgp22.CancelSessionReason(reason.Value[0])
+		}
+	}
+	s.cancellations = append(s.cancellations, cancellation)
+	respond(w, &sgp22.ES9CancelSessionResponse{Header: success()})
+}
+
+func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
+	var request sgp22.ES9HandleNotificationRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	if request.PendingNotification == nil {
+		http.Error(w, "missing pendingNotification", http.StatusBadRequest)
+		return
+	}
+	// OtherSignedNotification holds the metadata directly, ProfileInstallationResult in its data.
+	tlv := request.PendingNotification.First(bertlv.ContextSpecific.Constructed(47))
+	if tlv == nil {
+		tlv = request.PendingNotification.Select(bertlv.ContextSpecific.Constructed(39), bertlv.ContextSpecific.Constructed(47))
+	}
+	var metadata sgp22.NotificationMetadata
+	if tlv == nil {
+		http.Error(w, "missing notificationMetadata", http.StatusBadRequest)
+		return
+	}
+	if err := metadata.UnmarshalBERTLV(tlv); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.notifications = append(s.notifications, metadata)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
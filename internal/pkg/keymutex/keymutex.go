@@ -1,36 +1,134 @@
 package keymutex
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"sync"
+	"time"
 )
 
 // KeyMutex provides mutex locking mechanism based on a key.
 // This is useful when you need to ensure that operations with the same key
 // cannot run concurrently, while operations with different keys can.
 type KeyMutex struct {
-	mu   sync.Mutex
-	maps sync.Map // map[any]*sync.Mutex
+	mu    sync.Mutex
+	locks map[any]*lock
+}
+
+type lock struct {
+	token  chan struct{} // holds a value while the key is locked
+	holder Holder
+}
+
+// Holder describes who currently holds the lock for a key.
+type Holder struct {
+	Key       any
+	Operation string
+	Since     time.Time
+}
+
+// BusyError is returned by LockContext when the context expires before the lock is acquired.
+type BusyError struct {
+	Holder Holder
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("busy: %s since %s", e.Holder.Operation, e.Holder.Since.Format(time.RFC3339))
+}
+
+type operationKey struct{}
+
+// WithOperation returns a context that names the operation locks are acquired for.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// OperationFrom returns the operation name stored in ctx, or "unknown".
+func OperationFrom(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok && operation != "" {
+		return operation
+	}
+	return "unknown"
 }
 
 // New creates a new KeyMutex instance.
 func New() *KeyMutex {
-	return &KeyMutex{}
+	return &KeyMutex{locks: make(map[any]*lock)}
+}
+
+func (km *KeyMutex) load(key any) *lock {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	l, ok := km.locks[key]
+	if !ok {
+		l = &lock{token: make(chan struct{}, 1)}
+		km.locks[key] = l
+	}
+	return l
 }
 
 // Lock acquires the lock for the given key.
 // If a lock for this key already exists, it will wait until it's released.
 // The lock should be released by calling Unlock with the same key.
 func (km *KeyMutex) Lock(key any) {
-	mu, _ := km.maps.LoadOrStore(key, &sync.Mutex{})
-	mu.(*sync.Mutex).Lock()
+	_ = km.LockContext(context.Background(), key)
+}
+
+// LockContext acquires the lock for the given key, giving up when ctx is done.
+// The holder is recorded with the operation from WithOperation.
+// If ctx hits its deadline, a *BusyError describing the current holder is returned;
+// if it is canceled, ctx.Err() is returned.
+func (km *KeyMutex) LockContext(ctx context.Context, key any) error {
+	l := km.load(key)
+	select {
+	case l.token <- struct{}{}:
+		km.mu.Lock()
+		l.holder = Holder{Key: key, Operation: OperationFrom(ctx), Since: time.Now()}
+		km.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		km.mu.Lock()
+		defer km.mu.Unlock()
+		return &BusyError{Holder: l.holder}
+	}
 }
 
 // Unlock releases the lock for the given key.
 // The key must be the same as the one used for Lock.
 func (km *KeyMutex) Unlock(key any) {
-	mu, ok := km.maps.Load(key)
+	km.mu.Lock()
+	l, ok := km.locks[key]
+	if ok {
+		l.holder = Holder{}
+	}
+	km.mu.Unlock()
 	if !ok {
 		panic("KeyMutex.Unlock: unlock of unlocked key")
 	}
-	mu.(*sync.Mutex).Unlock()
+	select {
+	case <-l.token:
+	default:
+		panic("KeyMutex.Unlock: unlock of unlocked key")
+	}
+}
+
+// Holders returns the current holder of every locked key, oldest first.
+func (km *KeyMutex) Holders() []Holder {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	holders := make([]Holder, 0, len(km.locks))
+	for _, l := range km.locks {
+		if !l.holder.Since.IsZero() {
+			holders = append(holders, l.holder)
+		}
+	}
+	slices.SortFunc(holders, func(a, b Holder) int {
+		return a.Since.Compare(b.Since)
+	})
+	return holders
 }
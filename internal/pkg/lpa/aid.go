@@ -0,0 +1,82 @@
+package lpa
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+// pinned remembers the ISD-R AID that last worked for a modem and SIM
+// (keyed by EquipmentIdentifier and ICCID), so later clients skip probing.
+var pinned sync.Map // map[string][]byte
+
+func pinKey(m *modem.Modem) string {
+	var iccid string
+	if m.Sim != nil {
+		iccid = m.Sim.Identifier
+	}
+	return m.EquipmentIdentifier + "/" + iccid
+}
+
+func pinAID(m *modem.Modem, aid []byte) {
+	pinned.Store(pinKey(m), bytes.Clone(aid))
+}
+
+func forgetAID(m *modem.Modem) {
+	pinned.Delete(pinKey(m))
+}
+
+// candidateAIDs returns the AIDs to try for m, in order.
+// A per-modem override is used exclusively; otherwise the pinned AID comes first,
+// followed by the built-in and configured AIDs. Invalid configured AIDs are skipped,
+// so that one typo does not stop every modem from working.
+func candidateAIDs(m *modem.Modem, cfg *config.Config) ([][]byte, error) {
+	if override := cfg.FindModem(m.EquipmentIdentifier).AID; override != "" {
+		aid, err := ParseAID(override)
+		if err != nil {
+			return nil, fmt.Errorf("modem %s: %w", m.EquipmentIdentifier, err)
+		}
+		return [][]byte{aid}, nil
+	}
+	candidates := make([][]byte, 0, len(AIDs)+len(cfg.LPA.AIDs)+1)
+	if aid, ok := pinned.Load(pinKey(m)); ok {
+		candidates = append(candidates, aid.([]byte))
+	}
+	for _, aid := range AIDs {
+		candidates = appendAID(candidates, aid)
+	}
+	for _, raw := range cfg.LPA.AIDs {
+		aid, err := ParseAID(raw)
+		if err != nil {
+			slog.Warn("skipping configured ISD-R AID", "error", err)
+			continue
+		}
+		candidates = appendAID(candidates, aid)
+	}
+	return candidates, nil
+}
+
+func appendAID(candidates [][]byte, aid []byte) [][]byte {
+	for _, candidate := range candidates {
+		if bytes.Equal(candidate, aid) {
+			return candidates
+		}
+	}
+	return append(candidates, aid)
+}
+
+// ParseAID decodes a hex AID as written in the config file.
+// ISO/IEC 7816-5 AIDs are 5 to 16 bytes long.
+func ParseAID(raw string) ([]byte, error) {
+	aid, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(raw), " ", ""))
+	if err != nil || len(aid) < 5 || len(aid) > 16 {
+		return nil, fmt.Errorf("invalid ISD-R AID %q", raw)
+	}
+	return aid, nil
+}
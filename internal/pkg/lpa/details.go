@@ -0,0 +1,242 @@
+package lpa
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/damonto/euicc-go/bertlv"
+	"github.com/damonto/euicc-go/bertlv/primitive"
+)
+
+// Details is the decoded content of EUICCInfo1 and EUICCInfo2.
+//
+// See https://aka.pw/sgp22/v2.5#page=189 (Section 5.7.8, ES10b.GetEUICCInfo)
+type Details struct {
+	EID                         string
+	Info1                       Info1
+	ProfileVersion              string
+	SVN                         string
+	FirmwareVersion             string
+	TS102241Version             string
+	GlobalPlatformVersion       string
+	PPVersion                   string
+	InstalledApplications       int64
+	FreeNonVolatileMemory       int64
+	FreeVolatileMemory          int64
+	UICCCapabilities            []string
+	RSPCapabilities             []string
+	Category                    string
+	ForbiddenProfilePolicyRules []string
+	SASAccreditationNumber      string
+	CertificationData           *CertificationData
+	CIPKIDsForVerification      []string
+	CIPKIDsForSigning           []string
+}
+
+type Info1 struct {
+	SVN                    string
+	CIPKIDsForVerification []string
+	CIPKIDsForSigning      []string
+}
+
+type CertificationData struct {
+	PlatformLabel    string
+	DiscoveryBaseURL string
+}
+
+var uiccCapabilities = []string{
+	"contactlessSupport",
+	"usimSupport",
+	"isimSupport",
+	"csimSupport",
+	"akaMilenage",
+	"akaCave",
+	"akaTuak128",
+	"akaTuak256",
+	"rfu1",
+	"rfu2",
+	"gbaAuthenUsim",
+	"gbaAuthenISim",
+	"mbmsAuthenUsim",
+	"eapClient",
+	"javacard",
+	"multos",
+	"multipleUsimSupport",
+	"multipleIsimSupport",
+	"multipleCsimSupport",
+	"berTlvFileSupport",
+	"dfLinkSupport",
+	"catTp",
+	"getIdentity",
+	"profile-a-x25519",
+	"profile-b-p256",
+	"suciCalculatorApi",
+	"dns-resolution",
+	"scp11ac",
+	"scp11c-authorization-mechanism",
+	"s16mode",
+	"eaka",
+	"iotminimal",
+}
+
+var rspCapabilities = []string{
+	"additionalProfile",
+	"crlSupport",
+	"rpmSupport",
+	"testProfileSupport",
+	"deviceInfoExtensibilitySupport",
+	"serviceSpecificDataSupport",
+}
+
+var pprIDs = []string{
+	"pprUpdateControl",
+	"ppr1",
+	"ppr2",
+}
+
+var euiccCategories = map[int64]string{
+	0: "other",
+	1: "basicEuicc",
+	2: "mediumEuicc",
+	3: "contactlessEuicc",
+}
+
+// Details reads EUICCInfo1 and EUICCInfo2 and decodes every field Sigmo understands.
+// Optional fields the eUICC does not report are left empty.
+func (l *LPA) Details() (*Details, error) {
+	var details Details
+	eid, err := l.EID()
+	if err != nil {
+		return nil, err
+	}
+	details.EID = hex.EncodeToString(eid)
+
+	info1, err := l.EUICCInfo1()
+	if err != nil {
+		return nil, fmt.Errorf("reading EUICCInfo1: %w", err)
+	}
+	details.Info1 = Info1{
+		SVN:                    versionOf(info1.First(bertlv.ContextSpecific.Primitive(2))),
+		CIPKIDsForVerification: keyIDsOf(info1.First(bertlv.ContextSpecific.Constructed(9))),
+		CIPKIDsForSigning:      keyIDsOf(info1.First(bertlv.ContextSpecific.Constructed(10))),
+	}
+
+	info2, err := l.EUICCInfo2()
+	if err != nil {
+		return nil, fmt.Errorf("reading EUICCInfo2: %w", err)
+	}
+	details.ProfileVersion = versionOf(info2.First(bertlv.ContextSpecific.Primitive(1)))
+	details.SVN = versionOf(info2.First(bertlv.ContextSpecific.Primitive(2)))
+	details.FirmwareVersion = versionOf(info2.First(bertlv.ContextSpecific.Primitive(3)))
+	details.TS102241Version = versionOf(info2.First(bertlv.ContextSpecific.Primitive(6)))
+	details.GlobalPlatformVersion = versionOf(info2.First(bertlv.ContextSpecific.Primitive(7)))
+	details.PPVersion = versionOf(info2.First(bertlv.Universal.Primitive(4)))
+	if err := details.decodeResource(info2.First(bertlv.ContextSpecific.Primitive(4))); err != nil {
+		return nil, fmt.Errorf("decoding extCardResource: %w", err)
+	}
+	if details.UICCCapabilities, err = flagsOf(info2.First(bertlv.ContextSpecific.Primitive(5)), uiccCapabilities); err != nil {
+		return nil, fmt.Errorf("decoding uiccCapability: %w", err)
+	}
+	if details.RSPCapabilities, err = flagsOf(info2.First(bertlv.ContextSpecific.Primitive(8)), rspCapabilities); err != nil {
+		return nil, fmt.Errorf("decoding rspCapability: %w", err)
+	}
+	if details.ForbiddenProfilePolicyRules, err = flagsOf(info2.First(bertlv.ContextSpecific.Primitive(25)), pprIDs); err != nil {
+		return nil, fmt.Errorf("decoding forbiddenProfilePolicyRules: %w", err)
+	}
+	if category := info2.First(bertlv.ContextSpecific.Primitive(11)); category != nil {
+		var value int64
+		if err := category.UnmarshalValue(primitive.UnmarshalInt(&value)); err != nil {
+			return nil, fmt.Errorf("decoding euiccCategory: %w", err)
+		}
+		details.Category = euiccCategories[value]
+	}
+	if sas := info2.First(bertlv.Universal.Primitive(12)); sas != nil {
+		details.SASAccreditationNumber = string(sas.Value)
+	}
+	if certification := info2.First(bertlv.ContextSpecific.Constructed(12)); certification != nil {
+		labels := certification.Find(bertlv.Universal.Primitive(12))
+		details.CertificationData = new(CertificationData)
+		if len(labels) > 0 {
+			details.CertificationData.PlatformLabel = string(labels[0].Value)
+		}
+		if len(labels) > 1 {
+			details.CertificationData.DiscoveryBaseURL = string(labels[1].Value)
+		}
+	}
+	details.CIPKIDsForVerification = keyIDsOf(info2.First(bertlv.ContextSpecific.Constructed(9)))
+	details.CIPKIDsForSigning = keyIDsOf(info2.First(bertlv.ContextSpecific.Constructed(10)))
+	return &details, nil
+}
+
+// decodeResource decodes extCardResource, an OCTET STRING wrapping
+// numberOfInstalledApplication [1], freeNonVolatileMemory [2] and freeVolatileMemory [3].
+func (d *Details) decodeResource(resource *bertlv.TLV) error {
+	if resource == nil {
+		return nil
+	}
+	data, err := resource.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data[0] = 0x30
+	var children bertlv.TLV
+	if err := children.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	for tag, value := range map[uint64]*int64{
+		1: &d.InstalledApplications,
+		2: &d.FreeNonVolatileMemory,
+		3: &d.FreeVolatileMemory,
+	} {
+		if child := children.First(bertlv.ContextSpecific.Primitive(tag)); child != nil {
+			if err := child.UnmarshalValue(primitive.UnmarshalInt(value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func versionOf(tlv *bertlv.TLV) string {
+	if tlv == nil || len(tlv.Value) != 3 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", tlv.Value[0], tlv.Value[1], tlv.Value[2])
+}
+
+func keyIDsOf(tlv *bertlv.TLV) []string {
+	if tlv == nil {
+		return nil
+	}
+	keyIDs := make([]string, 0, len(tlv.Children))
+	for _, child := range tlv.Children {
+		keyIDs = append(keyIDs, hex.EncodeToString(child.Value))
+	}
+	return keyIDs
+}
+
+func flagsOf(tlv *bertlv.TLV, names []string) ([]string, error) {
+	if tlv == nil {
+		return nil, nil
+	}
+	if len(tlv.Value) == 0 {
+		return nil, errors.New("empty bit string")
+	}
+	var bits []bool
+	if err := tlv.UnmarshalValue(primitive.UnmarshalBitString(&bits)); err != nil {
+		return nil, err
+	}
+	flags := make([]string, 0, len(bits))
+	for index, set := range bits {
+		if !set {
+			continue
+		}
+		if index < len(names) {
+			flags = append(flags, names[index])
+			continue
+		}
+		flags = append(flags, fmt.Sprintf("bit%d", index))
+	}
+	return flags, nil
+}
@@ -3,15 +3,21 @@ package lpa
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/damonto/euicc-go/apdu"
 	"github.com/damonto/euicc-go/bertlv"
 	"github.com/damonto/euicc-go/bertlv/primitive"
+	"github.com/damonto/euicc-go/driver"
 	"github.com/damonto/euicc-go/driver/at"
 	"github.com/damonto/euicc-go/driver/mbim"
 	"github.com/damonto/euicc-go/driver/qmi"
@@ -20,6 +26,7 @@ import (
 	"github.com/damonto/sigmo/internal/pkg/config"
 	"github.com/damonto/sigmo/internal/pkg/euicc"
 	"github.com/damonto/sigmo/internal/pkg/keymutex"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
 	"github.com/damonto/sigmo/internal/pkg/modem"
 )
 
@@ -30,7 +37,8 @@ var gmu = keymutex.New()
 
 type LPA struct {
 	*lpa.Client
-	key string // EquipmentIdentifier used for global locking
+	key     string // EquipmentIdentifier used for global locking
+	session *session
 }
 
 type Info struct {
@@ -42,6 +50,10 @@ type Info struct {
 
 var ErrNoSupportedAID = errors.New("no supported ISD-R AID found or it's not an eUICC")
 
+// ErrDownloadCanceled is returned by Download when the user rejected the profile or canceled the download.
+var ErrDownloadCanceled = errors.New("download canceled")
+
+// AIDs are the built-in ISD-R AIDs probed when a modem has no pinned or configured AID.
 var AIDs = [][]byte{
 	lpa.GSMAISDRApplicationAID,
 	{0xA0, 0x00, 0x00, 0x05, 0x59, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x89, 0x00, 0x05, 0x05, 0x00}, // 5ber Ultra
@@ -51,40 +63,135 @@ var AIDs = [][]byte{
 	{0xA0, 0x00, 0x00, 0x06, 0x28, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x89, 0x00, 0x00, 0x01, 0x00}, // GlocalMe
 }
 
-func New(m *modem.Modem, cfg *config.Config) (*LPA, error) {
-	gmu.Lock(m.EquipmentIdentifier)
-	instance := &LPA{key: m.EquipmentIdentifier}
-	ch, err := instance.createChannel(m)
-	if err != nil {
-		gmu.Unlock(m.EquipmentIdentifier)
+// lockTimeout bounds how long New waits for another operation on the same eUICC.
+const lockTimeout = 15 * time.Second
+
+var lockWait = metrics.NewHistogram("sigmo_lpa_lock_wait_seconds", "Time spent waiting for the eUICC lock.", metrics.DefaultBuckets, "modem")
+
+// New returns an LPA client for m, reusing the modem's open session when there is one.
+// The modem stays locked until Close is called. If another operation holds the eUICC
+// for longer than lockTimeout, New returns an error wrapping *keymutex.BusyError.
+func New(ctx context.Context, m *modem.Modem, cfg *config.Config) (*LPA, error) {
+	if err := lock(ctx, m.EquipmentIdentifier); err != nil {
 		return nil, err
 	}
-	opts := &lpa.Options{
-		Channel:              ch,
-		AdminProtocolVersion: "2.2.0",
-		MSS:                  cfg.FindModem(m.EquipmentIdentifier).MSS,
+	if cfg.FindModem(m.EquipmentIdentifier).APDUTrace {
+		traces.begin(m.EquipmentIdentifier, keymutex.OperationFrom(ctx))
 	}
-	if err := instance.tryCreateClient(opts); err != nil {
+	s, err := sessions.acquire(ctx, m, cfg)
+	if err != nil {
+		traces.finish(m.EquipmentIdentifier)
 		gmu.Unlock(m.EquipmentIdentifier)
 		return nil, err
 	}
-	return instance, nil
+	return &LPA{Client: s.client, key: m.EquipmentIdentifier, session: s}, nil
 }
 
-func (l *LPA) tryCreateClient(opts *lpa.Options) error {
-	var err error
-	for _, opts.AID = range AIDs {
-		l.Client, err = lpa.New(opts)
+// Exclusive runs fn with the eUICC of a modem locked and its session closed, for
+// anything that talks to the SIM through the AT port instead of an LPA client.
+// Like New, it returns an error wrapping *keymutex.BusyError if another operation
+// holds the eUICC for longer than lockTimeout.
+func Exclusive(ctx context.Context, equipmentIdentifier string, fn func() error) error {
+	if err := lock(ctx, equipmentIdentifier); err != nil {
+		return err
+	}
+	defer gmu.Unlock(equipmentIdentifier)
+	Invalidate(equipmentIdentifier)
+	return fn()
+}
+
+// lock takes the eUICC lock of a modem, waiting at most lockTimeout.
+func lock(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	start := time.Now()
+	err := gmu.LockContext(ctx, key)
+	lockWait.Observe(time.Since(start).Seconds(), key)
+	var busy *keymutex.BusyError
+	if errors.As(err, &busy) {
+		return fmt.Errorf("eUICC %w", err)
+	}
+	return err
+}
+
+// Holders returns the operations currently holding an eUICC, keyed by EquipmentIdentifier.
+func Holders() []keymutex.Holder {
+	return gmu.Holders()
+}
+
+// Busy reports whether an operation holds the eUICC of a modem, e.g. to switch
+// profiles, which drops the registration for a while.
+func Busy(equipmentIdentifier string) bool {
+	return slices.ContainsFunc(gmu.Holders(), func(holder keymutex.Holder) bool {
+		return holder.Key == equipmentIdentifier
+	})
+}
+
+func tryCreateClient(opts *lpa.Options, candidates [][]byte) (*lpa.Client, error) {
+	for _, opts.AID = range candidates {
+		client, err := lpa.New(opts)
 		if err == nil {
 			slog.Info("LPA client created", "AID", fmt.Sprintf("%X", opts.AID))
-			return nil
+			return client, nil
 		}
 		slog.Warn("failed to create LPA client", "AID", fmt.Sprintf("%X", opts.AID), "error", err)
 	}
-	return ErrNoSupportedAID
+	return nil, ErrNoSupportedAID
 }
 
-func (l *LPA) createChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
+type channelKey struct{}
+
+// WithChannel returns a context that makes New talk to channel instead of opening
+// the modem's driver, e.g. a fake card in tests. An open session of the modem is
+// only reused by contexts carrying the same channel.
+func WithChannel(ctx context.Context, channel apdu.SmartCardChannel) context.Context {
+	return context.WithValue(ctx, channelKey{}, channel)
+}
+
+// channelFrom returns the channel stored in ctx by WithChannel, or nil.
+func channelFrom(ctx context.Context) apdu.SmartCardChannel {
+	channel, _ := ctx.Value(channelKey{}).(apdu.SmartCardChannel)
+	return channel
+}
+
+type rootCAsKey struct{}
+
+// WithRootCAs returns a context that makes New trust only pool for HTTPS instead of
+// the GSMA CI bundle, e.g. the test CI of a fake SM-DP+. An open session of the modem
+// is only reused by contexts carrying the same pool.
+func WithRootCAs(ctx context.Context, pool *x509.CertPool) context.Context {
+	return context.WithValue(ctx, rootCAsKey{}, pool)
+}
+
+// rootCAsFrom returns the pool stored in ctx by WithRootCAs, or nil.
+func rootCAsFrom(ctx context.Context) *x509.CertPool {
+	pool, _ := ctx.Value(rootCAsKey{}).(*x509.CertPool)
+	return pool
+}
+
+// newTransport returns the HTTP transport for new clients, or nil for the library default.
+func newTransport(ctx context.Context) *driver.LoggingRoundTripper {
+	pool := rootCAsFrom(ctx)
+	if pool == nil {
+		return nil
+	}
+	return driver.NewLoggingRoundTripper(pool, slog.Default())
+}
+
+// createChannel opens the channel for m, wrapped so it can be traced. It is the
+// channel from ctx when there is one and the modem's driver otherwise.
+func createChannel(ctx context.Context, m *modem.Modem) (apdu.SmartCardChannel, error) {
+	ch := channelFrom(ctx)
+	if ch == nil {
+		var err error
+		if ch, err = createDriverChannel(m); err != nil {
+			return nil, err
+		}
+	}
+	return &tracingChannel{SmartCardChannel: ch, key: m.EquipmentIdentifier}, nil
+}
+
+func createDriverChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
 	slot := uint8(1)
 	if m.PrimarySimSlot > 0 {
 		slot = uint8(m.PrimarySimSlot)
@@ -97,11 +204,11 @@ func (l *LPA) createChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
 		slog.Info("using MBIM driver", "port", m.PrimaryPort, "slot", slot)
 		return mbim.New(m.PrimaryPort, slot)
 	default:
-		return l.createATChannel(m)
+		return createATChannel(m)
 	}
 }
 
-func (l *LPA) createATChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
+func createATChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
 	port, err := m.Port(modem.ModemPortTypeAt)
 	if err != nil {
 		return nil, err
@@ -110,9 +217,25 @@ func (l *LPA) createATChannel(m *modem.Modem) (apdu.SmartCardChannel, error) {
 	return at.New(port.Device)
 }
 
+// Close hands the session back for reuse and unlocks the modem.
+// The underlying channel is only closed once the session is idle, invalidated or broken.
 func (l *LPA) Close() error {
-	gmu.Unlock(l.key)
-	return l.Client.Close()
+	defer gmu.Unlock(l.key)
+	defer traces.finish(l.key)
+	return sessions.release(l.key, l.session)
+}
+
+// EID returns the EID of the eUICC, read once per session.
+func (l *LPA) EID() ([]byte, error) {
+	if l.session.eid != nil {
+		return l.session.eid, nil
+	}
+	eid, err := l.Client.EID()
+	if err != nil {
+		return nil, err
+	}
+	l.session.eid = eid
+	return eid, nil
 }
 
 func (l *LPA) Info() (*Info, error) {
@@ -148,26 +271,63 @@ func (l *LPA) Info() (*Info, error) {
 }
 
 func (l *LPA) Delete(id sgp22.ICCID) error {
-	currentNotifications, err := l.ListNotification()
+	lastSeq, err := l.lastSequenceNumber()
 	if err != nil {
 		return err
 	}
-	var lastSeq sgp22.SequenceNumber
-	for _, n := range currentNotifications {
-		lastSeq = max(n.SequenceNumber, lastSeq)
+	if err := l.DeleteProfile(id); err != nil {
+		return err
 	}
+	return l.sendDeleteNotifications(lastSeq, func(n *sgp22.NotificationMetadata) bool {
+		return bytes.Equal(n.ICCID, id)
+	})
+}
 
-	if err := l.DeleteProfile(id); err != nil {
+// MemoryResetOptions selects what ES10c.eUICCMemoryReset removes.
+type MemoryResetOptions struct {
+	DeleteOperationalProfiles bool
+	DeleteTestProfiles        bool
+	ResetDefaultSMDPAddress   bool
+}
+
+// ResetMemory performs ES10c.eUICCMemoryReset and sends the delete notifications it generates.
+func (l *LPA) ResetMemory(opts MemoryResetOptions) error {
+	lastSeq, err := l.lastSequenceNumber()
+	if err != nil {
 		return err
 	}
+	if _, err := sgp22.InvokeAPDU(l.APDU, &sgp22.EuiccMemoryResetRequest{
+		DeleteOperationalProfiles:     opts.DeleteOperationalProfiles,
+		DeleteFieldLoadedTestProfiles: opts.DeleteTestProfiles,
+		ResetDefaultSMDPAddress:       opts.ResetDefaultSMDPAddress,
+	}); err != nil {
+		return err
+	}
+	return l.sendDeleteNotifications(lastSeq, func(*sgp22.NotificationMetadata) bool {
+		return true
+	})
+}
+
+func (l *LPA) lastSequenceNumber() (sgp22.SequenceNumber, error) {
+	notifications, err := l.ListNotification()
+	if err != nil {
+		return 0, err
+	}
+	var lastSeq sgp22.SequenceNumber
+	for _, n := range notifications {
+		lastSeq = max(n.SequenceNumber, lastSeq)
+	}
+	return lastSeq, nil
+}
 
+func (l *LPA) sendDeleteNotifications(lastSeq sgp22.SequenceNumber, match func(*sgp22.NotificationMetadata) bool) error {
 	deletionNotifications, err := l.ListNotification(sgp22.NotificationEventDelete)
 	if err != nil {
 		return err
 	}
 	var errs error
 	for _, n := range deletionNotifications {
-		if n.SequenceNumber > lastSeq && bytes.Equal(n.ICCID, id) {
+		if n.SequenceNumber > lastSeq && match(n) {
 			slog.Info("sending deletion notification", "sequence", n.SequenceNumber)
 			if err := l.SendNotification(n.SequenceNumber, false); err != nil {
 				errs = errors.Join(errs, err)
@@ -196,39 +356,115 @@ func (l *LPA) SendNotification(searchCriteria any, delete bool) error {
 	return errs
 }
 
-func (l *LPA) Download(ctx context.Context, activationCode *lpa.ActivationCode, opts *lpa.DownloadOptions) error {
+// Download downloads and installs a profile and returns its ICCID.
+func (l *LPA) Download(ctx context.Context, activationCode *lpa.ActivationCode, opts *lpa.DownloadOptions) (sgp22.ICCID, error) {
 	slog.Info("downloading profile", "activationCode", activationCode)
 	result, err := l.DownloadProfile(ctx, activationCode, opts)
+	// A download canceled while waiting for the confirmation code is aborted with
+	// the SM-DP+ as if no code had been entered.
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		slog.Info("download canceled", "error", err)
+		return nil, ErrDownloadCanceled
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// The session was canceled with the SM-DP+ before anything was installed.
+	if result == nil {
+		return nil, ErrDownloadCanceled
+	}
+	if result.Notification == nil {
+		return nil, nil
 	}
-	if result != nil && result.Notification != nil && result.Notification.SequenceNumber > 0 {
+	if result.Notification.SequenceNumber > 0 {
 		slog.Info("sending download notification", "sequence", result.Notification.SequenceNumber)
 		if err := l.SendNotification(result.Notification.SequenceNumber, false); err != nil {
-			return err
+			return result.Notification.ICCID, err
 		}
 	}
-	return nil
+	return result.Notification.ICCID, nil
+}
+
+// DiscoveryResult holds the events returned by a single SM-DS server.
+type DiscoveryResult struct {
+	Address string
+	Entries []*sgp22.EventEntry
+	Err     error
+}
+
+// Discover queries every SM-DS address concurrently and reports each server's outcome
+// separately, so one unreachable server does not hide the events of the others.
+func (l *LPA) Discover(imei sgp22.IMEI, addresses []string) []DiscoveryResult {
+	results := make([]DiscoveryResult, len(addresses))
+	var (
+		card sync.Mutex
+		wg   sync.WaitGroup
+	)
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			results[i] = DiscoveryResult{Address: address}
+			results[i].Entries, results[i].Err = l.discover(&card, address, imei)
+		}(i, address)
+	}
+	wg.Wait()
+	return results
 }
 
-func (l *LPA) Discover(imei sgp22.IMEI) ([]*sgp22.EventEntry, error) {
-	var entries []*sgp22.EventEntry
-	addresses := []url.URL{
-		{Scheme: "https", Host: "lpa.ds.gsma.com"},
-		{Scheme: "https", Host: "lpa.live.esimdiscovery.com"},
+// discover runs ES11.AuthenticateClient against a single SM-DS.
+// The eUICC only keeps one server challenge at a time, so everything up to
+// AuthenticateServer is serialized through card; the final SM-DS request runs in parallel.
+func (l *LPA) discover(card *sync.Mutex, address string, imei sgp22.IMEI) ([]*sgp22.EventEntry, error) {
+	parsed, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
 	}
-	for _, address := range addresses {
-		slog.Info("discovering profiles", "address", address.Host)
-		discovered, err := l.Discovery(&address, imei)
-		if err != nil {
-			return nil, err
-		}
-		for _, entry := range discovered {
-			if entry == nil {
-				continue
-			}
-			entries = append(entries, entry)
+	smds := &url.URL{Scheme: "https", Host: parsed.Host}
+	slog.Info("discovering profiles", "address", smds.Host)
+	card.Lock()
+	response, err := l.InitiateAuthentication(smds)
+	if err != nil {
+		card.Unlock()
+		return nil, err
+	}
+	cardRequest := response.CardRequest()
+	cardRequest.IMEI = imei
+	request, err := sgp22.InvokeAPDU(l.APDU, cardRequest)
+	card.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	clientResponse, err := sgp22.InvokeHTTP(l.HTTP, smds, &sgp22.ES11AuthenticateClientRequest{
+		ES9AuthenticateClientRequest: request,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*sgp22.EventEntry, 0, len(clientResponse.EventEntries))
+	for _, entry := range clientResponse.EventEntries {
+		if entry == nil {
+			continue
 		}
+		entries = append(entries, entry)
 	}
 	return entries, nil
 }
+
+// ParseAddress parses an SM-DP+ or SM-DS address given as a host or a URL. A
+// host gets the https scheme. Only the host of the result is used to reach the
+// server; the rest is kept for callers that want to check it.
+func ParseAddress(raw string) (*url.URL, error) {
+	address := strings.TrimSpace(raw)
+	if address == "" {
+		return nil, errors.New("address is required")
+	}
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	parsed, err := url.Parse(address)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid address %q", raw)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,268 @@
+package lpa
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damonto/euicc-go/apdu"
+	"github.com/damonto/euicc-go/lpa"
+	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/modem"
+)
+
+// sessionIdleTimeout is how long an unused session keeps its channel open.
+const sessionIdleTimeout = 2 * time.Minute
+
+// session is an open channel and LPA client for a single modem.
+// It is only used by the goroutine holding the modem's gmu lock.
+type session struct {
+	client   *lpa.Client
+	channel  *trackedChannel
+	identity string
+	eid      []byte
+	inUse    bool
+	idle     *time.Timer
+	// source and rootCAs are what the session was opened with by WithChannel
+	// and WithRootCAs, if anything.
+	source  apdu.SmartCardChannel
+	rootCAs *x509.CertPool
+}
+
+func (s *session) close() error {
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	slog.Debug("closing LPA session", "identity", s.identity)
+	return s.client.Close()
+}
+
+// trackedChannel remembers transport failures so a broken channel is never reused.
+type trackedChannel struct {
+	apdu.SmartCardChannel
+	broken atomic.Bool
+}
+
+func (c *trackedChannel) Transmit(command []byte) ([]byte, error) {
+	response, err := c.SmartCardChannel.Transmit(command)
+	if err != nil {
+		c.broken.Store(true)
+	}
+	return response, err
+}
+
+// registry keeps one session per modem (keyed by EquipmentIdentifier) and
+// remembers which modem and SIM combinations have an eUICC.
+type registry struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	support  map[string]bool // keyed by identity
+}
+
+var sessions = &registry{
+	sessions: make(map[string]*session),
+	support:  make(map[string]bool),
+}
+
+// identityOf changes whenever the channel of a modem has to be reopened:
+// a different port, another SIM slot or a new card.
+func identityOf(m *modem.Modem) string {
+	var iccid string
+	if m.Sim != nil {
+		iccid = m.Sim.Identifier
+	}
+	return fmt.Sprintf("%s/%s/%d/%s", m.EquipmentIdentifier, m.PrimaryPort, m.PrimarySimSlot, iccid)
+}
+
+func (r *registry) acquire(ctx context.Context, m *modem.Modem, cfg *config.Config) (*session, error) {
+	key, identity := m.EquipmentIdentifier, identityOf(m)
+	r.mu.Lock()
+	if s := r.sessions[key]; s != nil {
+		if s.identity == identity && s.source == channelFrom(ctx) && s.rootCAs == rootCAsFrom(ctx) && !s.channel.broken.Load() {
+			if s.idle != nil {
+				s.idle.Stop()
+			}
+			s.inUse = true
+			r.mu.Unlock()
+			return s, nil
+		}
+		delete(r.sessions, key)
+		r.mu.Unlock()
+		if err := s.close(); err != nil {
+			slog.Warn("failed to close stale LPA session", "modem", key, "error", err)
+		}
+	} else {
+		r.mu.Unlock()
+	}
+
+	s, err := open(ctx, m, cfg)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, ErrNoSupportedAID) {
+			r.support[identity] = false
+		}
+		return nil, err
+	}
+	s.inUse = true
+	r.sessions[key] = s
+	r.support[identity] = true
+	return s, nil
+}
+
+func (r *registry) release(key string, s *session) error {
+	r.mu.Lock()
+	s.inUse = false
+	if r.sessions[key] != s || s.channel.broken.Load() {
+		if r.sessions[key] == s {
+			delete(r.sessions, key)
+		}
+		r.mu.Unlock()
+		return s.close()
+	}
+	s.idle = time.AfterFunc(sessionIdleTimeout, func() {
+		r.expire(key, s)
+	})
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *registry) expire(key string, s *session) {
+	r.mu.Lock()
+	if r.sessions[key] != s || s.inUse {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.sessions, key)
+	r.mu.Unlock()
+	if err := s.close(); err != nil {
+		slog.Warn("failed to close idle LPA session", "modem", key, "error", err)
+	}
+}
+
+func (r *registry) invalidate(key string) {
+	r.mu.Lock()
+	for identity := range r.support {
+		if strings.HasPrefix(identity, key+"/") {
+			delete(r.support, identity)
+		}
+	}
+	s := r.sessions[key]
+	delete(r.sessions, key)
+	// A session in use is closed by release once it notices it was dropped.
+	if s == nil || s.inUse {
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+	if err := s.close(); err != nil {
+		slog.Warn("failed to close LPA session", "modem", key, "error", err)
+	}
+}
+
+func (r *registry) closeAll() {
+	r.mu.Lock()
+	idle := make([]*session, 0, len(r.sessions))
+	for key, s := range r.sessions {
+		if !s.inUse {
+			idle = append(idle, s)
+		}
+		delete(r.sessions, key)
+	}
+	r.mu.Unlock()
+	for _, s := range idle {
+		if err := s.close(); err != nil {
+			slog.Warn("failed to close LPA session", "error", err)
+		}
+	}
+}
+
+func open(ctx context.Context, m *modem.Modem, cfg *config.Config) (*session, error) {
+	candidates, err := candidateAIDs(m, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := createChannel(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	channel := &trackedChannel{SmartCardChannel: ch}
+	opts := &lpa.Options{
+		Channel:              channel,
+		AdminProtocolVersion: "2.2.0",
+		MSS:                  cfg.FindModem(m.EquipmentIdentifier).MSS,
+	}
+	client, err := tryCreateClient(opts, candidates)
+	if err != nil {
+		forgetAID(m)
+		if derr := ch.Disconnect(); derr != nil {
+			slog.Debug("failed to disconnect channel", "modem", m.EquipmentIdentifier, "error", derr)
+		}
+		return nil, err
+	}
+	pinAID(m, opts.AID)
+	if transport := newTransport(ctx); transport != nil {
+		client.HTTP.Client.Transport = transport
+	}
+	// Probing other AIDs may have failed on the wire; only failures from now on matter.
+	channel.broken.Store(false)
+	return &session{
+		client:   client,
+		channel:  channel,
+		identity: identityOf(m),
+		source:   channelFrom(ctx),
+		rootCAs:  rootCAsFrom(ctx),
+	}, nil
+}
+
+// SupportsEsim reports whether m has an eUICC, probing the card only once per modem and SIM.
+func SupportsEsim(ctx context.Context, m *modem.Modem, cfg *config.Config) (bool, error) {
+	sessions.mu.Lock()
+	supported, ok := sessions.support[identityOf(m)]
+	sessions.mu.Unlock()
+	if ok {
+		return supported, nil
+	}
+	client, err := New(ctx, m, cfg)
+	if err != nil {
+		if errors.Is(err, ErrNoSupportedAID) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, client.Close()
+}
+
+// Invalidate drops the session and cached eSIM support of a modem.
+// Call it before anything that resets the card or the modem, such as enabling a profile.
+func Invalidate(equipmentIdentifier string) {
+	sessions.invalidate(equipmentIdentifier)
+}
+
+// Watch invalidates the sessions of modems that ModemManager removes or re-adds,
+// or whose SIM changes. The returned function stops watching and closes every idle session.
+func Watch(manager *modem.Manager) (func(), error) {
+	unsubscribe, err := manager.Subscribe(func(event modem.ModemEvent) error {
+		if event.Modem == nil {
+			return nil
+		}
+		switch event.Type {
+		case modem.ModemEventAdded, modem.ModemEventRemoved, modem.ModemEventSimChanged, modem.ModemEventPrimarySimSlotChanged:
+			Invalidate(event.Modem.EquipmentIdentifier)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unsubscribe()
+		sessions.closeAll()
+	}, nil
+}
@@ -0,0 +1,241 @@
+package lpa
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/damonto/euicc-go/apdu"
+)
+
+// maxTraces is how many finished traces are kept per modem.
+const maxTraces = 10
+
+// Trace is the APDU exchange of a single LPA operation on a modem.
+type Trace struct {
+	ID        string
+	Modem     string
+	Operation string
+	StartedAt time.Time
+	EndedAt   time.Time
+	Exchanges []Exchange
+}
+
+// Exchange is one call on the smart card channel.
+type Exchange struct {
+	Time     time.Time
+	Duration time.Duration
+	Kind     string
+	Command  string
+	Response string
+	Error    string
+}
+
+// sensitiveTags start ES10 messages that carry secrets: the matching ID in
+// AuthenticateServer, the confirmation code hash in PrepareDownload and
+// every segment of a bound profile package.
+var sensitiveTags = [][]byte{
+	{0xBF, 0x38}, // AuthenticateServerRequest
+	{0xBF, 0x21}, // PrepareDownloadRequest
+	{0xBF, 0x23}, // InitialiseSecureChannelRequest
+	{0xA0},       // firstSequenceOf87 (ConfigureISDP)
+	{0xA1},       // sequenceOf88 (StoreMetadata)
+	{0xA2},       // secondSequenceOf87 (ReplaceSessionKeys)
+	{0xA3},       // sequenceOf86 (profile elements)
+	{0x86},
+	{0x87},
+	{0x88},
+}
+
+// sensitiveResponseTags start ES10 responses that carry secrets: the matching ID
+// echoed in the signed data of AuthenticateServer and the one-time key of PrepareDownload.
+var sensitiveResponseTags = [][]byte{
+	{0xBF, 0x38}, // AuthenticateServerResponse
+	{0xBF, 0x21}, // PrepareDownloadResponse
+}
+
+type tracer struct {
+	mu       sync.Mutex
+	nextID   uint64
+	current  map[string]*Trace
+	finished map[string][]*Trace
+	// redacting is set while the STORE DATA blocks of a sensitive message are sent
+	// or a sensitive response is read, including its GET RESPONSE continuations.
+	redacting map[string]bool
+}
+
+var traces = &tracer{
+	current:   make(map[string]*Trace),
+	finished:  make(map[string][]*Trace),
+	redacting: make(map[string]bool),
+}
+
+func (t *tracer) begin(key, operation string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	t.current[key] = &Trace{
+		ID:        strconv.FormatUint(t.nextID, 10),
+		Modem:     key,
+		Operation: operation,
+		StartedAt: time.Now(),
+	}
+}
+
+func (t *tracer) finish(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace, ok := t.current[key]
+	if !ok {
+		return
+	}
+	delete(t.current, key)
+	delete(t.redacting, key)
+	trace.EndedAt = time.Now()
+	finished := append(t.finished[key], trace)
+	if len(finished) > maxTraces {
+		finished = slices.Delete(finished, 0, len(finished)-maxTraces)
+	}
+	t.finished[key] = finished
+}
+
+func (t *tracer) record(key string, start time.Time, kind string, command, response []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace, ok := t.current[key]
+	if !ok {
+		return
+	}
+	exchange := Exchange{
+		Time:     start,
+		Duration: time.Since(start),
+		Kind:     kind,
+		Command:  hex.EncodeToString(command),
+		Response: hex.EncodeToString(response),
+	}
+	if kind == "transmit" {
+		exchange.Command = t.redactCommand(key, command)
+		exchange.Response = t.redactResponse(key, response)
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	trace.Exchanges = append(trace.Exchanges, exchange)
+}
+
+// redactCommand hides the data of STORE DATA commands belonging to a sensitive message,
+// keeping the header and length so the exchange stays readable.
+func (t *tracer) redactCommand(key string, command []byte) string {
+	if len(command) < 5 {
+		return hex.EncodeToString(command)
+	}
+	switch command[1] {
+	case 0xE2: // STORE DATA
+	case 0xC0: // GET RESPONSE continues the response to the previous command.
+		return hex.EncodeToString(command)
+	default:
+		t.redacting[key] = false
+		return hex.EncodeToString(command)
+	}
+	data := command[5:]
+	if command[3] == 0 {
+		t.redacting[key] = hasTag(data, sensitiveTags)
+	}
+	if !t.redacting[key] || len(data) == 0 {
+		return hex.EncodeToString(command)
+	}
+	return fmt.Sprintf("%s<redacted %d bytes>", hex.EncodeToString(command[:5]), len(data))
+}
+
+// redactResponse hides the data of responses to a sensitive message and of sensitive
+// responses, keeping the status words.
+func (t *tracer) redactResponse(key string, response []byte) string {
+	if len(response) < 2 {
+		return hex.EncodeToString(response)
+	}
+	data, sw := response[:len(response)-2], response[len(response)-2:]
+	if hasTag(data, sensitiveResponseTags) {
+		t.redacting[key] = true
+	}
+	if !t.redacting[key] || len(data) == 0 {
+		return hex.EncodeToString(response)
+	}
+	return fmt.Sprintf("<redacted %d bytes>%s", len(data), hex.EncodeToString(sw))
+}
+
+func hasTag(data []byte, tags [][]byte) bool {
+	return slices.ContainsFunc(tags, func(tag []byte) bool {
+		return bytes.HasPrefix(data, tag)
+	})
+}
+
+// Traces returns the finished traces of a modem, newest first, without their exchanges.
+func Traces(equipmentIdentifier string) []Trace {
+	traces.mu.Lock()
+	defer traces.mu.Unlock()
+	finished := traces.finished[equipmentIdentifier]
+	summaries := make([]Trace, 0, len(finished))
+	for _, trace := range slices.Backward(finished) {
+		summary := *trace
+		summary.Exchanges = nil
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// FindTrace returns a finished trace of a modem by its ID.
+func FindTrace(equipmentIdentifier, id string) (*Trace, bool) {
+	traces.mu.Lock()
+	defer traces.mu.Unlock()
+	for _, trace := range traces.finished[equipmentIdentifier] {
+		if trace.ID == id {
+			return trace, true
+		}
+	}
+	return nil, false
+}
+
+// tracingChannel records every call into the modem's current trace, if one was started.
+type tracingChannel struct {
+	apdu.SmartCardChannel
+	key string
+}
+
+func (c *tracingChannel) Connect() error {
+	start := time.Now()
+	err := c.SmartCardChannel.Connect()
+	traces.record(c.key, start, "connect", nil, nil, err)
+	return err
+}
+
+func (c *tracingChannel) Disconnect() error {
+	start := time.Now()
+	err := c.SmartCardChannel.Disconnect()
+	traces.record(c.key, start, "disconnect", nil, nil, err)
+	return err
+}
+
+func (c *tracingChannel) OpenLogicalChannel(AID []byte) (byte, error) {
+	start := time.Now()
+	channel, err := c.SmartCardChannel.OpenLogicalChannel(AID)
+	traces.record(c.key, start, "open", AID, []byte{channel}, err)
+	return channel, err
+}
+
+func (c *tracingChannel) Transmit(command []byte) ([]byte, error) {
+	start := time.Now()
+	response, err := c.SmartCardChannel.Transmit(command)
+	traces.record(c.key, start, "transmit", command, response, err)
+	return response, err
+}
+
+func (c *tracingChannel) CloseLogicalChannel(channel byte) error {
+	start := time.Now()
+	err := c.SmartCardChannel.CloseLogicalChannel(channel)
+	traces.record(c.key, start, "close", []byte{channel}, nil, err)
+	return err
+}
@@ -0,0 +1,79 @@
+package lpa
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestTraceRedaction(t *testing.T) {
+	type exchange struct {
+		command, response         string
+		wantCommand, wantResponse string
+	}
+	tests := []struct {
+		name      string
+		exchanges []exchange
+	}{
+		{
+			name: "ordinary message",
+			exchanges: []exchange{
+				{"81e2910003bf2d00", "bf2d03a00100" + "9000", "81e2910003bf2d00", "bf2d03a001009000"},
+			},
+		},
+		{
+			name: "sensitive request and its response",
+			exchanges: []exchange{
+				{"81e2110004bf380102", "6100", "81e2110004<redacted 4 bytes>", "6100"},
+				{"81e2910102aabb", "6104", "81e2910102<redacted 2 bytes>", "6104"},
+				{"81c0000004", "a1b2c3d4" + "9000", "81c0000004", "<redacted 4 bytes>9000"},
+				{"81e2910003bf2d00", "bf2d00" + "9000", "81e2910003bf2d00", "bf2d009000"},
+			},
+		},
+		{
+			name: "sensitive response read with GET RESPONSE",
+			exchanges: []exchange{
+				{"81e2910003bf2e00", "6106", "81e2910003bf2e00", "6106"},
+				{"81c0000006", "bf2e03800101" + "9000", "81c0000006", "bf2e038001019000"},
+				{"81e2910003bf2100", "6104", "81e2910003<redacted 3 bytes>", "6104"},
+				{"81c0000004", "bf210100" + "6102", "81c0000004", "<redacted 4 bytes>6102"},
+				{"81c0000002", "0102" + "9000", "81c0000002", "<redacted 2 bytes>9000"},
+			},
+		},
+		{
+			name: "sensitive response to an ordinary message",
+			exchanges: []exchange{
+				{"81e2910003bf2d00", "bf380100" + "6102", "81e2910003bf2d00", "<redacted 4 bytes>6102"},
+				{"81c0000002", "0102" + "9000", "81c0000002", "<redacted 2 bytes>9000"},
+				{"0070800000", "01" + "9000", "0070800000", "019000"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const key = "test"
+			traces.begin(key, tt.name)
+			for _, e := range tt.exchanges {
+				traces.record(key, time.Now(), "transmit", decodeHex(t, e.command), decodeHex(t, e.response), nil)
+			}
+			traces.finish(key)
+			finished := traces.finished[key]
+			trace := finished[len(finished)-1]
+			for i, e := range tt.exchanges {
+				got := trace.Exchanges[i]
+				if got.Command != e.wantCommand || got.Response != e.wantResponse {
+					t.Errorf("exchange %d = %s / %s, want %s / %s", i, got.Command, got.Response, e.wantCommand, e.wantResponse)
+				}
+			}
+		})
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
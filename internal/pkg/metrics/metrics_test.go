@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func()
+		want   string
+	}{
+		{
+			name: "counter",
+			update: func() {
+				c := NewCounter("test_requests_total", "Requests served.\nBy route.", "route")
+				c.Inc("/modems")
+				c.Add(2.5, "/modems")
+				c.Inc(`/a"b\c`)
+			},
+			want: `# HELP test_requests_total Requests served.\nBy route.
+# TYPE test_requests_total counter
+test_requests_total{route="/a\"b\\c"} 1
+test_requests_total{route="/modems"} 3.5
+`,
+		},
+		{
+			name: "gauge reset",
+			update: func() {
+				g := NewGauge("test_signal_rssi", "Signal strength.", "modem", "technology")
+				g.Set(-70, "1", "lte")
+				g.Set(-80, "2", "lte")
+				g.Reset()
+				g.Set(-65.5, "2", "5g")
+				// A gauge without values after a reset is left out.
+				empty := NewGauge("test_empty", "Nothing.")
+				empty.Set(1)
+				empty.Reset()
+			},
+			want: `# HELP test_signal_rssi Signal strength.
+# TYPE test_signal_rssi gauge
+test_signal_rssi{modem="2",technology="5g"} -65.5
+`,
+		},
+		{
+			name: "histogram",
+			update: func() {
+				h := NewHistogram("test_duration_seconds", "Durations.", []float64{0.25, 1})
+				h.Observe(0.25)
+				h.Observe(0.5)
+				h.Observe(1)
+				h.Observe(4)
+			},
+			want: `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.25"} 1
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 5.75
+test_duration_seconds_count 4
+`,
+		},
+		{
+			name: "sorted by name",
+			update: func() {
+				NewGauge("test_b", "B.").Set(2)
+				NewCounter("test_a", "A.").Inc()
+			},
+			want: `# HELP test_a A.
+# TYPE test_a counter
+test_a 1
+# HELP test_b B.
+# TYPE test_b gauge
+test_b 2
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry.families = nil
+			tt.update()
+			var b strings.Builder
+			if err := Write(&b); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Write() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package modem
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/godbus/dbus/v5"
 )
 
@@ -23,58 +26,35 @@ type ThreeGPPNetwork struct {
 }
 
 func (g *ThreeGPP) IMEI() (string, error) {
-	variant, err := g.modem.dbusObject.GetProperty(Modem3GPPInterface + ".Imei")
-	if err != nil {
-		return "", err
-	}
-	return variant.Value().(string), nil
+	return getProperty[string](g.modem.dbusObject, Modem3GPPInterface, "Imei")
 }
 
 func (g *ThreeGPP) RegistrationState() (Modem3gppRegistrationState, error) {
-	variant, err := g.modem.dbusObject.GetProperty(Modem3GPPInterface + ".RegistrationState")
-	if err != nil {
-		return 0, err
-	}
-	return Modem3gppRegistrationState(variant.Value().(uint32)), nil
+	state, err := getProperty[uint32](g.modem.dbusObject, Modem3GPPInterface, "RegistrationState")
+	return Modem3gppRegistrationState(state), err
 }
 
 func (g *ThreeGPP) OperatorCode() (string, error) {
-	variant, err := g.modem.dbusObject.GetProperty(Modem3GPPInterface + ".OperatorCode")
-	if err != nil {
-		return "", err
-	}
-	return variant.Value().(string), nil
+	return getProperty[string](g.modem.dbusObject, Modem3GPPInterface, "OperatorCode")
 }
 
 func (g *ThreeGPP) OperatorName() (string, error) {
-	variant, err := g.modem.dbusObject.GetProperty(Modem3GPPInterface + ".OperatorName")
-	if err != nil {
-		return "", err
-	}
-	return variant.Value().(string), nil
+	return getProperty[string](g.modem.dbusObject, Modem3GPPInterface, "OperatorName")
 }
 
-func (g *ThreeGPP) ScanNetworks() ([]*ThreeGPPNetwork, error) {
+// ScanNetworks scans for available networks, which can take minutes.
+func (g *ThreeGPP) ScanNetworks(ctx context.Context) ([]*ThreeGPPNetwork, error) {
 	var results []map[string]dbus.Variant
-	err := g.modem.dbusObject.Call(Modem3GPPInterface+".Scan", 0).Store(&results)
+	err := g.modem.dbusObject.CallWithContext(ctx, Modem3GPPInterface+".Scan", 0).Store(&results)
 	if err != nil {
 		return nil, err
 	}
 	networks := make([]*ThreeGPPNetwork, len(results))
 	for i, result := range results {
-		var accessTechnology ModemAccessTechnology
-		n := ThreeGPPNetwork{
-			Status:           Modem3gppNetworkAvailability(result["status"].Value().(uint32)),
-			OperatorCode:     result["operator-code"].Value().(string),
-			AccessTechnology: accessTechnology.UnmarshalBitmask(result["access-technology"].Value().(uint32)),
-		}
-		if name, ok := result["operator-long"]; ok {
-			n.OperatorName = name.Value().(string)
-		}
-		if shortName, ok := result["operator-short"]; ok {
-			n.OperatorShortName = shortName.Value().(string)
+		networks[i], err = decodeNetwork(result)
+		if err != nil {
+			return nil, fmt.Errorf("decoding network %d: %w", i, err)
 		}
-		networks[i] = &n
 	}
 	return networks, nil
 }
@@ -1,5 +1,9 @@
 package modem
 
+import "github.com/damonto/sigmo/internal/pkg/metrics"
+
+var ussdRequests = metrics.NewCounter("sigmo_ussd_requests_total", "USSD requests by action and result.", "modem", "action", "result")
+
 type USSD struct {
 	modem *Modem
 }
@@ -11,12 +15,14 @@ func (g *ThreeGPP) USSD() *USSD {
 func (u *USSD) Initiate(command string) (string, error) {
 	var reply string
 	err := u.modem.dbusObject.Call(Modem3GPPInterface+".Ussd.Initiate", 0, command).Store(&reply)
+	ussdRequests.Inc(u.modem.EquipmentIdentifier, "initiate", metrics.Result(err))
 	return reply, err
 }
 
 func (u *USSD) Respond(response string) (string, error) {
 	var reply string
 	err := u.modem.dbusObject.Call(Modem3GPPInterface+".Ussd.Respond", 0, response).Store(&reply)
+	ussdRequests.Inc(u.modem.EquipmentIdentifier, "respond", metrics.Result(err))
 	return reply, err
 }
 
@@ -25,14 +31,10 @@ func (u *USSD) Cancel() error {
 }
 
 func (u *USSD) State() (Modem3gppUssdSessionState, error) {
-	variant, err := u.modem.dbusObject.GetProperty(Modem3GPPInterface + ".Ussd.State")
-	if err != nil {
-		return 0, err
-	}
-	return Modem3gppUssdSessionState(variant.Value().(uint32)), nil
+	state, err := getProperty[uint32](u.modem.dbusObject, Modem3GPPInterface+".Ussd", "State")
+	return Modem3gppUssdSessionState(state), err
 }
 
 func (u *USSD) NetworkRequest() (string, error) {
-	variant, err := u.modem.dbusObject.GetProperty(Modem3GPPInterface + ".Ussd.NetworkRequest")
-	return variant.Value().(string), err
+	return getProperty[string](u.modem.dbusObject, Modem3GPPInterface+".Ussd", "NetworkRequest")
 }
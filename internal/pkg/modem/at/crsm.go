@@ -28,9 +28,14 @@ type CRSMCommand struct {
 	P1          byte
 	P2          byte
 	Data        []byte
+	// Length is P3 of commands without data, such as how many bytes to read.
+	Length byte
 }
 
 func (c CRSMCommand) Bytes() []byte {
+	if len(c.Data) == 0 && c.Length > 0 {
+		return fmt.Appendf(nil, "%d,%d,%d,%d,%d", c.Instruction, c.FileID, c.P1, c.P2, c.Length)
+	}
 	return fmt.Appendf(nil, "%d,%d,%d,%d,%d,\"%X\"", c.Instruction, c.FileID, c.P1, c.P2, len(c.Data), c.Data)
 }
 
@@ -8,14 +8,35 @@ import (
 	"strings"
 )
 
-type CSIM struct {
-	at   *AT
-	data []byte
-}
+type CSIM struct{ at *AT }
 
 func NewCSIM(at *AT) ATCommand { return &CSIM{at: at} }
 
+// Run sends an APDU and returns its response data without the status words,
+// fetching the rest of the response while the card has more.
 func (c *CSIM) Run(command []byte) ([]byte, error) {
+	response, err := c.transmit(command)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		data = append(data, response[:len(response)-2]...)
+		switch response[len(response)-2] {
+		case 0x90:
+			return data, nil
+		case 0x61:
+			response, err = c.transmit([]byte{0x00, 0xC0, 0x00, 0x00, response[len(response)-1]})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected response: %X", response)
+		}
+	}
+}
+
+func (c *CSIM) transmit(command []byte) ([]byte, error) {
 	cmd := fmt.Sprintf("%X", command)
 	cmd = fmt.Sprintf("AT+CSIM=%d,%q", len(cmd), cmd)
 	slog.Debug("[AT] CSIM Sending", "command", cmd)
@@ -28,29 +49,12 @@ func (c *CSIM) Run(command []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if sw[len(sw)-2] != 0x61 && sw[len(sw)-2] != 0x90 {
-		return sw, fmt.Errorf("unexpected response: %X", sw)
-	}
-	if sw[len(sw)-2] == 0x61 {
-		return c.read(sw[1:])
+	if len(sw) < 2 {
+		return nil, fmt.Errorf("unexpected response: %s", response)
 	}
 	return sw, nil
 }
 
-func (c *CSIM) read(length []byte) ([]byte, error) {
-	for {
-		b, err := c.Run(append([]byte{0x00, 0xC0, 0x00, 0x00}, length...))
-		if err != nil {
-			return nil, err
-		}
-		c.data = append(c.data, b[:len(b)-2]...)
-		if b[len(b)-2] == 0x90 {
-			break
-		}
-	}
-	return c.data, nil
-}
-
 func (c *CSIM) sw(sw string) ([]byte, error) {
 	lastIdx := strings.LastIndex(sw, ",")
 	if lastIdx == -1 {
@@ -18,6 +18,70 @@ const (
 	ModemStateConnected                           // One or more packet data bearers is active and connected.
 )
 
+func (s ModemState) String() string {
+	switch s {
+	case ModemStateFailed:
+		return "Failed"
+	case ModemStateUnknown:
+		return "Unknown"
+	case ModemStateInitializing:
+		return "Initializing"
+	case ModemStateLocked:
+		return "Locked"
+	case ModemStateDisabled:
+		return "Disabled"
+	case ModemStateDisabling:
+		return "Disabling"
+	case ModemStateEnabling:
+		return "Enabling"
+	case ModemStateEnabled:
+		return "Enabled"
+	case ModemStateSearching:
+		return "Searching"
+	case ModemStateRegistered:
+		return "Registered"
+	case ModemStateDisconnecting:
+		return "Disconnecting"
+	case ModemStateConnecting:
+		return "Connecting"
+	case ModemStateConnected:
+		return "Connected"
+	default:
+		return "Undefined"
+	}
+}
+
+// ModemStateFailedReason tells why a modem is in the ModemStateFailed state.
+type ModemStateFailedReason uint32
+
+const (
+	ModemStateFailedReasonNone                ModemStateFailedReason = iota // No error.
+	ModemStateFailedReasonUnknown                                           // Unknown error.
+	ModemStateFailedReasonSimMissing                                        // SIM is required but missing.
+	ModemStateFailedReasonSimError                                          // SIM is available, but unusable (e.g. permanently locked).
+	ModemStateFailedReasonUnknownCapabilities                               // Unknown modem capabilities.
+	ModemStateFailedReasonEsimWithoutProfiles                               // eSIM is not initialized.
+)
+
+func (r ModemStateFailedReason) String() string {
+	switch r {
+	case ModemStateFailedReasonNone:
+		return "None"
+	case ModemStateFailedReasonUnknown:
+		return "Unknown"
+	case ModemStateFailedReasonSimMissing:
+		return "SimMissing"
+	case ModemStateFailedReasonSimError:
+		return "SimError"
+	case ModemStateFailedReasonUnknownCapabilities:
+		return "UnknownCapabilities"
+	case ModemStateFailedReasonEsimWithoutProfiles:
+		return "EsimWithoutProfiles"
+	default:
+		return "Undefined"
+	}
+}
+
 type ModemPortType uint32
 
 const (
@@ -106,6 +170,26 @@ func (m Modem3gppRegistrationState) String() string {
 	}
 }
 
+// Registered reports whether the modem is registered on a home or roaming network.
+func (m Modem3gppRegistrationState) Registered() bool {
+	switch m {
+	case Modem3gppRegistrationStateHome, Modem3gppRegistrationStateHomeSmsOnly, Modem3gppRegistrationStateHomeCsfbNotPreferred:
+		return true
+	default:
+		return m.Roaming()
+	}
+}
+
+// Roaming reports whether the modem is registered on a roaming network.
+func (m Modem3gppRegistrationState) Roaming() bool {
+	switch m {
+	case Modem3gppRegistrationStateRoaming, Modem3gppRegistrationStateRoamingSmsOnly, Modem3gppRegistrationStateRoamingCsfbNotPreferred:
+		return true
+	default:
+		return false
+	}
+}
+
 type Modem3gppUssdSessionState uint32
 
 const (
@@ -0,0 +1,81 @@
+// Package fplmn reads and clears the forbidden PLMN list of a SIM (EF FPLMN).
+// The modem adds networks that reject it to the list and never selects them
+// automatically again, which can leave a roaming SIM without service.
+package fplmn
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/damonto/sigmo/internal/pkg/modem/at"
+	"github.com/damonto/sigmo/internal/pkg/modem/simfile"
+)
+
+type FPLMN struct {
+	at    *at.AT
+	files *simfile.Files
+}
+
+func New(device string) (*FPLMN, error) {
+	conn, err := at.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	files, err := simfile.New(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &FPLMN{at: conn, files: files}, nil
+}
+
+func (f *FPLMN) Close() error {
+	return f.at.Close()
+}
+
+// List returns the MCC and MNC of every forbidden network, in the order stored.
+func (f *FPLMN) List() ([]string, error) {
+	data, err := f.files.ReadBinary(simfile.EFFPLMN)
+	if err != nil {
+		return nil, err
+	}
+	operators := make([]string, 0, len(data)/3)
+	for i := 0; i+3 <= len(data); i += 3 {
+		if bytes.Equal(data[i:i+3], []byte{0xFF, 0xFF, 0xFF}) {
+			continue
+		}
+		operator, err := decode(data[i : i+3])
+		if err != nil {
+			return nil, err
+		}
+		operators = append(operators, operator)
+	}
+	return operators, nil
+}
+
+// Clear empties the list. The modem keeps its own copy until it restarts.
+func (f *FPLMN) Clear() error {
+	size, err := f.files.FileSize(simfile.EFFPLMN)
+	if err != nil {
+		return err
+	}
+	return f.files.UpdateBinary(simfile.EFFPLMN, bytes.Repeat([]byte{0xFF}, size))
+}
+
+// decode decodes a PLMN as stored on the SIM (3GPP TS 24.008): MCC digit 2 and 1,
+// MNC digit 3 and MCC digit 3, then MNC digit 2 and 1, each byte low nibble first.
+// An MNC digit 3 of F means a two digit MNC.
+func decode(b []byte) (string, error) {
+	digits := []byte{b[0] & 0x0F, b[0] >> 4, b[1] & 0x0F, b[2] & 0x0F, b[2] >> 4, b[1] >> 4}
+	code := make([]byte, 0, len(digits))
+	for i, digit := range digits {
+		if i == len(digits)-1 && digit == 0x0F {
+			break
+		}
+		if digit > 9 {
+			return "", fmt.Errorf("invalid PLMN %X", b)
+		}
+		code = append(code, '0'+digit)
+	}
+	return string(code), nil
+}
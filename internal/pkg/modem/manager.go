@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -19,8 +21,13 @@ const (
 
 	ModemManagerInterfacesAdded   = "org.freedesktop.DBus.ObjectManager.InterfacesAdded"
 	ModemManagerInterfacesRemoved = "org.freedesktop.DBus.ObjectManager.InterfacesRemoved"
+	PropertiesChanged             = "org.freedesktop.DBus.Properties.PropertiesChanged"
+	NameOwnerChanged              = "org.freedesktop.DBus.NameOwnerChanged"
 )
 
+// Manager keeps the modems exported by ModemManager in memory. They are loaded once
+// and then kept up to date from ModemManager's signals, so looking up a modem does
+// not cost a D-Bus round trip.
 type Manager struct {
 	dbusConn   *dbus.Conn
 	dbusObject dbus.BusObject
@@ -28,7 +35,11 @@ type Manager struct {
 	mu         sync.RWMutex
 	subs       []subscription
 	nextSubID  uint64
-	subscribe  sync.Once
+	// update serializes loading the modems with applying signals to them.
+	update   sync.Mutex
+	loaded   atomic.Bool
+	watch    sync.Once
+	watchErr error
 }
 
 type ModemEventType int
@@ -36,6 +47,12 @@ type ModemEventType int
 const (
 	ModemEventAdded ModemEventType = iota
 	ModemEventRemoved
+	ModemEventStateChanged
+	ModemEventSignalQualityChanged
+	ModemEventAccessTechnologiesChanged
+	ModemEventRegistrationChanged
+	ModemEventSimChanged
+	ModemEventPrimarySimSlotChanged
 )
 
 func (t ModemEventType) String() string {
@@ -44,16 +61,46 @@ func (t ModemEventType) String() string {
 		return "added"
 	case ModemEventRemoved:
 		return "removed"
+	case ModemEventStateChanged:
+		return "state-changed"
+	case ModemEventSignalQualityChanged:
+		return "signal-quality-changed"
+	case ModemEventAccessTechnologiesChanged:
+		return "access-technologies-changed"
+	case ModemEventRegistrationChanged:
+		return "registration-changed"
+	case ModemEventSimChanged:
+		return "sim-changed"
+	case ModemEventPrimarySimSlotChanged:
+		return "primary-sim-slot-changed"
 	default:
 		return "unknown"
 	}
 }
 
+// ModemEvent reports a modem being added or removed, or one of its properties changing.
+// Only the fields belonging to the event type are set.
 type ModemEvent struct {
-	Type     ModemEventType
+	Type ModemEventType
+	// Modem is the modem after the event, or the modem that was removed.
 	Modem    *Modem
 	Path     dbus.ObjectPath
 	Snapshot map[dbus.ObjectPath]*Modem
+
+	// PreviousState and State are set for ModemEventStateChanged.
+	PreviousState ModemState
+	State         ModemState
+	// SignalQuality is set for ModemEventSignalQualityChanged, in percent.
+	SignalQuality uint32
+	// AccessTechnologies is set for ModemEventAccessTechnologiesChanged.
+	AccessTechnologies []ModemAccessTechnology
+	// RegistrationState and OperatorCode are set for ModemEventRegistrationChanged.
+	RegistrationState Modem3gppRegistrationState
+	OperatorCode      string
+	// Sim is set for ModemEventSimChanged. It is nil when the SIM is gone.
+	Sim *SIM
+	// PrimarySimSlot is set for ModemEventPrimarySimSlotChanged.
+	PrimarySimSlot uint32
 }
 
 type subscription struct {
@@ -74,6 +121,17 @@ func NewManager() (*Manager, error) {
 	return m, nil
 }
 
+// Connected reports whether the system bus connection is still open.
+func (m *Manager) Connected() bool {
+	return m.dbusConn.Connected()
+}
+
+// Ping checks that ModemManager answers on the system bus, without making it
+// probe devices like ScanDevices does.
+func (m *Manager) Ping(ctx context.Context) error {
+	return m.dbusObject.CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0).Err
+}
+
 func (m *Manager) ScanDevices() error {
 	return m.dbusObject.Call(ModemManagerInterface+".ScanDevices", 0).Err
 }
@@ -82,10 +140,37 @@ func (m *Manager) InhibitDevice(uid string, inhibit bool) error {
 	return m.dbusObject.Call(ModemManagerInterface+".InhibitDevice", 0, uid, inhibit).Err
 }
 
+// Modems returns the modems currently exported by ModemManager, keyed by object path.
 func (m *Manager) Modems() (map[dbus.ObjectPath]*Modem, error) {
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.copyModemsLocked(), nil
+}
+
+// load starts watching ModemManager's signals and, the first time it succeeds,
+// reads every modem it exports. Signals received in the meantime wait for it.
+func (m *Manager) load() error {
+	m.watch.Do(func() {
+		m.watchErr = m.startSubscription()
+	})
+	if m.watchErr != nil {
+		return m.watchErr
+	}
+	// Subscribers run while signals are applied, so they must not wait for update.
+	if m.loaded.Load() {
+		return nil
+	}
+	m.update.Lock()
+	defer m.update.Unlock()
+	if m.loaded.Load() {
+		return nil
+	}
 	managedObjects := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant)
 	if err := m.dbusObject.Call(ModemManagerManagedObjects, 0).Store(&managedObjects); err != nil {
-		return nil, err
+		return err
 	}
 	modems := make(map[dbus.ObjectPath]*Modem, len(managedObjects))
 	for objectPath, data := range managedObjects {
@@ -101,26 +186,19 @@ func (m *Manager) Modems() (map[dbus.ObjectPath]*Modem, error) {
 	}
 	m.mu.Lock()
 	m.modems = modems
-	snapshot := m.copyModemsLocked()
 	m.mu.Unlock()
-	return snapshot, nil
+	m.loaded.Store(true)
+	return nil
 }
 
 func (m *Manager) createModem(objectPath dbus.ObjectPath, data map[string]dbus.Variant) (*Modem, error) {
 	modem := Modem{
-		mmgr:                m,
-		objectPath:          objectPath,
-		dbusObject:          m.dbusConn.Object(ModemManagerInterface, objectPath),
-		Device:              data["Device"].Value().(string),
-		Manufacturer:        data["Manufacturer"].Value().(string),
-		EquipmentIdentifier: data["EquipmentIdentifier"].Value().(string),
-		Driver:              data["Drivers"].Value().([]string)[0],
-		Model:               data["Model"].Value().(string),
-		FirmwareRevision:    data["Revision"].Value().(string),
-		HardwareRevision:    data["HardwareRevision"].Value().(string),
-		State:               ModemState(data["State"].Value().(int32)),
-		PrimaryPort:         fmt.Sprintf("/dev/%s", data["PrimaryPort"].Value().(string)),
-		PrimarySimSlot:      data["PrimarySimSlot"].Value().(uint32),
+		mmgr:       m,
+		objectPath: objectPath,
+		dbusObject: m.dbusConn.Object(ModemManagerInterface, objectPath),
+	}
+	if err := decodeModem(&modem, data); err != nil {
+		return nil, fmt.Errorf("decoding modem %s: %w", objectPath, err)
 	}
 	if modem.State == ModemStateDisabled {
 		slog.Info("enabling modem", "path", objectPath)
@@ -129,23 +207,16 @@ func (m *Manager) createModem(objectPath dbus.ObjectPath, data map[string]dbus.V
 			return nil, err
 		}
 	}
-	var err error
-	modem.Sim, err = modem.SIMs().Get(data["Sim"].Value().(dbus.ObjectPath))
+	simPath, err := property[dbus.ObjectPath](data, "Sim")
 	if err != nil {
-		return nil, err
-	}
-	if numbers := data["OwnNumbers"].Value().([]string); len(numbers) > 0 {
-		modem.Number = numbers[0]
+		return nil, fmt.Errorf("decoding modem %s: %w", objectPath, err)
 	}
-	for _, port := range data["Ports"].Value().([][]any) {
-		modem.Ports = append(modem.Ports, ModemPort{
-			PortType: ModemPortType(port[1].(uint32)),
-			Device:   fmt.Sprintf("/dev/%s", port[0]),
-		})
-	}
-	for _, slot := range data["SimSlots"].Value().([]dbus.ObjectPath) {
-		if slot != "/" {
-			modem.SimSlots = append(modem.SimSlots, slot)
+	// A modem without a SIM reports "/", and is still listed so that its slots
+	// can be switched and the missing SIM reported.
+	if simPath != "/" {
+		modem.Sim, err = modem.SIMs().Get(simPath)
+		if err != nil {
+			return nil, err
 		}
 	}
 	return &modem, nil
@@ -161,11 +232,7 @@ func (m *Manager) Subscribe(subscriber func(ModemEvent) error) (func(), error) {
 	m.subs = append(m.subs, subscription{id: id, fn: subscriber})
 	m.mu.Unlock()
 
-	var err error
-	m.subscribe.Do(func() {
-		err = m.startSubscription()
-	})
-	if err != nil {
+	if err := m.load(); err != nil {
 		m.mu.Lock()
 		for i, sub := range m.subs {
 			if sub.id == id {
@@ -248,6 +315,28 @@ func (m *Manager) startSubscription() error {
 	); err != nil {
 		return err
 	}
+	if err := m.dbusConn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchPathNamespace("/org/freedesktop/ModemManager1"),
+	); err != nil {
+		return err
+	}
+	if err := m.dbusConn.AddMatchSignal(
+		dbus.WithMatchInterface(ModemMessagingInterface),
+		dbus.WithMatchMember("Added"),
+		dbus.WithMatchPathNamespace("/org/freedesktop/ModemManager1"),
+	); err != nil {
+		return err
+	}
+	// ModemManager does not remove its objects when it exits, so watch for that too.
+	if err := m.dbusConn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, ModemManagerInterface),
+	); err != nil {
+		return err
+	}
 
 	sig := make(chan *dbus.Signal, 10)
 	m.dbusConn.Signal(sig)
@@ -257,46 +346,282 @@ func (m *Manager) startSubscription() error {
 
 func (m *Manager) handleSignals(sig <-chan *dbus.Signal) {
 	for event := range sig {
-		modemPath := event.Body[0].(dbus.ObjectPath)
-		var (
-			modem     *Modem
-			eventType ModemEventType
-		)
-		if event.Name == ModemManagerInterfacesAdded {
-			eventType = ModemEventAdded
-			slog.Info("new modem plugged in", "path", modemPath)
-			raw := event.Body[1].(map[string]map[string]dbus.Variant)
-			var err error
-			modem, err = m.createModem(modemPath, raw["org.freedesktop.ModemManager1.Modem"])
-			if err != nil {
-				slog.Error("failed to create modem", "error", err)
+		m.update.Lock()
+		switch event.Name {
+		case ModemManagerInterfacesAdded, ModemManagerInterfacesRemoved:
+			m.handleInterfaces(event)
+		case PropertiesChanged:
+			m.handlePropertiesChanged(event)
+		case NameOwnerChanged:
+			m.handleNameOwnerChanged(event)
+		case ModemMessagingInterface + ".Added":
+			m.handleMessageAdded(event)
+		}
+		m.update.Unlock()
+	}
+}
+
+func (m *Manager) handleInterfaces(event *dbus.Signal) {
+	if len(event.Body) < 2 {
+		return
+	}
+	modemPath, ok := event.Body[0].(dbus.ObjectPath)
+	if !ok {
+		return
+	}
+	var (
+		modem     *Modem
+		eventType ModemEventType
+	)
+	if event.Name == ModemManagerInterfacesAdded {
+		raw, ok := event.Body[1].(map[string]map[string]dbus.Variant)
+		if !ok {
+			return
+		}
+		data, ok := raw[ModemInterface]
+		if !ok {
+			return
+		}
+		eventType = ModemEventAdded
+		slog.Info("new modem plugged in", "path", modemPath)
+		var err error
+		modem, err = m.createModem(modemPath, data)
+		if err != nil {
+			slog.Error("failed to create modem", "error", err)
+			return
+		}
+	} else {
+		interfaces, ok := event.Body[1].([]string)
+		if !ok || !slices.Contains(interfaces, ModemInterface) {
+			return
+		}
+		eventType = ModemEventRemoved
+		slog.Info("modem unplugged", "path", modemPath)
+	}
+
+	m.mu.Lock()
+	if eventType == ModemEventAdded {
+		m.deleteAndUpdate(modem)
+	} else {
+		modem = m.modems[modemPath]
+		delete(m.modems, modemPath)
+	}
+	m.mu.Unlock()
+	m.publish(ModemEvent{Type: eventType, Modem: modem, Path: modemPath})
+}
+
+// handlePropertiesChanged publishes an event for each tracked property that changed.
+// The cached modem is replaced with a copy carrying the new values, so that callers
+// holding the previous one are not affected.
+func (m *Manager) handlePropertiesChanged(event *dbus.Signal) {
+	if len(event.Body) < 2 {
+		return
+	}
+	iface, _ := event.Body[0].(string)
+	changed, ok := event.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return
+	}
+	if iface == ModemSimInterface {
+		m.handleSimPropertiesChanged(event.Path)
+		return
+	}
+	if iface != ModemInterface && iface != Modem3GPPInterface {
+		return
+	}
+	m.mu.RLock()
+	current, ok := m.modems[event.Path]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	updated := *current
+	var events []ModemEvent
+	if iface == Modem3GPPInterface {
+		if e, ok := registrationChanged(&updated, changed); ok {
+			events = append(events, e)
+		}
+	}
+	if iface == ModemInterface {
+		events = modemChanged(&updated, changed)
+	}
+	m.mu.Lock()
+	if m.modems[event.Path] != current {
+		m.mu.Unlock()
+		return
+	}
+	m.modems[event.Path] = &updated
+	m.mu.Unlock()
+	for _, e := range events {
+		e.Modem = &updated
+		e.Path = event.Path
+		m.publish(e)
+	}
+}
+
+// modemChanged applies changed properties of the Modem interface to modem
+// and returns the events they raise.
+func modemChanged(modem *Modem, changed map[string]dbus.Variant) []ModemEvent {
+	var events []ModemEvent
+	for name, value := range changed {
+		switch name {
+		case "State":
+			if state, ok := value.Value().(int32); ok && ModemState(state) != modem.State {
+				events = append(events, ModemEvent{
+					Type:          ModemEventStateChanged,
+					PreviousState: modem.State,
+					State:         ModemState(state),
+				})
+				modem.State = ModemState(state)
+			}
+		case "StateFailedReason":
+			if reason, ok := value.Value().(uint32); ok {
+				modem.StateFailedReason = ModemStateFailedReason(reason)
+			}
+		case "SignalQuality":
+			if values, ok := value.Value().([]any); ok {
+				if percent, _, err := decodeSignalQuality(values); err == nil {
+					events = append(events, ModemEvent{Type: ModemEventSignalQualityChanged, SignalQuality: percent})
+				}
+			}
+		case "AccessTechnologies":
+			if bitmask, ok := value.Value().(uint32); ok {
+				events = append(events, ModemEvent{
+					Type:               ModemEventAccessTechnologiesChanged,
+					AccessTechnologies: ModemAccessTechnology(bitmask).UnmarshalBitmask(bitmask),
+				})
+			}
+		case "PrimarySimSlot":
+			if slot, ok := value.Value().(uint32); ok && slot != modem.PrimarySimSlot {
+				modem.PrimarySimSlot = slot
+				events = append(events, ModemEvent{Type: ModemEventPrimarySimSlotChanged, PrimarySimSlot: slot})
+			}
+		case "OwnNumbers":
+			if numbers, ok := value.Value().([]string); ok {
+				modem.Number = ""
+				if len(numbers) > 0 {
+					modem.Number = numbers[0]
+				}
+			}
+		case "Sim":
+			path, ok := value.Value().(dbus.ObjectPath)
+			if !ok {
 				continue
 			}
-		} else {
-			eventType = ModemEventRemoved
-			slog.Info("modem unplugged", "path", modemPath)
+			modem.Sim = nil
+			if path != "/" {
+				sim, err := modem.SIMs().Get(path)
+				if err != nil {
+					slog.Warn("failed to read SIM", "modem", modem.EquipmentIdentifier, "path", path, "error", err)
+					continue
+				}
+				modem.Sim = sim
+			}
+			events = append(events, ModemEvent{Type: ModemEventSimChanged, Sim: modem.Sim})
 		}
+	}
+	return events
+}
 
-		m.mu.Lock()
-		if eventType == ModemEventAdded {
-			m.deleteAndUpdate(modem)
-		} else {
-			modem = m.modems[modemPath]
-			delete(m.modems, modemPath)
+// registrationChanged returns the event raised by changed properties of the 3GPP interface.
+// ModemManager may report only one of the registration state and operator code, so the
+// other one is read from the modem.
+func registrationChanged(modem *Modem, changed map[string]dbus.Variant) (ModemEvent, bool) {
+	stateValue, hasState := changed["RegistrationState"]
+	codeValue, hasCode := changed["OperatorCode"]
+	if !hasState && !hasCode {
+		return ModemEvent{}, false
+	}
+	event := ModemEvent{Type: ModemEventRegistrationChanged}
+	var err error
+	if state, ok := stateValue.Value().(uint32); hasState && ok {
+		event.RegistrationState = Modem3gppRegistrationState(state)
+	} else if event.RegistrationState, err = modem.ThreeGPP().RegistrationState(); err != nil {
+		slog.Warn("failed to read registration state", "modem", modem.EquipmentIdentifier, "error", err)
+	}
+	if code, ok := codeValue.Value().(string); hasCode && ok {
+		event.OperatorCode = code
+	} else if event.OperatorCode, err = modem.ThreeGPP().OperatorCode(); err != nil {
+		slog.Warn("failed to read operator code", "modem", modem.EquipmentIdentifier, "error", err)
+	}
+	return event, true
+}
+
+// handleSimPropertiesChanged re-reads the SIM at path and publishes it for the modem using it.
+func (m *Manager) handleSimPropertiesChanged(path dbus.ObjectPath) {
+	m.mu.RLock()
+	var (
+		current   *Modem
+		modemPath dbus.ObjectPath
+	)
+	for p, modem := range m.modems {
+		if modem.Sim != nil && modem.Sim.Path == path {
+			current, modemPath = modem, p
+			break
 		}
-		snapshot := m.copyModemsLocked()
-		subscribers := append([]subscription(nil), m.subs...)
+	}
+	m.mu.RUnlock()
+	if current == nil {
+		return
+	}
+	sim, err := current.SIMs().Get(path)
+	if err != nil {
+		slog.Warn("failed to read SIM", "modem", current.EquipmentIdentifier, "path", path, "error", err)
+		return
+	}
+	updated := *current
+	updated.Sim = sim
+	m.mu.Lock()
+	if m.modems[modemPath] != current {
 		m.mu.Unlock()
+		return
+	}
+	m.modems[modemPath] = &updated
+	m.mu.Unlock()
+	m.publish(ModemEvent{Type: ModemEventSimChanged, Modem: &updated, Path: modemPath, Sim: sim})
+}
 
-		for _, subscriber := range subscribers {
-			if err := subscriber.fn(ModemEvent{
-				Type:     eventType,
-				Modem:    modem,
-				Path:     modemPath,
-				Snapshot: snapshot,
-			}); err != nil {
-				slog.Error("failed to process modem", "error", err)
-			}
+// handleMessageAdded counts messages received by a modem. Messages created
+// locally for sending are added too, but are not marked as received.
+func (m *Manager) handleMessageAdded(event *dbus.Signal) {
+	if len(event.Body) < 2 {
+		return
+	}
+	if received, _ := event.Body[1].(bool); !received {
+		return
+	}
+	m.mu.RLock()
+	modem, ok := m.modems[event.Path]
+	m.mu.RUnlock()
+	if ok {
+		smsReceived.Inc(modem.EquipmentIdentifier)
+	}
+}
+
+func (m *Manager) handleNameOwnerChanged(event *dbus.Signal) {
+	if len(event.Body) < 3 || event.Body[2] != "" {
+		return
+	}
+	slog.Warn("ModemManager exited, forgetting its modems")
+	m.mu.Lock()
+	removed := m.modems
+	m.modems = make(map[dbus.ObjectPath]*Modem, 16)
+	m.mu.Unlock()
+	for path, modem := range removed {
+		m.publish(ModemEvent{Type: ModemEventRemoved, Modem: modem, Path: path})
+	}
+}
+
+// publish sends event, along with a snapshot of the modems, to every subscriber.
+func (m *Manager) publish(event ModemEvent) {
+	m.mu.RLock()
+	event.Snapshot = m.copyModemsLocked()
+	subscribers := append([]subscription(nil), m.subs...)
+	m.mu.RUnlock()
+	for _, subscriber := range subscribers {
+		if err := subscriber.fn(event); err != nil {
+			slog.Error("failed to process modem", "error", err)
 		}
 	}
 }
@@ -68,10 +68,15 @@ func (msg *Messaging) Subscribe(ctx context.Context, subscriber func(message *SM
 	for {
 		select {
 		case sig := <-signalChan:
-			if !sig.Body[1].(bool) {
+			if len(sig.Body) < 2 {
 				continue
 			}
-			s, err := msg.waitForSMSReceived(ctx, sig.Body[0].(dbus.ObjectPath), 100*time.Millisecond)
+			path, ok := sig.Body[0].(dbus.ObjectPath)
+			received, _ := sig.Body[1].(bool)
+			if !ok || !received {
+				continue
+			}
+			s, err := msg.waitForSMSReceived(ctx, path, 100*time.Millisecond)
 			if err != nil {
 				slog.Error("failed to process message", "error", err, "path", sig.Path)
 				continue
@@ -28,6 +28,7 @@ type Modem struct {
 	PrimarySimSlot      uint32
 	Sim                 *SIM
 	State               ModemState
+	StateFailedReason   ModemStateFailedReason
 }
 
 type ModemPort struct {
@@ -48,21 +49,19 @@ func (m *Modem) SetPrimarySimSlot(slot uint32) error {
 }
 
 func (m *Modem) AccessTechnologies() ([]ModemAccessTechnology, error) {
-	variant, err := m.dbusObject.GetProperty(ModemInterface + ".AccessTechnologies")
+	bitmask, err := getProperty[uint32](m.dbusObject, ModemInterface, "AccessTechnologies")
 	if err != nil {
 		return nil, err
 	}
-	bitmask := variant.Value().(uint32)
 	return ModemAccessTechnology(bitmask).UnmarshalBitmask(bitmask), nil
 }
 
 func (m *Modem) SignalQuality() (percent uint32, recent bool, err error) {
-	variant, err := m.dbusObject.GetProperty(ModemInterface + ".SignalQuality")
+	values, err := getProperty[[]any](m.dbusObject, ModemInterface, "SignalQuality")
 	if err != nil {
 		return 0, false, err
 	}
-	values := variant.Value().([]any)
-	return values[0].(uint32), values[1].(bool), nil
+	return decodeSignalQuality(values)
 }
 
 func (m *Modem) Restart(compatible bool) error {
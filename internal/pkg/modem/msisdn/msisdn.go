@@ -4,18 +4,18 @@ import (
 	"bytes"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/damonto/sigmo/internal/pkg/modem/at"
+	"github.com/damonto/sigmo/internal/pkg/modem/simfile"
 )
 
 var phoneRE = regexp.MustCompile(`^\+?[0-9]{1,15}$`)
 
 type MSISDN struct {
-	at     *at.AT
-	runner Runner
+	at    *at.AT
+	files *simfile.Files
 }
 
 func New(device string) (*MSISDN, error) {
@@ -23,12 +23,12 @@ func New(device string) (*MSISDN, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MSISDN{at: conn}
-	if err := m.selectRunner(); err != nil {
+	files, err := simfile.New(conn)
+	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
-	return m, nil
+	return &MSISDN{at: conn, files: files}, nil
 }
 
 func (m *MSISDN) Close() error {
@@ -42,18 +42,6 @@ func (m *MSISDN) Update(name, number string) error {
 	return m.update(strings.HasPrefix(number, "+"), name, number)
 }
 
-func (m *MSISDN) selectRunner() error {
-	if m.at.Support("AT+CRSM=?") {
-		m.runner = NewCRSM(m.at)
-		return nil
-	}
-	if m.at.Support("AT+CSIM=?") {
-		m.runner = NewCSIM(m.at)
-		return nil
-	}
-	return errors.New("modem does not support updating MSISDN")
-}
-
 func (m *MSISDN) update(hasPrefix bool, name string, number string) error {
 	n, err := m.recordLen()
 	if err != nil {
@@ -76,19 +64,11 @@ func (m *MSISDN) update(hasPrefix bool, name string, number string) error {
 		[]byte{byte(len(nb) + 1), tonNpi},
 		m.padRight(nb, 12)...,
 	)...)
-	return m.runner.Run(data)
+	return m.files.UpdateRecord(simfile.EFMSISDN, 1, data)
 }
 
 func (m *MSISDN) recordLen() (int, error) {
-	b, err := m.runner.Select()
-	if err != nil {
-		return 0, err
-	}
-	data := m.findTag(b, 0x82)
-	if data == nil {
-		return 0, fmt.Errorf("unexpected response: %X", b)
-	}
-	return int(data[4])<<8 + int(data[5]), nil
+	return m.files.RecordLength(simfile.EFMSISDN)
 }
 
 func (m *MSISDN) encodeBCD(value string) ([]byte, error) {
@@ -116,15 +96,3 @@ func (m *MSISDN) padRight(value []byte, length int) []byte {
 	}
 	return append(value, bytes.Repeat([]byte{0xFF}, length-len(value))...)
 }
-
-func (m *MSISDN) findTag(bs []byte, tag byte) []byte {
-	bs = bs[2:]
-	for len(bs) > 0 {
-		n := int(bs[1])
-		if bs[0] == tag {
-			return bs[:2+n]
-		}
-		bs = bs[2+n:]
-	}
-	return nil
-}
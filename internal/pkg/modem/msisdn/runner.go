@@ -1,6 +0,0 @@
-package msisdn
-
-type Runner interface {
-	Run(data []byte) error
-	Select() ([]byte, error)
-}
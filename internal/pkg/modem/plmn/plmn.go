@@ -0,0 +1,165 @@
+// Package plmn manages the user controlled PLMN selector list of a SIM (EF PLMNwAcT)
+// with AT+CPOL. The modem prefers these networks, in order, when it selects a network
+// automatically.
+package plmn
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/damonto/sigmo/internal/pkg/modem/at"
+)
+
+var (
+	ErrInvalidOperator = errors.New("operator code must be 5 or 6 digits")
+	ErrListFull        = errors.New("too many networks for the SIM")
+	errUnsupported     = errors.New("modem does not support the preferred PLMN list")
+)
+
+var (
+	operatorRE = regexp.MustCompile(`^[0-9]{5,6}$`)
+	// entryRE matches +CPOL: <index>,<format>,"<oper>"[,<GSM>,<GSM compact>,<UTRAN>,<E-UTRAN>[,<NG-RAN>]].
+	entryRE = regexp.MustCompile(`^\+CPOL:\s*(\d+),\s*2,\s*"([0-9]+)"((?:,\s*[01])*)`)
+	// rangeRE matches the index range of +CPOL: (1-n),(0-2).
+	rangeRE = regexp.MustCompile(`^\+CPOL:\s*\((\d+)-(\d+)\)`)
+)
+
+// Entry is a network in the list with the access technologies it is preferred on.
+type Entry struct {
+	OperatorCode string
+	GSM          bool
+	UTRAN        bool
+	EUTRAN       bool
+	NGRAN        bool
+	// index is the position of the entry on the SIM, set by List. The list may
+	// have gaps, e.g. entries at 1, 5 and 9.
+	index int
+}
+
+type PLMN struct {
+	at *at.AT
+}
+
+func New(device string) (*PLMN, error) {
+	conn, err := at.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	p := &PLMN{at: conn}
+	// Select the user controlled list, rather than the operator controlled one.
+	if _, err := conn.Run("AT+CPLS=0"); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %w", errUnsupported, err)
+	}
+	if _, err := conn.Run("AT+CPOL=,2"); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %w", errUnsupported, err)
+	}
+	return p, nil
+}
+
+func (p *PLMN) Close() error {
+	return p.at.Close()
+}
+
+// List returns the networks in the list, most preferred first.
+func (p *PLMN) List() ([]Entry, error) {
+	response, err := p.at.Run("AT+CPOL?")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0)
+	for line := range strings.SplitSeq(response, "\n") {
+		match := entryRE.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("parsing index %q: %w", match[1], err)
+		}
+		entry := Entry{OperatorCode: match[2], index: index}
+		var flags []bool
+		for flag := range strings.SplitSeq(match[3], ",") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				flags = append(flags, flag == "1")
+			}
+		}
+		// The GSM compact flag at index 1 is not used by any modem sigmo supports.
+		for i, value := range []*bool{&entry.GSM, nil, &entry.UTRAN, &entry.EUTRAN, &entry.NGRAN} {
+			if value != nil && i < len(flags) {
+				*value = flags[i]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Replace writes entries as the whole list, most preferred first.
+func (p *PLMN) Replace(entries []Entry) error {
+	for _, entry := range entries {
+		if !operatorRE.MatchString(entry.OperatorCode) {
+			return fmt.Errorf("%w: %q", ErrInvalidOperator, entry.OperatorCode)
+		}
+	}
+	size, err := p.size()
+	if err != nil {
+		return err
+	}
+	if len(entries) > size {
+		return fmt.Errorf("%w: the SIM holds %d", ErrListFull, size)
+	}
+	for i, entry := range entries {
+		command := fmt.Sprintf("AT+CPOL=%d,2,%q,%d,0,%d,%d", i+1, entry.OperatorCode, bit(entry.GSM), bit(entry.UTRAN), bit(entry.EUTRAN))
+		// Older modems reject the NG-RAN flag, so it is only sent when set.
+		if entry.NGRAN {
+			command += ",1"
+		}
+		if _, err := p.at.Run(command); err != nil {
+			return fmt.Errorf("writing %s: %w", entry.OperatorCode, err)
+		}
+	}
+	existing, err := p.List()
+	if err != nil {
+		return err
+	}
+	// Deleting an index that is empty fails on some modems, so only the indices
+	// that hold an entry not just written are deleted, from the end as the list
+	// may be compacted.
+	slices.SortFunc(existing, func(a, b Entry) int { return b.index - a.index })
+	for _, entry := range existing {
+		if entry.index <= len(entries) {
+			continue
+		}
+		if _, err := p.at.Run("AT+CPOL=" + strconv.Itoa(entry.index)); err != nil {
+			return fmt.Errorf("deleting entry %d: %w", entry.index, err)
+		}
+	}
+	return nil
+}
+
+// size returns how many networks the SIM can hold.
+func (p *PLMN) size() (int, error) {
+	response, err := p.at.Run("AT+CPOL=?")
+	if err != nil {
+		return 0, err
+	}
+	for line := range strings.SplitSeq(response, "\n") {
+		if match := rangeRE.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			return strconv.Atoi(match[2])
+		}
+	}
+	return 0, fmt.Errorf("unexpected response: %s", response)
+}
+
+func bit(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
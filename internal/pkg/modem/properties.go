@@ -0,0 +1,257 @@
+package modem
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var (
+	ErrMissingProperty = errors.New("missing property")
+	ErrInvalidProperty = errors.New("invalid property")
+)
+
+// properties are the D-Bus properties of an object, as returned by GetAll or
+// GetManagedObjects. They are decoded with property and optionalProperty, which
+// return errors instead of panicking on whatever ModemManager sends.
+type properties map[string]dbus.Variant
+
+// getProperties reads every property of iface on object.
+func getProperties(object dbus.BusObject, iface string) (properties, error) {
+	var props map[string]dbus.Variant
+	if err := object.Call("org.freedesktop.DBus.Properties.GetAll", 0, iface).Store(&props); err != nil {
+		return nil, fmt.Errorf("reading %s properties of %s: %w", iface, object.Path(), err)
+	}
+	return props, nil
+}
+
+// getProperty reads the property iface.name on object.
+func getProperty[T any](object dbus.BusObject, iface, name string) (T, error) {
+	var zero T
+	variant, err := object.GetProperty(iface + "." + name)
+	if err != nil {
+		return zero, fmt.Errorf("reading property %s of %s: %w", name, object.Path(), err)
+	}
+	return decodeVariant[T](name, variant)
+}
+
+// property returns the required property name.
+func property[T any](props properties, name string) (T, error) {
+	variant, ok := props[name]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w %s", ErrMissingProperty, name)
+	}
+	return decodeVariant[T](name, variant)
+}
+
+// optionalProperty returns the property name, or the zero value if it is absent.
+func optionalProperty[T any](props properties, name string) (T, error) {
+	variant, ok := props[name]
+	if !ok {
+		var zero T
+		return zero, nil
+	}
+	return decodeVariant[T](name, variant)
+}
+
+func decodeVariant[T any](name string, variant dbus.Variant) (T, error) {
+	value, ok := variant.Value().(T)
+	if !ok {
+		return value, fmt.Errorf("%w %s: got %s, want %T", ErrInvalidProperty, name, variant.Signature(), value)
+	}
+	return value, nil
+}
+
+// decodePorts decodes the Ports property, an array of (name, type) structs.
+func decodePorts(values [][]any) ([]ModemPort, error) {
+	ports := make([]ModemPort, 0, len(values))
+	for _, value := range values {
+		if len(value) != 2 {
+			return nil, fmt.Errorf("%w Ports: got %d fields, want 2", ErrInvalidProperty, len(value))
+		}
+		name, ok := value[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w Ports: port name is %T", ErrInvalidProperty, value[0])
+		}
+		portType, ok := value[1].(uint32)
+		if !ok {
+			return nil, fmt.Errorf("%w Ports: port type is %T", ErrInvalidProperty, value[1])
+		}
+		ports = append(ports, ModemPort{PortType: ModemPortType(portType), Device: "/dev/" + name})
+	}
+	return ports, nil
+}
+
+// decodeSignalQuality decodes the SignalQuality property, a (percent, recent) struct.
+func decodeSignalQuality(values []any) (percent uint32, recent bool, err error) {
+	if len(values) != 2 {
+		return 0, false, fmt.Errorf("%w SignalQuality: got %d fields, want 2", ErrInvalidProperty, len(values))
+	}
+	percent, ok := values[0].(uint32)
+	if !ok {
+		return 0, false, fmt.Errorf("%w SignalQuality: percent is %T", ErrInvalidProperty, values[0])
+	}
+	recent, ok = values[1].(bool)
+	if !ok {
+		return 0, false, fmt.Errorf("%w SignalQuality: recent is %T", ErrInvalidProperty, values[1])
+	}
+	return percent, recent, nil
+}
+
+// decodeModem decodes the properties of the Modem interface into modem. Only the
+// properties sigmo cannot work without are required; the SIM is left to the caller.
+func decodeModem(modem *Modem, props properties) error {
+	var err error
+	if modem.Device, err = property[string](props, "Device"); err != nil {
+		return err
+	}
+	if modem.EquipmentIdentifier, err = property[string](props, "EquipmentIdentifier"); err != nil {
+		return err
+	}
+	state, err := property[int32](props, "State")
+	if err != nil {
+		return err
+	}
+	modem.State = ModemState(state)
+	reason, err := optionalProperty[uint32](props, "StateFailedReason")
+	if err != nil {
+		return err
+	}
+	modem.StateFailedReason = ModemStateFailedReason(reason)
+	if modem.Manufacturer, err = optionalProperty[string](props, "Manufacturer"); err != nil {
+		return err
+	}
+	if modem.Model, err = optionalProperty[string](props, "Model"); err != nil {
+		return err
+	}
+	if modem.FirmwareRevision, err = optionalProperty[string](props, "Revision"); err != nil {
+		return err
+	}
+	if modem.HardwareRevision, err = optionalProperty[string](props, "HardwareRevision"); err != nil {
+		return err
+	}
+	if modem.PrimarySimSlot, err = optionalProperty[uint32](props, "PrimarySimSlot"); err != nil {
+		return err
+	}
+	drivers, err := optionalProperty[[]string](props, "Drivers")
+	if err != nil {
+		return err
+	}
+	if len(drivers) > 0 {
+		modem.Driver = drivers[0]
+	}
+	primaryPort, err := optionalProperty[string](props, "PrimaryPort")
+	if err != nil {
+		return err
+	}
+	if primaryPort != "" {
+		modem.PrimaryPort = "/dev/" + primaryPort
+	}
+	numbers, err := optionalProperty[[]string](props, "OwnNumbers")
+	if err != nil {
+		return err
+	}
+	if len(numbers) > 0 {
+		modem.Number = numbers[0]
+	}
+	ports, err := optionalProperty[[][]any](props, "Ports")
+	if err != nil {
+		return err
+	}
+	if modem.Ports, err = decodePorts(ports); err != nil {
+		return err
+	}
+	slots, err := optionalProperty[[]dbus.ObjectPath](props, "SimSlots")
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if slot != "/" {
+			modem.SimSlots = append(modem.SimSlots, slot)
+		}
+	}
+	return nil
+}
+
+// decodeSIM decodes the properties of the Sim interface.
+func decodeSIM(path dbus.ObjectPath, props properties) (*SIM, error) {
+	sim := &SIM{Path: path}
+	var err error
+	if sim.Active, err = optionalProperty[bool](props, "Active"); err != nil {
+		return nil, err
+	}
+	if sim.Identifier, err = property[string](props, "SimIdentifier"); err != nil {
+		return nil, err
+	}
+	if sim.Eid, err = optionalProperty[string](props, "Eid"); err != nil {
+		return nil, err
+	}
+	if sim.Imsi, err = optionalProperty[string](props, "Imsi"); err != nil {
+		return nil, err
+	}
+	if sim.OperatorIdentifier, err = optionalProperty[string](props, "OperatorIdentifier"); err != nil {
+		return nil, err
+	}
+	if sim.OperatorName, err = optionalProperty[string](props, "OperatorName"); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// decodeSMS decodes the properties of the Sms interface.
+func decodeSMS(path dbus.ObjectPath, props properties) (*SMS, error) {
+	sms := &SMS{objectPath: path}
+	state, err := property[uint32](props, "State")
+	if err != nil {
+		return nil, err
+	}
+	sms.State = SMSState(state)
+	if sms.Number, err = optionalProperty[string](props, "Number"); err != nil {
+		return nil, err
+	}
+	if sms.Text, err = optionalProperty[string](props, "Text"); err != nil {
+		return nil, err
+	}
+	timestamp, err := optionalProperty[string](props, "Timestamp")
+	if err != nil {
+		return nil, err
+	}
+	if timestamp != "" {
+		// ModemManager may omit the minutes of the UTC offset, e.g. "+08".
+		if len(timestamp) >= 3 && (timestamp[len(timestamp)-3] == '+' || timestamp[len(timestamp)-3] == '-') {
+			timestamp += ":00"
+		}
+		if sms.Timestamp, err = time.Parse(time.RFC3339, timestamp); err != nil {
+			return nil, fmt.Errorf("%w Timestamp: %w", ErrInvalidProperty, err)
+		}
+	}
+	return sms, nil
+}
+
+// decodeNetwork decodes a network returned by a 3GPP scan.
+func decodeNetwork(props properties) (*ThreeGPPNetwork, error) {
+	var network ThreeGPPNetwork
+	status, err := property[uint32](props, "status")
+	if err != nil {
+		return nil, err
+	}
+	network.Status = Modem3gppNetworkAvailability(status)
+	if network.OperatorCode, err = property[string](props, "operator-code"); err != nil {
+		return nil, err
+	}
+	if network.OperatorName, err = optionalProperty[string](props, "operator-long"); err != nil {
+		return nil, err
+	}
+	if network.OperatorShortName, err = optionalProperty[string](props, "operator-short"); err != nil {
+		return nil, err
+	}
+	access, err := optionalProperty[uint32](props, "access-technology")
+	if err != nil {
+		return nil, err
+	}
+	network.AccessTechnology = ModemAccessTechnology(access).UnmarshalBitmask(access)
+	return &network, nil
+}
@@ -0,0 +1,439 @@
+package modem
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestDecodeModem(t *testing.T) {
+	required := properties{
+		"Device":              dbus.MakeVariant("/sys/devices/usb1/1-1"),
+		"EquipmentIdentifier": dbus.MakeVariant("861234567890123"),
+		"State":               dbus.MakeVariant(int32(ModemStateRegistered)),
+	}
+	tests := []struct {
+		name    string
+		props   properties
+		want    Modem
+		wantErr error
+	}{
+		{
+			name:  "required only",
+			props: required,
+			want: Modem{
+				Device:              "/sys/devices/usb1/1-1",
+				EquipmentIdentifier: "861234567890123",
+				State:               ModemStateRegistered,
+				Ports:               []ModemPort{},
+			},
+		},
+		{
+			name: "all",
+			props: with(required, properties{
+				"Manufacturer":      dbus.MakeVariant("Quectel"),
+				"Model":             dbus.MakeVariant("EC25"),
+				"Revision":          dbus.MakeVariant("EC25EFAR06A06M4G"),
+				"HardwareRevision":  dbus.MakeVariant("10000"),
+				"PrimarySimSlot":    dbus.MakeVariant(uint32(1)),
+				"StateFailedReason": dbus.MakeVariant(uint32(ModemStateFailedReasonSimMissing)),
+				"Drivers":           dbus.MakeVariant([]string{"qmi_wwan", "option"}),
+				"PrimaryPort":       dbus.MakeVariant("cdc-wdm0"),
+				"OwnNumbers":        dbus.MakeVariant([]string{"+8613800000000"}),
+				"Ports": dbus.MakeVariant([][]any{
+					{"cdc-wdm0", uint32(ModemPortTypeQmi)},
+					{"ttyUSB2", uint32(ModemPortTypeAt)},
+				}),
+				"SimSlots": dbus.MakeVariant([]dbus.ObjectPath{"/org/freedesktop/ModemManager1/SIM/0", "/"}),
+			}),
+			want: Modem{
+				Device:              "/sys/devices/usb1/1-1",
+				EquipmentIdentifier: "861234567890123",
+				State:               ModemStateRegistered,
+				StateFailedReason:   ModemStateFailedReasonSimMissing,
+				Manufacturer:        "Quectel",
+				Model:               "EC25",
+				FirmwareRevision:    "EC25EFAR06A06M4G",
+				HardwareRevision:    "10000",
+				PrimarySimSlot:      1,
+				Driver:              "qmi_wwan",
+				PrimaryPort:         "/dev/cdc-wdm0",
+				Number:              "+8613800000000",
+				Ports: []ModemPort{
+					{PortType: ModemPortTypeQmi, Device: "/dev/cdc-wdm0"},
+					{PortType: ModemPortTypeAt, Device: "/dev/ttyUSB2"},
+				},
+				SimSlots: []dbus.ObjectPath{"/org/freedesktop/ModemManager1/SIM/0"},
+			},
+		},
+		{
+			name:    "missing device",
+			props:   without(required, "Device"),
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name:    "missing equipment identifier",
+			props:   without(required, "EquipmentIdentifier"),
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name:    "missing state",
+			props:   without(required, "State"),
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name:    "state of the wrong type",
+			props:   with(required, properties{"State": dbus.MakeVariant(uint32(ModemStateRegistered))}),
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "optional property of the wrong type",
+			props:   with(required, properties{"Model": dbus.MakeVariant(int32(25))}),
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "invalid port",
+			props:   with(required, properties{"Ports": dbus.MakeVariant([][]any{{"ttyUSB2"}})}),
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Modem
+			err := decodeModem(&got, tt.props)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeModem() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeModem() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSIM(t *testing.T) {
+	const path = dbus.ObjectPath("/org/freedesktop/ModemManager1/SIM/0")
+	tests := []struct {
+		name    string
+		props   properties
+		want    *SIM
+		wantErr error
+	}{
+		{
+			name: "all",
+			props: properties{
+				"Active":             dbus.MakeVariant(true),
+				"SimIdentifier":      dbus.MakeVariant("8986000000000000001"),
+				"Eid":                dbus.MakeVariant("89049032000000000000000000000001"),
+				"Imsi":               dbus.MakeVariant("460001234567890"),
+				"OperatorIdentifier": dbus.MakeVariant("46000"),
+				"OperatorName":       dbus.MakeVariant("CMCC"),
+			},
+			want: &SIM{
+				Path:               path,
+				Active:             true,
+				Identifier:         "8986000000000000001",
+				Eid:                "89049032000000000000000000000001",
+				Imsi:               "460001234567890",
+				OperatorIdentifier: "46000",
+				OperatorName:       "CMCC",
+			},
+		},
+		{
+			name:  "identifier only",
+			props: properties{"SimIdentifier": dbus.MakeVariant("8986000000000000001")},
+			want:  &SIM{Path: path, Identifier: "8986000000000000001"},
+		},
+		{
+			name:    "missing identifier",
+			props:   properties{"Imsi": dbus.MakeVariant("460001234567890")},
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name: "active of the wrong type",
+			props: properties{
+				"SimIdentifier": dbus.MakeVariant("8986000000000000001"),
+				"Active":        dbus.MakeVariant(uint32(1)),
+			},
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSIM(path, tt.props)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeSIM() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeSIM() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSMS(t *testing.T) {
+	const path = dbus.ObjectPath("/org/freedesktop/ModemManager1/SMS/3")
+	received := properties{
+		"State":  dbus.MakeVariant(uint32(SMSStateReceived)),
+		"Number": dbus.MakeVariant("10086"),
+		"Text":   dbus.MakeVariant("hello"),
+	}
+	tests := []struct {
+		name    string
+		props   properties
+		want    *SMS
+		wantErr error
+	}{
+		{
+			name:  "without timestamp",
+			props: received,
+			want:  &SMS{objectPath: path, State: SMSStateReceived, Number: "10086", Text: "hello"},
+		},
+		{
+			name:  "full offset",
+			props: with(received, properties{"Timestamp": dbus.MakeVariant("2026-03-02T13:37:00+08:00")}),
+			want: &SMS{
+				objectPath: path,
+				State:      SMSStateReceived,
+				Number:     "10086",
+				Text:       "hello",
+				Timestamp:  time.Date(2026, 3, 2, 5, 37, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "offset in hours",
+			props: with(received, properties{"Timestamp": dbus.MakeVariant("2026-03-02T13:37:00+08")}),
+			want: &SMS{
+				objectPath: path,
+				State:      SMSStateReceived,
+				Number:     "10086",
+				Text:       "hello",
+				Timestamp:  time.Date(2026, 3, 2, 5, 37, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "negative offset in hours",
+			props: with(received, properties{"Timestamp": dbus.MakeVariant("2026-03-02T13:37:00-05")}),
+			want: &SMS{
+				objectPath: path,
+				State:      SMSStateReceived,
+				Number:     "10086",
+				Text:       "hello",
+				Timestamp:  time.Date(2026, 3, 2, 18, 37, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "state only",
+			props: properties{"State": dbus.MakeVariant(uint32(SMSStateReceived))},
+			want:  &SMS{objectPath: path, State: SMSStateReceived},
+		},
+		{
+			name:    "missing state",
+			props:   without(received, "State"),
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name:    "invalid timestamp",
+			props:   with(received, properties{"Timestamp": dbus.MakeVariant("yesterday")}),
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "text of the wrong type",
+			props:   with(received, properties{"Text": dbus.MakeVariant([]byte("hello"))}),
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSMS(path, tt.props)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeSMS() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("decodeSMS() Timestamp = %s, want %s", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeSMS() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		props   properties
+		want    *ThreeGPPNetwork
+		wantErr error
+	}{
+		{
+			name: "all",
+			props: properties{
+				"status":            dbus.MakeVariant(uint32(Modem3gppNetworkAvailabilityAvailable)),
+				"operator-code":     dbus.MakeVariant("46001"),
+				"operator-long":     dbus.MakeVariant("China Unicom"),
+				"operator-short":    dbus.MakeVariant("CU"),
+				"access-technology": dbus.MakeVariant(uint32(ModemAccessTechnologyLte)),
+			},
+			want: &ThreeGPPNetwork{
+				Status:            Modem3gppNetworkAvailabilityAvailable,
+				OperatorCode:      "46001",
+				OperatorName:      "China Unicom",
+				OperatorShortName: "CU",
+				AccessTechnology:  []ModemAccessTechnology{ModemAccessTechnologyLte},
+			},
+		},
+		{
+			name: "required only",
+			props: properties{
+				"status":        dbus.MakeVariant(uint32(Modem3gppNetworkAvailabilityAvailable)),
+				"operator-code": dbus.MakeVariant("46001"),
+			},
+			want: &ThreeGPPNetwork{Status: Modem3gppNetworkAvailabilityAvailable, OperatorCode: "46001"},
+		},
+		{
+			name:    "missing status",
+			props:   properties{"operator-code": dbus.MakeVariant("46001")},
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name:    "missing operator code",
+			props:   properties{"status": dbus.MakeVariant(uint32(Modem3gppNetworkAvailabilityAvailable))},
+			wantErr: ErrMissingProperty,
+		},
+		{
+			name: "operator code of the wrong type",
+			props: properties{
+				"status":        dbus.MakeVariant(uint32(Modem3gppNetworkAvailabilityAvailable)),
+				"operator-code": dbus.MakeVariant(uint32(46001)),
+			},
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeNetwork(tt.props)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeNetwork() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeNetwork() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  [][]any
+		want    []ModemPort
+		wantErr error
+	}{
+		{
+			name:   "ports",
+			values: [][]any{{"ttyUSB2", uint32(ModemPortTypeAt)}, {"cdc-wdm0", uint32(ModemPortTypeQmi)}},
+			want:   []ModemPort{{PortType: ModemPortTypeAt, Device: "/dev/ttyUSB2"}, {PortType: ModemPortTypeQmi, Device: "/dev/cdc-wdm0"}},
+		},
+		{
+			name: "none",
+			want: []ModemPort{},
+		},
+		{
+			name:    "too few fields",
+			values:  [][]any{{"ttyUSB2"}},
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "name of the wrong type",
+			values:  [][]any{{uint32(2), uint32(ModemPortTypeAt)}},
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "type of the wrong type",
+			values:  [][]any{{"ttyUSB2", int32(ModemPortTypeAt)}},
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePorts(tt.values)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodePorts() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodePorts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSignalQuality(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []any
+		wantPercent uint32
+		wantRecent  bool
+		wantErr     error
+	}{
+		{
+			name:        "recent",
+			values:      []any{uint32(75), true},
+			wantPercent: 75,
+			wantRecent:  true,
+		},
+		{
+			name:        "cached",
+			values:      []any{uint32(20), false},
+			wantPercent: 20,
+		},
+		{
+			name:    "too few fields",
+			values:  []any{uint32(75)},
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "percent of the wrong type",
+			values:  []any{int32(75), true},
+			wantErr: ErrInvalidProperty,
+		},
+		{
+			name:    "recent of the wrong type",
+			values:  []any{uint32(75), uint32(1)},
+			wantErr: ErrInvalidProperty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percent, recent, err := decodeSignalQuality(tt.values)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeSignalQuality() error = %v, want %v", err, tt.wantErr)
+			}
+			if percent != tt.wantPercent || recent != tt.wantRecent {
+				t.Errorf("decodeSignalQuality() = %d, %v, want %d, %v", percent, recent, tt.wantPercent, tt.wantRecent)
+			}
+		})
+	}
+}
+
+// with returns a copy of props with extra added or replaced.
+func with(props, extra properties) properties {
+	merged := maps.Clone(props)
+	maps.Copy(merged, extra)
+	return merged
+}
+
+// without returns a copy of props without name.
+func without(props properties, name string) properties {
+	merged := with(props, nil)
+	delete(merged, name)
+	return merged
+}
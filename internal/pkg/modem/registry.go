@@ -0,0 +1,175 @@
+package modem
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownModem   = errors.New("modem has never been connected")
+	ErrModemConnected = errors.New("modem is connected")
+)
+
+// KnownModem is a modem that has been connected at least once.
+type KnownModem struct {
+	EquipmentIdentifier string    `json:"equipmentIdentifier"`
+	Manufacturer        string    `json:"manufacturer"`
+	Model               string    `json:"model"`
+	FirstSeen           time.Time `json:"firstSeen"`
+	// LastSeen is when the modem was last connected or disconnected.
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Registry remembers every modem the manager has seen in a JSON file, so that
+// modems that are unplugged or stuck can still be listed.
+type Registry struct {
+	manager     *Manager
+	path        string
+	unsubscribe func()
+
+	mu    sync.Mutex
+	known map[string]KnownModem
+}
+
+// NewRegistry loads the registry at path and keeps it up to date with the manager's modems.
+func NewRegistry(manager *Manager, path string) (*Registry, error) {
+	r := &Registry{
+		manager: manager,
+		path:    path,
+		known:   make(map[string]KnownModem),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading modem registry: %w", err)
+	}
+	if err == nil {
+		var known []KnownModem
+		if err := json.Unmarshal(data, &known); err != nil {
+			return nil, fmt.Errorf("parsing modem registry: %w", err)
+		}
+		for _, modem := range known {
+			r.known[modem.EquipmentIdentifier] = modem
+		}
+	}
+
+	r.unsubscribe, err = manager.Subscribe(func(event ModemEvent) error {
+		if event.Modem == nil || (event.Type != ModemEventAdded && event.Type != ModemEventRemoved) {
+			return nil
+		}
+		return r.seen(event.Modem)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("subscribing to modem manager: %w", err)
+	}
+	modems, err := manager.Modems()
+	if err != nil {
+		r.unsubscribe()
+		return nil, fmt.Errorf("listing modems: %w", err)
+	}
+	for _, modem := range modems {
+		if err := r.seen(modem); err != nil {
+			slog.Warn("failed to record modem", "modem", modem.EquipmentIdentifier, "error", err)
+		}
+	}
+	return r, nil
+}
+
+// Close stops following the manager.
+func (r *Registry) Close() {
+	r.unsubscribe()
+}
+
+// Known returns every modem seen so far, sorted by equipment identifier.
+func (r *Registry) Known() []KnownModem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sortedLocked()
+}
+
+// Find returns the modem with the given equipment identifier.
+func (r *Registry) Find(id string) (KnownModem, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modem, ok := r.known[id]
+	return modem, ok
+}
+
+// Forget removes a disconnected modem from the registry.
+func (r *Registry) Forget(id string) error {
+	modems, err := r.manager.Modems()
+	if err != nil {
+		return err
+	}
+	for _, modem := range modems {
+		if modem.EquipmentIdentifier == id {
+			return ErrModemConnected
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.known[id]
+	if !ok {
+		return ErrUnknownModem
+	}
+	delete(r.known, id)
+	if err := r.saveLocked(); err != nil {
+		r.known[id] = previous
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) seen(modem *Modem) error {
+	if modem.EquipmentIdentifier == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	known, ok := r.known[modem.EquipmentIdentifier]
+	if !ok {
+		known = KnownModem{EquipmentIdentifier: modem.EquipmentIdentifier, FirstSeen: now}
+	}
+	known.Manufacturer = modem.Manufacturer
+	known.Model = modem.Model
+	known.LastSeen = now
+	r.known[modem.EquipmentIdentifier] = known
+	return r.saveLocked()
+}
+
+func (r *Registry) sortedLocked() []KnownModem {
+	known := make([]KnownModem, 0, len(r.known))
+	for _, modem := range r.known {
+		known = append(known, modem)
+	}
+	slices.SortFunc(known, func(a, b KnownModem) int {
+		return cmp.Compare(a.EquipmentIdentifier, b.EquipmentIdentifier)
+	})
+	return known
+}
+
+func (r *Registry) saveLocked() error {
+	data, err := json.MarshalIndent(r.sortedLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding modem registry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("creating modem registry directory: %w", err)
+	}
+	temp := r.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return fmt.Errorf("writing modem registry: %w", err)
+	}
+	if err := os.Rename(temp, r.path); err != nil {
+		return fmt.Errorf("writing modem registry: %w", err)
+	}
+	return nil
+}
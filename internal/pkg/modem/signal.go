@@ -0,0 +1,110 @@
+package modem
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const ModemSignalInterface = ModemInterface + ".Signal"
+
+// Signal reads detailed signal measurements. ModemManager only takes them after
+// Setup was called with a non-zero refresh rate.
+type Signal struct {
+	modem *Modem
+}
+
+func (m *Modem) Signal() *Signal {
+	return &Signal{modem: m}
+}
+
+// SignalMetrics are the latest measurements of each access technology.
+// A technology the modem is not using is nil.
+type SignalMetrics struct {
+	GSM  *SignalMeasurement
+	UMTS *SignalMeasurement
+	LTE  *SignalMeasurement
+	NR5G *SignalMeasurement
+}
+
+// SignalMeasurement holds the values reported for one access technology. Values the
+// technology does not have or the modem does not report are nil. Powers are in dBm,
+// RSRQ, SINR and Ec/Io in dB, and the error rate in percent.
+type SignalMeasurement struct {
+	RSSI      *float64
+	RSRP      *float64
+	RSRQ      *float64
+	SINR      *float64
+	RSCP      *float64
+	ECIO      *float64
+	ErrorRate *float64
+}
+
+// Setup makes the modem refresh the measurements every rate seconds. A rate of 0 stops it.
+func (s *Signal) Setup(rate uint32) error {
+	return s.modem.dbusObject.Call(ModemSignalInterface+".Setup", 0, rate).Err
+}
+
+// Rate returns the refresh rate in seconds, 0 if measurements are not taken.
+func (s *Signal) Rate() (uint32, error) {
+	return getProperty[uint32](s.modem.dbusObject, ModemSignalInterface, "Rate")
+}
+
+func (s *Signal) Metrics() (*SignalMetrics, error) {
+	props, err := getProperties(s.modem.dbusObject, ModemSignalInterface)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := decodeSignalMetrics(props)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signal of %s: %w", s.modem.objectPath, err)
+	}
+	return metrics, nil
+}
+
+func decodeSignalMetrics(props properties) (*SignalMetrics, error) {
+	var metrics SignalMetrics
+	var err error
+	for name, measurement := range map[string]**SignalMeasurement{
+		"Gsm":  &metrics.GSM,
+		"Umts": &metrics.UMTS,
+		"Lte":  &metrics.LTE,
+		"Nr5g": &metrics.NR5G,
+	} {
+		if *measurement, err = decodeSignalMeasurement(props, name); err != nil {
+			return nil, err
+		}
+	}
+	return &metrics, nil
+}
+
+func decodeSignalMeasurement(props properties, name string) (*SignalMeasurement, error) {
+	values, err := optionalProperty[map[string]dbus.Variant](props, name)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+	var measurement SignalMeasurement
+	for key, value := range map[string]**float64{
+		"rssi":       &measurement.RSSI,
+		"rsrp":       &measurement.RSRP,
+		"rsrq":       &measurement.RSRQ,
+		"snr":        &measurement.SINR,
+		"rscp":       &measurement.RSCP,
+		"ecio":       &measurement.ECIO,
+		"error-rate": &measurement.ErrorRate,
+	} {
+		v, ok := values[key]
+		if !ok {
+			continue
+		}
+		f, err := decodeVariant[float64](name+"."+key, v)
+		if err != nil {
+			return nil, err
+		}
+		*value = &f
+	}
+	if measurement == (SignalMeasurement{}) {
+		return nil, nil
+	}
+	return &measurement, nil
+}
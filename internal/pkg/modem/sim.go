@@ -2,6 +2,7 @@ package modem
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -34,48 +35,17 @@ func (s *SIMs) Primary() (*SIM, error) {
 }
 
 func (sims *SIMs) Get(path dbus.ObjectPath) (*SIM, error) {
-	var variant dbus.Variant
-	var err error
-	sim := &SIM{Path: path}
 	dbusObject, err := systemBusObject(path)
 	if err != nil {
 		return nil, err
 	}
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".Active")
-	if err != nil {
-		return nil, err
-	}
-	sim.Active = variant.Value().(bool)
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".SimIdentifier")
+	props, err := getProperties(dbusObject, ModemSimInterface)
 	if err != nil {
 		return nil, err
 	}
-	sim.Identifier = variant.Value().(string)
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".Eid")
-	if err != nil {
-		return nil, err
-	}
-	sim.Eid = variant.Value().(string)
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".Imsi")
+	sim, err := decodeSIM(path, props)
 	if err != nil {
-		return nil, err
-	}
-	sim.Imsi = variant.Value().(string)
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".OperatorIdentifier")
-	if err != nil {
-		return nil, err
-	}
-	sim.OperatorIdentifier = variant.Value().(string)
-
-	variant, err = dbusObject.GetProperty(ModemSimInterface + ".OperatorName")
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("decoding SIM %s: %w", path, err)
 	}
-	sim.OperatorName = variant.Value().(string)
 	return sim, nil
 }
@@ -0,0 +1,51 @@
+package simfile
+
+import "github.com/damonto/sigmo/internal/pkg/modem/at"
+
+type CRSM struct {
+	commander at.ATCommand
+}
+
+func NewCRSM(conn *at.AT) Runner {
+	return &CRSM{commander: at.NewCRSM(conn)}
+}
+
+func (r *CRSM) Select(fileID uint16) ([]byte, error) {
+	command := at.CRSMCommand{Instruction: at.CRSMGetResponse, FileID: fileID}
+	return r.commander.Run(command.Bytes())
+}
+
+func (r *CRSM) ReadBinary(fileID uint16, offset uint16, length byte) ([]byte, error) {
+	command := at.CRSMCommand{
+		Instruction: at.CRSMReadBinary,
+		FileID:      fileID,
+		P1:          byte(offset >> 8),
+		P2:          byte(offset),
+		Length:      length,
+	}
+	return r.commander.Run(command.Bytes())
+}
+
+func (r *CRSM) UpdateBinary(fileID uint16, offset uint16, data []byte) error {
+	command := at.CRSMCommand{
+		Instruction: at.CRSMUpdateBinary,
+		FileID:      fileID,
+		P1:          byte(offset >> 8),
+		P2:          byte(offset),
+		Data:        data,
+	}
+	_, err := r.commander.Run(command.Bytes())
+	return err
+}
+
+func (r *CRSM) UpdateRecord(fileID uint16, record byte, data []byte) error {
+	command := at.CRSMCommand{
+		Instruction: at.CRSMUpdateRecord,
+		FileID:      fileID,
+		P1:          record,
+		P2:          4,
+		Data:        data,
+	}
+	_, err := r.commander.Run(command.Bytes())
+	return err
+}
@@ -0,0 +1,31 @@
+package simfile
+
+import "github.com/damonto/sigmo/internal/pkg/modem/at"
+
+// CSIM sends APDUs to the file selected last, so callers select the file first.
+type CSIM struct {
+	commander at.ATCommand
+}
+
+func NewCSIM(conn *at.AT) Runner {
+	return &CSIM{commander: at.NewCSIM(conn)}
+}
+
+// Select selects the file by its path from the MF, where 7FFF is the current ADF.
+func (r *CSIM) Select(fileID uint16) ([]byte, error) {
+	return r.commander.Run([]byte{0x00, 0xA4, 0x08, 0x04, 0x04, 0x7F, 0xFF, byte(fileID >> 8), byte(fileID)})
+}
+
+func (r *CSIM) ReadBinary(_ uint16, offset uint16, length byte) ([]byte, error) {
+	return r.commander.Run([]byte{0x00, 0xB0, byte(offset >> 8), byte(offset), length})
+}
+
+func (r *CSIM) UpdateBinary(_ uint16, offset uint16, data []byte) error {
+	_, err := r.commander.Run(append([]byte{0x00, 0xD6, byte(offset >> 8), byte(offset), byte(len(data))}, data...))
+	return err
+}
+
+func (r *CSIM) UpdateRecord(_ uint16, record byte, data []byte) error {
+	_, err := r.commander.Run(append([]byte{0x00, 0xDC, record, 0x04, byte(len(data))}, data...))
+	return err
+}
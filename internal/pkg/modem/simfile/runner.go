@@ -0,0 +1,13 @@
+package simfile
+
+// Runner sends file commands to the USIM application, through AT+CRSM or AT+CSIM.
+type Runner interface {
+	// Select selects an elementary file and returns its FCP template.
+	Select(fileID uint16) ([]byte, error)
+	// ReadBinary reads length bytes at offset of the selected transparent file.
+	ReadBinary(fileID uint16, offset uint16, length byte) ([]byte, error)
+	// UpdateBinary writes data at offset of the selected transparent file.
+	UpdateBinary(fileID uint16, offset uint16, data []byte) error
+	// UpdateRecord writes data to a record of the selected linear fixed file.
+	UpdateRecord(fileID uint16, record byte, data []byte) error
+}
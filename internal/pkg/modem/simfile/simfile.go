@@ -0,0 +1,123 @@
+// Package simfile reads and writes elementary files of the USIM application
+// through the AT port, with AT+CRSM where the modem supports it and AT+CSIM otherwise.
+package simfile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/damonto/sigmo/internal/pkg/modem/at"
+)
+
+// Elementary files of the USIM application.
+const (
+	EFMSISDN uint16 = 0x6F40
+	EFFPLMN  uint16 = 0x6F7B
+)
+
+// chunk is the most data a single READ BINARY or UPDATE BINARY carries.
+const chunk = 0xFF
+
+var errUnsupported = errors.New("modem does not support SIM file access")
+
+type Files struct {
+	runner Runner
+}
+
+// New returns the files of the SIM behind conn. The caller keeps ownership of conn.
+func New(conn *at.AT) (*Files, error) {
+	if conn.Support("AT+CRSM=?") {
+		return &Files{runner: NewCRSM(conn)}, nil
+	}
+	if conn.Support("AT+CSIM=?") {
+		return &Files{runner: NewCSIM(conn)}, nil
+	}
+	return nil, errUnsupported
+}
+
+// ReadBinary reads the whole content of a transparent file.
+func (f *Files) ReadBinary(fileID uint16) ([]byte, error) {
+	size, err := f.FileSize(fileID)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, size)
+	for offset := 0; offset < size; offset += chunk {
+		b, err := f.runner.ReadBinary(fileID, uint16(offset), byte(min(size-offset, chunk)))
+		if err != nil {
+			return nil, fmt.Errorf("reading %04X: %w", fileID, err)
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+// UpdateBinary writes data from the start of a transparent file.
+func (f *Files) UpdateBinary(fileID uint16, data []byte) error {
+	size, err := f.FileSize(fileID)
+	if err != nil {
+		return err
+	}
+	if len(data) > size {
+		return fmt.Errorf("%04X holds %d bytes, got %d", fileID, size, len(data))
+	}
+	for offset := 0; offset < len(data); offset += chunk {
+		if err := f.runner.UpdateBinary(fileID, uint16(offset), data[offset:min(offset+chunk, len(data))]); err != nil {
+			return fmt.Errorf("updating %04X: %w", fileID, err)
+		}
+	}
+	return nil
+}
+
+// UpdateRecord writes data to a record of a linear fixed file. Records are numbered from 1.
+func (f *Files) UpdateRecord(fileID uint16, record byte, data []byte) error {
+	if _, err := f.runner.Select(fileID); err != nil {
+		return err
+	}
+	return f.runner.UpdateRecord(fileID, record, data)
+}
+
+// FileSize selects a transparent file and returns its size in bytes.
+func (f *Files) FileSize(fileID uint16) (int, error) {
+	fcp, err := f.runner.Select(fileID)
+	if err != nil {
+		return 0, err
+	}
+	data := findTag(fcp, 0x80)
+	if len(data) < 4 {
+		return 0, fmt.Errorf("unexpected response: %X", fcp)
+	}
+	return int(data[2])<<8 + int(data[3]), nil
+}
+
+// RecordLength selects a linear fixed file and returns the length of its records.
+func (f *Files) RecordLength(fileID uint16) (int, error) {
+	fcp, err := f.runner.Select(fileID)
+	if err != nil {
+		return 0, err
+	}
+	data := findTag(fcp, 0x82)
+	if len(data) < 6 {
+		return 0, fmt.Errorf("unexpected response: %X", fcp)
+	}
+	return int(data[4])<<8 + int(data[5]), nil
+}
+
+// findTag returns the TLV with tag, including its tag and length, from an FCP template.
+func findTag(bs []byte, tag byte) []byte {
+	if len(bs) < 2 {
+		return nil
+	}
+	bs = bs[2:]
+	for len(bs) >= 2 {
+		n := int(bs[1])
+		if len(bs) < 2+n {
+			return nil
+		}
+		if bs[0] == tag {
+			return bs[:2+n]
+		}
+		bs = bs[2+n:]
+	}
+	return nil
+}
@@ -1,13 +1,22 @@
 package modem
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/godbus/dbus/v5"
+
+	"github.com/damonto/sigmo/internal/pkg/metrics"
 )
 
 const ModemSMSInterface = ModemManagerInterface + ".Sms"
 
+var (
+	smsReceived = metrics.NewCounter("sigmo_sms_received_total", "SMS messages received.", "modem")
+	smsSent     = metrics.NewCounter("sigmo_sms_sent_total", "SMS messages sent.", "modem")
+	smsFailed   = metrics.NewCounter("sigmo_sms_failed_total", "SMS messages that could not be sent.", "modem")
+)
+
 type SMS struct {
 	objectPath dbus.ObjectPath
 	State      SMSState
@@ -25,42 +34,28 @@ func (msg *Messaging) Retrieve(objectPath dbus.ObjectPath) (*SMS, error) {
 	if err != nil {
 		return nil, err
 	}
-	sms := SMS{objectPath: objectPath}
-	variant, err := dbusObject.GetProperty(ModemSMSInterface + ".State")
+	props, err := getProperties(dbusObject, ModemSMSInterface)
 	if err != nil {
 		return nil, err
 	}
-	sms.State = SMSState(variant.Value().(uint32))
-
-	variant, err = dbusObject.GetProperty(ModemSMSInterface + ".Number")
+	sms, err := decodeSMS(objectPath, props)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("decoding message %s: %w", objectPath, err)
 	}
-	sms.Number = variant.Value().(string)
-
-	variant, err = dbusObject.GetProperty(ModemSMSInterface + ".Text")
-	if err != nil {
-		return nil, err
-	}
-	sms.Text = variant.Value().(string)
+	return sms, nil
+}
 
-	variant, err = dbusObject.GetProperty(ModemSMSInterface + ".Timestamp")
+func (msg *Messaging) Send(to string, text string) (*SMS, error) {
+	sms, err := msg.send(to, text)
 	if err != nil {
+		smsFailed.Inc(msg.modem.EquipmentIdentifier)
 		return nil, err
 	}
-	if t := variant.Value().(string); t != "" {
-		if len(t) >= 3 && (t[len(t)-3] == '+' || t[len(t)-3] == '-') {
-			t = t + ":00"
-		}
-		sms.Timestamp, err = time.Parse(time.RFC3339, t)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return &sms, nil
+	smsSent.Inc(msg.modem.EquipmentIdentifier)
+	return sms, nil
 }
 
-func (msg *Messaging) Send(to string, text string) (*SMS, error) {
+func (msg *Messaging) send(to string, text string) (*SMS, error) {
 	path, err := msg.Create(to, text)
 	if err != nil {
 		return nil, err
@@ -8,8 +8,11 @@ import (
 	"sync"
 
 	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/metrics"
 )
 
+var deliveries = metrics.NewCounter("sigmo_notifier_deliveries_total", "Notifications delivered by channel and result.", "channel", "result")
+
 type Message interface {
 	fmt.Stringer
 	Markdown() string
@@ -103,7 +106,9 @@ func (n *Notifier) Send(message Message, channels ...string) error {
 		wg.Add(1)
 		go func(target string, sender Sender) {
 			defer wg.Done()
-			if err := sender.Send(message); err != nil {
+			err := sender.Send(message)
+			deliveries.Inc(target, metrics.Result(err))
+			if err != nil {
 				mu.Lock()
 				combined = errors.Join(combined, fmt.Errorf("%s send failed: %w", target, err))
 				mu.Unlock()
@@ -0,0 +1,15 @@
+// Package randid generates short random identifiers, such as those of schedules,
+// incidents and network scans.
+package randid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// New returns 12 random hexadecimal characters.
+func New() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -8,19 +8,32 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"github.com/damonto/sigmo/internal/app/alert"
+	"github.com/damonto/sigmo/internal/app/backup"
 	"github.com/damonto/sigmo/internal/app/forwarder"
+	"github.com/damonto/sigmo/internal/app/keepalive"
+	"github.com/damonto/sigmo/internal/app/registrar"
 	"github.com/damonto/sigmo/internal/app/router"
+	"github.com/damonto/sigmo/internal/app/scheduler"
+	"github.com/damonto/sigmo/internal/app/telemetry"
+	"github.com/damonto/sigmo/internal/app/watchdog"
 	"github.com/damonto/sigmo/internal/pkg/config"
+	"github.com/damonto/sigmo/internal/pkg/lpa"
 	"github.com/damonto/sigmo/internal/pkg/modem"
 	"github.com/damonto/sigmo/internal/pkg/validator"
 )
 
+// subsystemShutdownTimeout bounds how long shutdown waits for running operations
+// to finish. It leaves room for a scheduled switch back, which may take 2 minutes.
+const subsystemShutdownTimeout = 3 * time.Minute
+
 var (
 	BuildVersion string
 	configPath   string
@@ -47,6 +60,18 @@ func main() {
 		slog.Error("unable to connect modem manager", "error", err)
 		os.Exit(1)
 	}
+	stopSessions, err := lpa.Watch(manager)
+	if err != nil {
+		slog.Error("unable to watch modems", "error", err)
+		os.Exit(1)
+	}
+	defer stopSessions()
+	registry, err := modem.NewRegistry(manager, cfg.DataPath("modems.json"))
+	if err != nil {
+		slog.Error("unable to load modem registry", "error", err)
+		os.Exit(1)
+	}
+	defer registry.Close()
 
 	server := echo.New()
 	server.HideBanner = true
@@ -61,25 +86,59 @@ func main() {
 		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodHead, http.MethodOptions},
 		AllowHeaders: []string{"*"},
 	}))
-	router.Register(server, cfg, manager)
-
+	sched, err := scheduler.New(cfg, manager)
+	if err != nil {
+		slog.Error("unable to configure scheduler", "error", err)
+		os.Exit(1)
+	}
+	keeper, err := keepalive.New(cfg, manager, sched)
+	if err != nil {
+		slog.Error("unable to configure keep-alive", "error", err)
+		os.Exit(1)
+	}
+	recorder := telemetry.New(cfg, manager)
 	relay, err := forwarder.New(cfg, manager)
 	if err != nil {
 		slog.Error("unable to configure message relay", "error", err)
 		os.Exit(1)
 	}
+	dog, err := watchdog.New(cfg, manager)
+	if err != nil {
+		slog.Error("unable to configure registration watchdog", "error", err)
+		os.Exit(1)
+	}
+	alerts, err := alert.New(cfg, manager)
+	if err != nil {
+		slog.Error("unable to configure alerts", "error", err)
+		os.Exit(1)
+	}
+	reg := registrar.New(cfg, manager)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	router.Register(ctx, server, cfg, manager, registry, sched, keeper, recorder, relay, dog, reg)
 
-	if relay.Enabled() {
-		go func() {
-			if err := relay.Run(ctx); err != nil {
-				slog.Error("message relay stopped", "error", err)
+	// Subsystems finish what they are doing once ctx is canceled, such as switching
+	// a profile back, so they are waited for after the HTTP server stops.
+	var wg sync.WaitGroup
+	run := func(name string, fn func(context.Context) error) {
+		wg.Go(func() {
+			if err := fn(ctx); err != nil {
+				slog.Error(name+" stopped", "error", err)
 				stop()
 			}
-		}()
+		})
 	}
+	if relay.Enabled() {
+		run("message relay", relay.Run)
+	}
+	run("scheduler", sched.Run)
+	run("keep-alive", keeper.Run)
+	run("signal recorder", recorder.Run)
+	run("alerts", alerts.Run)
+	run("registration watchdog", dog.Run)
+	run("network policies", reg.Run)
+	run("profile backup", backup.New(cfg, manager).Run)
 
 	go func() {
 		if err := server.Start(cfg.App.ListenAddress); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -91,8 +150,23 @@ func main() {
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	failed := false
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("http server shutdown failed", "error", err)
+		failed = true
+	}
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(subsystemShutdownTimeout):
+		slog.Error("subsystems did not stop in time")
+		failed = true
+	}
+	if failed {
 		os.Exit(1)
 	}
 }
@@ -1,10 +1,14 @@
 import { useFetch } from '@/lib/fetch'
 
-import type { NetworksResponse } from '@/types/network'
+import type { NetworkScanResponse } from '@/types/network'
 
 export const useNetworkApi = () => {
-  const scanNetworks = (id: string) => {
-    return useFetch<NetworksResponse>(`modems/${id}/networks`).get().json()
+  const startNetworkScan = (id: string) => {
+    return useFetch<NetworkScanResponse>(`modems/${id}/networks/scans`).post().json()
+  }
+
+  const getNetworkScan = (id: string, scanId: string) => {
+    return useFetch<NetworkScanResponse>(`modems/${id}/networks/scans/${scanId}`).get().json()
   }
 
   const registerNetwork = (id: string, operatorCode: string) => {
@@ -15,7 +19,8 @@ export const useNetworkApi = () => {
   }
 
   return {
-    scanNetworks,
+    startNetworkScan,
+    getNetworkScan,
     registerNetwork,
   }
 }
@@ -2,7 +2,10 @@ import { computed, ref, watch, type ComputedRef } from 'vue'
 import { useI18n } from 'vue-i18n'
 
 import { useNetworkApi } from '@/apis/network'
-import type { NetworkResponse } from '@/types/network'
+import type { NetworkResponse, NetworkScan } from '@/types/network'
+
+// Scans run in the background and can take minutes, so they are polled.
+const SCAN_POLL_MS = 2000
 
 type Options = {
   modemId: ComputedRef<string>
@@ -29,6 +32,21 @@ export const useModemNetwork = ({ modemId, onRegistered, onSuccess }: Options) =
     selectedNetwork.value = ''
   }
 
+  const scanNetworks = async (id: string): Promise<NetworkResponse[]> => {
+    const { data } = await networkApi.startNetworkScan(id)
+    let scan: NetworkScan | undefined = data.value?.data
+    while (scan?.status === 'running') {
+      if (!networkDialogOpen.value || modemId.value !== id) return []
+      await new Promise((resolve) => setTimeout(resolve, SCAN_POLL_MS))
+      const { data: polled } = await networkApi.getNetworkScan(id, scan.id)
+      scan = polled.value?.data
+    }
+    if (scan?.status === 'failed') {
+      console.error('[useModemNetwork] Network scan failed:', scan.error)
+    }
+    return scan?.status === 'succeeded' ? scan.networks : []
+  }
+
   const openNetworkDialog = async () => {
     const targetId = modemId.value
     if (!targetId || targetId === 'unknown') return
@@ -37,8 +55,7 @@ export const useModemNetwork = ({ modemId, onRegistered, onSuccess }: Options) =
     selectedNetwork.value = ''
     isNetworkLoading.value = true
     try {
-      const { data } = await networkApi.scanNetworks(targetId)
-      availableNetworks.value = data.value?.data ?? []
+      availableNetworks.value = await scanNetworks(targetId)
     } catch (err) {
       console.error('[useModemNetwork] Failed to scan networks:', err)
       availableNetworks.value = []
@@ -14,9 +14,21 @@ export type EsimProfilesResponse = ApiResponse<EsimProfileApiResponse[]>
 export type EsimDiscoverItem = {
   eventId: string
   address: string
+  server: string
 }
 
-export type EsimDiscoverResponse = ApiResponse<EsimDiscoverItem[]>
+export type EsimDiscoverServer = {
+  address: string
+  count: number
+  error?: string
+}
+
+export type EsimDiscoverResult = {
+  entries: EsimDiscoverItem[]
+  servers: EsimDiscoverServer[]
+}
+
+export type EsimDiscoverResponse = ApiResponse<EsimDiscoverResult>
 
 export type EsimProfile = {
   id: string
@@ -8,4 +8,15 @@ export type NetworkResponse = {
   accessTechnologies: string[]
 }
 
-export type NetworksResponse = ApiResponse<NetworkResponse[]>
+export type NetworkScanStatus = 'running' | 'succeeded' | 'failed'
+
+export type NetworkScan = {
+  id: string
+  status: NetworkScanStatus
+  startedAt: string
+  finishedAt?: string
+  networks: NetworkResponse[]
+  error?: string
+}
+
+export type NetworkScanResponse = ApiResponse<NetworkScan>